@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// positions of annotations on frames.
+const (
+	posTopLeft     = "top-left"
+	posTop         = "top"
+	posTopRight    = "top-right"
+	posBottomLeft  = "bottom-left"
+	posBottom      = "bottom"
+	posBottomRight = "bottom-right"
+)
+
+// annotationSpec is an annotation as it's written in the annotations file, empty fields take the defaults of the file.
+// @property {string} Text - The caption, lines are split by \n.
+// @property {string} Position - The position of the caption on the frame, e.g. bottom or top-left.
+// @property {string} Color - The color of the text, e.g. #ffffff.
+// @property {string} Background - The color of the box under the text, or none for no box.
+type annotationSpec struct {
+	Text       string `json:"text"`
+	Position   string `json:"position"`
+	Color      string `json:"color"`
+	Background string `json:"background"`
+}
+
+// annotationsFile is the json file with captions of frames by their index in the source or file name.
+// @property {annotationSpec} annotationSpec - The default position and colors of captions, its text is unused.
+// @property {map[string]annotationSpec} Frames - The captions by the index of the source image from 0 or its file name.
+type annotationsFile struct {
+	annotationSpec
+	Frames map[string]annotationSpec `json:"frames"`
+}
+
+// annotation is a caption drawn on a frame.
+// @property {string} text - The caption, lines are split by \n.
+// @property {string} position - The position of the caption on the frame.
+// @property {color.Color} color - The color of the text.
+// @property {color.Color} background - The color of the box under the text, nil for no box.
+type annotation struct {
+	text       string
+	position   string
+	color      color.Color
+	background color.Color
+}
+
+// readAnnotations reads captions of frames from the json file, keyed by the index of the source image or its file name.
+func readAnnotations(path string) (map[string]annotation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := annotationsFile{}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("annotations %s: %w", path, err)
+	}
+
+	defaults := annotationSpec{Position: posBottom, Color: "#ffffff", Background: "#000000"}
+	annotations := make(map[string]annotation, len(file.Frames))
+	for key, spec := range file.Frames {
+		a, err := resolveAnnotation(spec, file.annotationSpec, defaults)
+		if err != nil {
+			return nil, fmt.Errorf("annotations %s: frame %q: %w", path, key, err)
+		}
+		annotations[key] = a
+	}
+	return annotations, nil
+}
+
+// resolveAnnotation fills empty fields of the annotation from the defaults of the file, then from the built-in ones.
+func resolveAnnotation(spec annotationSpec, defaults ...annotationSpec) (annotation, error) {
+	for _, d := range defaults {
+		if spec.Position == "" {
+			spec.Position = d.Position
+		}
+		if spec.Color == "" {
+			spec.Color = d.Color
+		}
+		if spec.Background == "" {
+			spec.Background = d.Background
+		}
+	}
+	switch spec.Position {
+	case posTopLeft, posTop, posTopRight, posBottomLeft, posBottom, posBottomRight:
+	default:
+		return annotation{}, fmt.Errorf("invalid position %q, use top-left, top, top-right, bottom-left, bottom or bottom-right", spec.Position)
+	}
+	fg, err := parseHexColor(spec.Color)
+	if err != nil {
+		return annotation{}, err
+	}
+	a := annotation{text: spec.Text, position: spec.Position, color: fg}
+	if spec.Background != "none" {
+		if a.background, err = parseHexColor(spec.Background); err != nil {
+			return annotation{}, err
+		}
+	}
+	return a, nil
+}
+
+// findAnnotation returns the caption of the source image by its index or file name, false if it has none.
+func findAnnotation(annotations map[string]annotation, n int, file string) (annotation, bool) {
+	if a, ok := annotations[strconv.Itoa(n)]; ok {
+		return a, true
+	}
+	a, ok := annotations[filepath.Base(file)]
+	return a, ok
+}
+
+// drawText draws lines of text on a box of the background color at the position on a copy of the image,
+// a nil background draws the text only.
+func drawText(img image.Image, text, position string, fg, bg color.Color) image.Image {
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	draw.Draw(dst, b, img, b.Min, draw.Src)
+
+	face := basicfont.Face7x13
+	d := font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(fg),
+		Face: face,
+	}
+	lines := strings.Split(text, "\n")
+	width := 0
+	for _, l := range lines {
+		width = max(width, d.MeasureString(l).Ceil())
+	}
+	pad := 2
+	size := image.Pt(width+2*pad, len(lines)*face.Height+2*pad)
+
+	at := b.Min
+	switch position {
+	case posTop, posBottom:
+		at.X += (b.Dx() - size.X) / 2
+	case posTopRight, posBottomRight:
+		at.X = b.Max.X - size.X
+	}
+	if strings.HasPrefix(position, "bottom") {
+		at.Y = b.Max.Y - size.Y
+	}
+	box := image.Rectangle{at, at.Add(size)}
+	if bg != nil {
+		draw.Draw(dst, box.Intersect(b), image.NewUniform(bg), image.Point{}, draw.Src)
+	}
+
+	for i, l := range lines {
+		d.Dot = fixed.P(box.Min.X+pad, box.Min.Y+pad+i*face.Height+face.Ascent)
+		d.DrawString(l)
+	}
+	return dst
+}
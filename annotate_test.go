@@ -0,0 +1,85 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// countColors counts pixels of the image in the area by their colors.
+func countColors(img image.Image, area image.Rectangle) map[color.RGBA]int {
+	counts := map[color.RGBA]int{}
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			counts[color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)]++
+		}
+	}
+	return counts
+}
+
+func TestReadAnnotations(t *testing.T) {
+	a, err := readAnnotations(writeConfig(t, t.TempDir(), "annotations.json", `{"position": "top", "background": "none", "frames": {
+		"0": {"text": "Open"},
+		"frame.png": {"text": "Save", "position": "bottom-left", "color": "#ff0000", "background": "#0000ff"}
+	}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := a["0"]; got.text != "Open" || got.position != posTop || !colorsEqual(got.color, color.White) || got.background != nil {
+		t.Errorf("got %+v, want the defaults of the file", got)
+	}
+	if got := a["frame.png"]; got.position != posBottomLeft || !colorsEqual(got.color, testRed) || !colorsEqual(got.background, testBlue) {
+		t.Errorf("got %+v, want the fields of the frame", got)
+	}
+
+	for data, want := range map[string]string{
+		`{"frames": {"0": {"text": "a", "position": "middle"}}}`: `frame "0": invalid position "middle"`,
+		`{"frames": {"1": {"text": "a", "color": "white"}}}`:     `frame "1": `,
+		`{"frames": [1, 2]}`: "annotations ",
+	} {
+		if _, err := readAnnotations(writeConfig(t, t.TempDir(), "annotations.json", data)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got %v, want an error with %q", data, err, want)
+		}
+	}
+	parseUsageError(t, "-annotations", filepath.Join(t.TempDir(), "missing.json"))
+}
+
+func TestAnnotations(t *testing.T) {
+	a, err := readAnnotations(writeConfig(t, t.TempDir(), "annotations.json", `{"frames": {
+		"1": {"text": "Open"},
+		"0003.png": {"text": "Save", "position": "top-right", "color": "#ffff00", "background": "none"}
+	}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	green := testFrame(96, 48, testGreen)
+	frames := readTestImages(t, Options{annotations: a, noDedup: true}, green, green, green, green)
+	if len(frames) != 4 {
+		t.Fatalf("got %d frames, want 4", len(frames))
+	}
+
+	whole := green.Bounds()
+	for _, n := range []int{0, 3} {
+		if counts := countColors(frames[n].img, whole); len(counts) != 1 {
+			t.Errorf("frame %d without a caption has colors %v", n, counts)
+		}
+	}
+	// the caption of the second frame is a black box at the bottom, the top stays green
+	black, white := color.RGBA{0, 0, 0, 255}, color.RGBA{255, 255, 255, 255}
+	if counts := countColors(frames[1].img, image.Rect(0, 0, 96, 24)); len(counts) != 1 {
+		t.Errorf("the top of frame 1 has colors %v", counts)
+	}
+	if counts := countColors(frames[1].img, image.Rect(0, 24, 96, 48)); counts[black] == 0 || counts[white] == 0 {
+		t.Errorf("the bottom of frame 1 has no caption: %v", counts)
+	}
+	// the caption of the third frame is yellow text at the top right without a box
+	yellow := color.RGBA{255, 255, 0, 255}
+	if counts := countColors(frames[2].img, image.Rect(48, 0, 96, 24)); counts[yellow] == 0 || counts[black] != 0 {
+		t.Errorf("the top right of frame 2 has colors %v, want yellow text without a box", counts)
+	}
+	if counts := countColors(frames[2].img, image.Rect(0, 0, 48, 48)); len(counts) != 1 {
+		t.Errorf("the left of frame 2 has colors %v", counts)
+	}
+}
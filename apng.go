@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"io"
+)
+
+// pngHeader is the signature every png file starts with.
+const pngHeader = "\x89PNG\r\n\x1a\n"
+
+// apngWriter writes png chunks and counts the sequence numbers of animation chunks.
+// @property w - The writer to write chunks to.
+// @property {uint32} seq - The sequence number of the next fcTL or fdAT chunk.
+// @property {error} err - The first error that happened while writing, next writes are skipped.
+type apngWriter struct {
+	w   io.Writer
+	seq uint32
+	err error
+}
+
+// writeChunk writes a png chunk with its length and crc.
+func (a *apngWriter) writeChunk(name string, data []byte) {
+	if a.err != nil {
+		return
+	}
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], name)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	footer := make([]byte, 4)
+	binary.BigEndian.PutUint32(footer, crc.Sum32())
+
+	for _, b := range [][]byte{header, data, footer} {
+		if _, err := a.w.Write(b); err != nil {
+			a.err = err
+			return
+		}
+	}
+}
+
+// nextSeq returns the sequence number for the next animation chunk.
+func (a *apngWriter) nextSeq() uint32 {
+	seq := a.seq
+	a.seq++
+	return seq
+}
+
+// apngPlays converts the loop count of options, as in gif.GIF, to the number of plays of an animated png.
+// 0 loops forever in both, -1 plays once, and n restarts the animation n times after the first play.
+func apngPlays(loopCount int) uint32 {
+	switch {
+	case loopCount == 0:
+		return 0
+	case loopCount < 0:
+		return 1
+	}
+	return uint32(loopCount) + 1
+}
+
+// encodeApng encodes frames as an animated png, all frames should be the same size.
+// Frames are stored as 8-bit RGBA to keep the same color type for all of them.
+// No ancillary chunks are written besides the animation ones, so metadata of source images never ends up in the file.
+func encodeApng(w io.Writer, images *[]imgWithDelay, delay int, opts Options) error {
+	if len(*images) == 0 {
+		return fmt.Errorf("apng: must provide at least one image")
+	}
+	bounds := (*images)[0].img.Bounds()
+	for _, im := range *images {
+		if im.img.Bounds().Size() != bounds.Size() {
+			return fmt.Errorf("apng: all frames should be the same size, got %v and %v", bounds.Size(), im.img.Bounds().Size())
+		}
+	}
+	frames, delays := splitDelays(*images, frameDelays(repetitions(images), delay, opts), opts.maxDelay)
+
+	if _, err := io.WriteString(w, pngHeader); err != nil {
+		return err
+	}
+	a := &apngWriter{w: w}
+
+	// IHDR: width, height, bit depth 8, color type 6 (RGBA), compression, filter and interlace methods 0.
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:4], uint32(bounds.Dx()))
+	binary.BigEndian.PutUint32(ihdr[4:8], uint32(bounds.Dy()))
+	ihdr[8], ihdr[9] = 8, 6
+	a.writeChunk("IHDR", ihdr)
+
+	// acTL: number of frames and number of plays, 0 loops forever.
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl[0:4], uint32(len(frames)))
+	binary.BigEndian.PutUint32(actl[4:8], apngPlays(opts.loopCount))
+	a.writeChunk("acTL", actl)
+
+	for n, im := range frames {
+		// fcTL: sequence, size, offset, delay as a fraction of a second, dispose and blend operations.
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:4], a.nextSeq())
+		binary.BigEndian.PutUint32(fctl[4:8], uint32(bounds.Dx()))
+		binary.BigEndian.PutUint32(fctl[8:12], uint32(bounds.Dy()))
+		binary.BigEndian.PutUint16(fctl[20:22], uint16(delays[n]))
+		binary.BigEndian.PutUint16(fctl[22:24], 100)
+		a.writeChunk("fcTL", fctl)
+
+		data, err := compressFrame(im.img, opts.zlibLevel())
+		if err != nil {
+			return err
+		}
+		// the first frame is the default image that is shown by viewers without apng support.
+		if n == 0 {
+			a.writeChunk("IDAT", data)
+			continue
+		}
+		fdat := make([]byte, 4, 4+len(data))
+		binary.BigEndian.PutUint32(fdat, a.nextSeq())
+		a.writeChunk("fdAT", append(fdat, data...))
+	}
+
+	a.writeChunk("IEND", nil)
+	return a.err
+}
+
+// repetitions returns the number of source images each frame stands for.
+func repetitions(images *[]imgWithDelay) []int {
+	reps := make([]int, len(*images))
+	for n, im := range *images {
+		reps[n] = im.delay
+	}
+	return reps
+}
+
+// compressFrame converts the image to RGBA scanlines, filters and compresses them with zlib of the level.
+func compressFrame(img image.Image, level int) ([]byte, error) {
+	b := img.Bounds()
+	rgba, ok := img.(*image.NRGBA)
+	if !ok || rgba.Rect.Min != (image.Point{}) {
+		rgba = image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(rgba, rgba.Rect, img, b.Min, draw.Src)
+	}
+
+	buf := bytes.Buffer{}
+	zw, err := zlib.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	rowLen := 4 * b.Dx()
+	prev := make([]byte, rowLen)
+	filtered := make([][]byte, 5)
+	for i := range filtered {
+		filtered[i] = make([]byte, rowLen+1)
+	}
+
+	for y := 0; y < b.Dy(); y++ {
+		row := rgba.Pix[y*rgba.Stride : y*rgba.Stride+rowLen]
+		if _, err := zw.Write(filterRow(row, prev, filtered)); err != nil {
+			return nil, err
+		}
+		prev = row
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// filterRow applies all png filters to the row and returns the one with the smallest sum of absolute values,
+// the same heuristic the standard png encoder uses. The first byte of the result is the filter type.
+func filterRow(row, prev []byte, filtered [][]byte) []byte {
+	const bpp = 4
+	best, bestSum := 0, -1
+	for ft := range filtered {
+		out := filtered[ft]
+		out[0] = byte(ft)
+		sum := 0
+		for i := range row {
+			var a, b, c byte
+			if i >= bpp {
+				a, c = row[i-bpp], prev[i-bpp]
+			}
+			b = prev[i]
+			var p byte
+			switch ft {
+			case 0:
+				p = 0
+			case 1:
+				p = a
+			case 2:
+				p = b
+			case 3:
+				p = byte((int(a) + int(b)) / 2)
+			case 4:
+				p = paeth(a, b, c)
+			}
+			out[i+1] = row[i] - p
+			sum += absInt(int(int8(out[i+1])))
+		}
+		if bestSum < 0 || sum < bestSum {
+			best, bestSum = ft, sum
+		}
+	}
+	return filtered[best]
+}
+
+// paeth is the Paeth predictor from the png specification.
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := absInt(p-int(a)), absInt(p-int(b)), absInt(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+// absInt returns the absolute value of an int.
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
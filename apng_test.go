@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"testing"
+)
+
+// apngInfo returns the number of frames and plays from the acTL chunk of the animated png, and the number of fcTL chunks.
+func apngInfo(t *testing.T, data []byte) (frames, plays uint32, controls int) {
+	t.Helper()
+	for i := len(pngHeader); i+8 <= len(data); {
+		n := int(binary.BigEndian.Uint32(data[i:]))
+		switch string(data[i+4 : i+8]) {
+		case "acTL":
+			frames = binary.BigEndian.Uint32(data[i+8:])
+			plays = binary.BigEndian.Uint32(data[i+12:])
+		case "fcTL":
+			controls++
+		}
+		i += 12 + n
+	}
+	return frames, plays, controls
+}
+
+// pngChunk returns an encoded png chunk.
+func pngChunk(typ string, data []byte) []byte {
+	c := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	c = append(c, typ...)
+	c = append(c, data...)
+	return binary.BigEndian.AppendUint32(c, crc32.ChecksumIEEE(c[4:]))
+}
+
+// apngFrames decodes frames of the animated png, each one is rebuilt as a png of its size and data.
+func apngFrames(t *testing.T, data []byte) []image.Image {
+	t.Helper()
+	var ihdr []byte
+	frames := []image.Image{}
+	var frame *bytes.Buffer
+	flush := func() {
+		if frame == nil {
+			return
+		}
+		frame.Write(pngChunk("IEND", nil))
+		img, err := png.Decode(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, img)
+		frame = nil
+	}
+	for i := len(pngHeader); i+8 <= len(data); {
+		n := int(binary.BigEndian.Uint32(data[i:]))
+		body := data[i+8 : i+8+n]
+		switch string(data[i+4 : i+8]) {
+		case "IHDR":
+			ihdr = append([]byte{}, body...)
+		case "fcTL":
+			flush()
+			// the size of the frame replaces the one of the image
+			h := append([]byte{}, ihdr...)
+			copy(h[0:8], body[4:12])
+			frame = bytes.NewBufferString(pngHeader)
+			frame.Write(pngChunk("IHDR", h))
+		case "IDAT":
+			frame.Write(pngChunk("IDAT", body))
+		case "fdAT":
+			frame.Write(pngChunk("IDAT", body[4:]))
+		}
+		i += 12 + n
+	}
+	flush()
+	return frames
+}
+
+func TestEncodeApng(t *testing.T) {
+	for _, tt := range []struct {
+		loopCount int
+		plays     uint32
+	}{{0, 0}, {-1, 1}, {2, 3}} {
+		b := bytes.Buffer{}
+		if err := encodeApng(&b, testFrames(8, 8, testRed, testGreen, testBlue), 5, Options{loopCount: tt.loopCount}); err != nil {
+			t.Fatal(err)
+		}
+		frames, plays, controls := apngInfo(t, b.Bytes())
+		if frames != 3 || controls != 3 {
+			t.Errorf("got %d frames and %d frame controls, want 3", frames, controls)
+		}
+		if plays != tt.plays {
+			t.Errorf("loop count %d: got %d plays, want %d", tt.loopCount, plays, tt.plays)
+		}
+		// viewers without animation support show the first frame
+		img, err := png.Decode(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !colorsEqual(img.At(0, 0), testRed) {
+			t.Errorf("the default image is %v, want %v", img.At(0, 0), testRed)
+		}
+	}
+}
+
+func TestApngCompression(t *testing.T) {
+	images := framesOf(testPattern(64, 48, 0), testGradient(64, 48, testGreen))
+	sizes := []int{}
+	for _, level := range []int{-1, 1, 9} {
+		b := bytes.Buffer{}
+		if err := encodeApng(&b, &images, 5, Options{compression: level}); err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, b.Len())
+		frames := apngFrames(t, b.Bytes())
+		if len(frames) != len(images) {
+			t.Fatalf("level %d: got %d frames, want %d", level, len(frames), len(images))
+		}
+		for n, f := range frames {
+			for y := 0; y < 48; y++ {
+				for x := 0; x < 64; x++ {
+					if !colorsEqual(f.At(x, y), images[n].img.At(x, y)) {
+						t.Fatalf("level %d: frame %d is %v at %d,%d, want %v", level, n, f.At(x, y), x, y, images[n].img.At(x, y))
+					}
+				}
+			}
+		}
+	}
+	if !(sizes[0] > sizes[1] && sizes[1] > sizes[2]) {
+		t.Errorf("got sizes %v for no compression, level 1 and 9, want them decreasing", sizes)
+	}
+
+	cfg := parseTestFlags(t, "-compression", "0")
+	if cfg.opts.compression != -1 {
+		t.Errorf("-compression 0: got %d, want -1 for no compression", cfg.opts.compression)
+	}
+	parseUsageError(t, "-compression", "10")
+}
+
+func TestEncodeToApng(t *testing.T) {
+	b := bytes.Buffer{}
+	if err := encodeTo(context.Background(), &b, formatApng, testFrames(8, 8, testRed, testBlue), 5, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if frames, _, _ := apngInfo(t, b.Bytes()); frames != 2 {
+		t.Errorf("got %d frames, want 2", frames)
+	}
+}
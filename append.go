@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"io/fs"
+	"math"
+)
+
+// readGifFrames decodes frames of an existing gif as they are shown, composed on the screen with their disposal,
+// with delays in numbers of frames of the delay in 100ths of a second. Returns the loop count of the gif too.
+// A missing gif gives no frames and the loop count of options, so the first build of an appended gif is a plain one.
+func readGifFrames(path string, delay int, opts Options) ([]imgWithDelay, int, error) {
+	f, err := opts.open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, opts.loopCount, nil
+	}
+	if err != nil {
+		return nil, 0, &fileError{"open gif", path, err}
+	}
+	defer f.Close()
+
+	g, err := gif.DecodeAll(f)
+	if err != nil {
+		return nil, 0, &fileError{"decode gif", path, err}
+	}
+
+	frames := make([]imgWithDelay, 0, len(g.Image))
+	inexact := false
+	for i, frame := range composeGifFrames(g) {
+		// delays of the gif are kept as close as the frame rate allows
+		d := int(math.Round(float64(g.Delay[i]) / float64(delay)))
+		if d < 1 {
+			d = 1
+		}
+		if d*delay != g.Delay[i] {
+			inexact = true
+		}
+		frames = append(frames, imgWithDelay{frame, d, []string{path}})
+	}
+	if inexact {
+		opts.warnf("delays of frames of %s are rounded to the frame rate", path)
+	}
+	return frames, g.LoopCount, nil
+}
+
+// composeGifFrames returns frames of the gif as they are shown, composed on the screen with their disposal.
+func composeGifFrames(g *gif.GIF) []image.Image {
+	screen := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	canvas := image.NewRGBA(screen)
+	frames := make([]image.Image, 0, len(g.Image))
+	for i, p := range g.Image {
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(screen)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, p.Bounds(), p, p.Bounds().Min, draw.Over)
+		frame := image.NewRGBA(screen)
+		copy(frame.Pix, canvas.Pix)
+		frames = append(frames, frame)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, p.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return frames
+}
+
+// appendFrames puts the frames of the existing gif before the new ones, their sizes should match.
+func appendFrames(existing, images []imgWithDelay) ([]imgWithDelay, error) {
+	if len(existing) == 0 || len(images) == 0 {
+		return append(existing, images...), nil
+	}
+	gs, is := existing[0].img.Bounds().Size(), images[0].img.Bounds().Size()
+	if gs != is {
+		return nil, fmt.Errorf("can't append images of %d×%d to the gif of %d×%d", is.X, is.Y, gs.X, gs.Y)
+	}
+	return append(existing, images...), nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAppend(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	writeTestImages(t, first, testPattern(32, 32, 0), testPattern(32, 32, 60))
+	writeTestImages(t, second, testPattern(32, 32, 120), testPattern(32, 32, 120), testGradient(32, 32, testBlue))
+	out := filepath.Join(t.TempDir(), "out.gif")
+	build := func(dir string, opts Options) error {
+		files, err := listFiles(dir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return BuildGif(context.Background(), files, out, opts)
+	}
+
+	// the first append builds a plain gif
+	if err := build(first, Options{appendOutput: true, fps: 20, loopCount: 3}); err != nil {
+		t.Fatal(err)
+	}
+	before := decodeTestGif(t, out)
+	if len(before.Image) != 2 || before.LoopCount != 3 {
+		t.Fatalf("got %d frames and loop count %d, want 2 and 3", len(before.Image), before.LoopCount)
+	}
+
+	// the existing loop count and delays are kept
+	if err := build(second, Options{appendOutput: true, fps: 20}); err != nil {
+		t.Fatal(err)
+	}
+	g := decodeTestGif(t, out)
+	if len(g.Image) != 4 {
+		t.Fatalf("got %d frames, want 2 existing and 2 new ones", len(g.Image))
+	}
+	if !slices.Equal(g.Delay, []int{5, 5, 10, 5}) {
+		t.Errorf("got delays %v, want [5 5 10 5]", g.Delay)
+	}
+	if g.LoopCount != 3 {
+		t.Errorf("got loop count %d, want the existing 3", g.LoopCount)
+	}
+	composed, want := composeGifFrames(g), composeGifFrames(before)
+	for n := range want {
+		if changedArea(composed[n], want[n]) != (image.Rectangle{}) {
+			t.Errorf("existing frame %d is changed", n)
+		}
+	}
+
+	// images of another size can't be appended
+	other := t.TempDir()
+	writeTestImages(t, other, testPattern(16, 16, 0), testPattern(16, 16, 60))
+	if err := build(other, Options{appendOutput: true}); err == nil || !strings.Contains(err.Error(), "can't append images of 16×16 to the gif of 32×32") {
+		t.Errorf("got %v, want a size error", err)
+	}
+	// only a single gif output is appended to
+	files, _ := listFiles(first, Options{})
+	if err := BuildGif(context.Background(), files, out+","+filepath.Join(t.TempDir(), "out.apng"), Options{appendOutput: true}); err == nil {
+		t.Error("got no error appending to two outputs")
+	}
+	if _, err := os.Stat(out); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppendKeepsGifOnErrors(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.gif")
+	writeTestGif(t, out, 10, 20)
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the existing gif is read from the file system in options
+	frames, _, err := readGifFrames("out.gif", 10, Options{fsys: fstest.MapFS{"out.gif": {Data: data}}})
+	if err != nil || len(frames) != 2 {
+		t.Fatalf("got %d frames and %v, want 2 frames", len(frames), err)
+	}
+
+	// a canceled build leaves the gif as it was, without temporary files
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := writeOutput(ctx, testFrames(32, 32, testRed, testBlue), 10, out, Options{appendOutput: true}); err == nil {
+		t.Fatal("got no error for a canceled build")
+	}
+	if got, err := os.ReadFile(out); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("the gif is changed: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("got %d files, want only the gif", len(entries))
+	}
+
+	// a written gif replaces the existing one
+	if err := writeOutput(context.Background(), testFrames(32, 32, testRed, testBlue, testGreen), 10, out, Options{appendOutput: true}); err != nil {
+		t.Fatal(err)
+	}
+	if g := decodeTestGif(t, out); len(g.Image) != 3 {
+		t.Errorf("got %d frames, want 3", len(g.Image))
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("got %d files, want only the gif", len(entries))
+	}
+}
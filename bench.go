@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"time"
+)
+
+// benchStage is the throughput of a stage of the pipeline.
+// @property {string} name - The name of the stage.
+// @property {int} frames - The number of frames processed in the stage.
+// @property {time.Duration} duration - The time the stage took.
+type benchStage struct {
+	name     string
+	frames   int
+	duration time.Duration
+}
+
+// String formats the stage as a row of the bench table.
+func (s benchStage) String() string {
+	fps := 0.0
+	if s.duration > 0 {
+		fps = float64(s.frames) / s.duration.Seconds()
+	}
+	return fmt.Sprintf("%-8s %6d frames %10s %10.1f frames/s", s.name, s.frames, s.duration.Round(time.Millisecond), fps)
+}
+
+// nopWriteCloser discards everything written to it.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// runBench runs the pipeline on images of the path and prints the throughput of its stages,
+// outputs are encoded in the format of -out, but nothing is written.
+func runBench(cfg config, w io.Writer) error {
+	if cfg.path == "" {
+		return &usageError{fmt.Errorf("bench: -path is required")}
+	}
+	if cfg.opts.fromVideo || isVideo(cfg.path) || isWebp(cfg.path) || isGif(cfg.path) || isPdf(cfg.path) {
+		return &usageError{fmt.Errorf("bench: frames of videos, webp, gif and pdf files can't be benchmarked")}
+	}
+	format, err := outputFormat(splitOutputs(cfg.out)[0], cfg.opts.format)
+	if err != nil {
+		return err
+	}
+	fps, err := normalizeFps(cfg.opts.fps)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	opts := cfg.opts
+	// nothing is written, so there is no existing output to append to and no report
+	opts.appendOutput = false
+	opts.reportPath = ""
+
+	files, err := listFiles(cfg.path, opts)
+	if err != nil {
+		return err
+	}
+	if len(*files) == 0 {
+		return fmt.Errorf("no images found in %s", cfg.path)
+	}
+	paths, _ := sampleFiles(*files, opts.sample)
+
+	// decoding and comparing are timed in one pass, images are compared between decoded batches
+	compare := time.Duration(0)
+	prev := image.Image(nil)
+	start := time.Now()
+	err = decodeImages(ctx, paths, opts, func(n int, img image.Image) {
+		t := time.Now()
+		img, _ = transformImage(img, sourceIndex(n, opts.sample), paths[n], opts)
+		if prev != nil {
+			FramesSimilar(prev, img, opts.similarity())
+		}
+		prev = img
+		compare += time.Since(t)
+	})
+	if err != nil {
+		return err
+	}
+	decode := time.Since(start) - compare
+
+	frames, err := readImages(ctx, files, opts)
+	if err != nil {
+		return err
+	}
+	start = time.Now()
+	if err := encodeTo(ctx, io.Discard, format, &frames, 100/fps, opts); err != nil {
+		return err
+	}
+	encode := time.Since(start)
+
+	opts.create = func(string) (io.WriteCloser, error) { return nopWriteCloser{io.Discard}, nil }
+	start = time.Now()
+	if err := BuildGif(ctx, files, "bench."+format, opts); err != nil {
+		return err
+	}
+	total := time.Since(start)
+
+	for _, s := range []benchStage{
+		{"decode", len(paths), decode},
+		{"dedup", len(paths), compare},
+		{"encode", len(frames), encode},
+		{"total", len(paths), total},
+	} {
+		if _, err := fmt.Fprintln(w, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunBenchWritesNothing(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPngs(t, dir, 16, 16, testRed, testGreen, testBlue)
+	// bench builds bench.gif in the working directory, it isn't a gif, so appending to it fails
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	out := filepath.Join(dir, "bench.gif")
+	if err := os.WriteFile(out, []byte("not a gif"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	report := filepath.Join(dir, "report.csv")
+	cfg := config{path: dir, out: filepath.Join(dir, "out.gif"), opts: Options{appendOutput: true, reportPath: report}}
+
+	b := bytes.Buffer{}
+	if err := runBench(cfg, &b); err != nil {
+		t.Fatal(err)
+	}
+	for _, stage := range []string{"decode", "dedup", "encode", "total"} {
+		if !strings.Contains(b.String(), stage) {
+			t.Errorf("no %s stage in %q", stage, b.String())
+		}
+	}
+	if _, err := os.Stat(report); !os.IsNotExist(err) {
+		t.Errorf("report is written: %v", err)
+	}
+	if data, err := os.ReadFile(out); err != nil || string(data) != "not a gif" {
+		t.Errorf("output is changed: %q, %v", data, err)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import "image"
+
+// blankSamples is the number of pixels along each side of the image checked before all of its pixels are.
+const blankSamples = 16
+
+// isBlank checks if the image is entirely one color, like an accidental export of an empty canvas.
+// A grid of pixels is checked first, so most images with content are rejected quickly.
+func isBlank(img image.Image) bool {
+	b := img.Bounds()
+	if b.Empty() {
+		return true
+	}
+	r0, g0, b0, a0 := img.At(b.Min.X, b.Min.Y).RGBA()
+	same := func(x, y int) bool {
+		r, g, bl, a := img.At(x, y).RGBA()
+		return r == r0 && g == g0 && bl == b0 && a == a0
+	}
+
+	stepX, stepY := max(b.Dx()/blankSamples, 1), max(b.Dy()/blankSamples, 1)
+	for y := b.Min.Y; y < b.Max.Y; y += stepY {
+		for x := b.Min.X; x < b.Max.X; x += stepX {
+			if !same(x, y) {
+				return false
+			}
+		}
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if !same(x, y) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"image"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestIsBlank(t *testing.T) {
+	// a single pixel between the sampled ones still counts
+	speck := testFrame(64, 64, testRed)
+	speck.Set(33, 35, testBlue)
+	for _, tt := range []struct {
+		name string
+		img  image.Image
+		want bool
+	}{
+		{"solid", testFrame(64, 64, testRed), true},
+		{"offset", testFrame(64, 64, testGreen).SubImage(image.Rect(10, 10, 30, 30)), true},
+		{"empty", image.NewRGBA(image.Rect(0, 0, 0, 0)), true},
+		{"speck", speck, false},
+		{"pattern", testPattern(64, 64, 0), false},
+	} {
+		if got := isBlank(tt.img); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDropBlank(t *testing.T) {
+	images := []image.Image{testFrame(32, 32, testRed), testPattern(32, 32, 0), testFrame(32, 32, testRed), testPattern(32, 32, 60), testFrame(32, 32, testBlue), testPattern(32, 32, 120)}
+	warnings := []string{}
+	frames := readTestImages(t, Options{dropBlank: true, warn: func(s string) { warnings = append(warnings, s) }}, images...)
+	sources := []string{}
+	for _, f := range frames {
+		for _, s := range f.sources {
+			sources = append(sources, filepath.Base(s))
+		}
+	}
+	if want := []string{"0002.png", "0004.png", "0006.png"}; !slices.Equal(sources, want) {
+		t.Errorf("got sources %v, want %v", sources, want)
+	}
+	if want := []string{"3 blank images of a single color are dropped"}; !slices.Equal(warnings, want) {
+		t.Errorf("got warnings %q, want %q", warnings, want)
+	}
+
+	// without the option blank images are kept
+	if frames := readTestImages(t, Options{noDedup: true}, images...); len(frames) != 6 {
+		t.Errorf("got %d frames, want 6", len(frames))
+	}
+
+	blank := t.TempDir()
+	writeTestImages(t, blank, testFrame(32, 32, testRed), testFrame(32, 32, testBlue))
+	files, err := listFiles(blank, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readImages(context.Background(), files, parseTestFlags(t, "-drop-blank").opts); err == nil || !strings.Contains(err.Error(), "all 2 images are blank") {
+		t.Errorf("got %v, want an error about blank images", err)
+	}
+}
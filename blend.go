@@ -0,0 +1,69 @@
+package main
+
+import (
+	"image"
+	"image/color"
+)
+
+// blendImages inserts n crossfaded frames between each pair of consecutive frames, each shown for one frame.
+// Pairs of frames with different sizes are not blended.
+func blendImages(images []imgWithDelay, n int, opts Options) []imgWithDelay {
+	if n <= 0 || len(images) < 2 {
+		return images
+	}
+	blended := make([]imgWithDelay, 0, len(images)+(len(images)-1)*n)
+	skipped := 0
+	for i, im := range images {
+		blended = append(blended, im)
+		if i == len(images)-1 {
+			break
+		}
+		next := images[i+1].img
+		if im.img.Bounds().Size() != next.Bounds().Size() {
+			skipped++
+			continue
+		}
+		blend := blendFrames
+		if opts.linear {
+			blend = blendLinear
+		}
+		for _, b := range blend(im.img, next, n) {
+			blended = append(blended, imgWithDelay{b, 1, nil})
+		}
+	}
+	if skipped > 0 {
+		opts.warnf("%d pairs of frames with different sizes are not blended", skipped)
+	}
+	return blended
+}
+
+// blendFrames returns n frames fading from a to b, not including a and b themselves.
+// Colors are mixed premultiplied by alpha, so transparent pixels don't bleed their color.
+func blendFrames(a, b image.Image, n int) []image.Image {
+	ba, bb := a.Bounds(), b.Bounds()
+	frames := make([]image.Image, n)
+	for i := range frames {
+		t := float64(i+1) / float64(n+1)
+		dst := image.NewRGBA(image.Rect(0, 0, ba.Dx(), ba.Dy()))
+		for y := 0; y < ba.Dy(); y++ {
+			for x := 0; x < ba.Dx(); x++ {
+				r1, g1, b1, a1 := a.At(ba.Min.X+x, ba.Min.Y+y).RGBA()
+				r2, g2, b2, a2 := b.At(bb.Min.X+x, bb.Min.Y+y).RGBA()
+				dst.SetRGBA(x, y, color.RGBA{
+					R: lerp8(r1, r2, t),
+					G: lerp8(g1, g2, t),
+					B: lerp8(b1, b2, t),
+					A: lerp8(a1, a2, t),
+				})
+			}
+		}
+		frames[i] = dst
+	}
+	return frames
+}
+
+// lerp8 interpolates between 16-bit color values and returns the 8-bit result rounded to the nearest.
+func lerp8(v1, v2 uint32, t float64) uint8 {
+	v := float64(v1)*(1-t) + float64(v2)*t
+	return uint8((v/0x101 + 0.5))
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestBlendMidpoint(t *testing.T) {
+	a := testFrame(4, 4, color.RGBA{200, 10, 0, 255})
+	b := testFrame(4, 4, color.RGBA{100, 30, 255, 255})
+	src := framesOf(a, b)
+	src[0].delay, src[1].delay = 3, 2
+	frames := blendImages(src, 1, Options{})
+	if len(frames) != 3 {
+		t.Fatalf("got %d frames, want 3", len(frames))
+	}
+	if frames[0].img != a || frames[2].img != b || frames[0].delay != 3 || frames[2].delay != 2 {
+		t.Error("source frames are changed")
+	}
+	if frames[1].delay != 1 {
+		t.Errorf("got the delay %d of the blended frame, want 1", frames[1].delay)
+	}
+	want := color.RGBA{150, 20, 128, 255}
+	if got := color.RGBAModel.Convert(frames[1].img.At(2, 2)); got != want {
+		t.Errorf("got the midpoint %v, want %v", got, want)
+	}
+}
+
+func TestBlendSteps(t *testing.T) {
+	a := testFrame(2, 2, color.RGBA{0, 0, 0, 255})
+	b := testFrame(2, 2, color.RGBA{255, 255, 255, 255})
+	c := testFrame(2, 2, color.RGBA{0, 0, 0, 255})
+	frames := blendImages(framesOf(a, b, c), 4, Options{})
+	if len(frames) != 3+2*4 {
+		t.Fatalf("got %d frames, want 11", len(frames))
+	}
+	// the fade goes up to white in 5 steps and back down
+	want := []uint8{0, 51, 102, 153, 204, 255, 204, 153, 102, 51, 0}
+	for i, f := range frames {
+		if r, _, _, _ := f.img.At(0, 0).RGBA(); uint8(r>>8) != want[i] {
+			t.Errorf("frame %d: got %d, want %d", i, r>>8, want[i])
+		}
+	}
+	if got := blendImages(frames[:1], 4, Options{}); len(got) != 1 {
+		t.Errorf("got %d frames of a single one", len(got))
+	}
+	if got := blendImages(frames, 0, Options{}); len(got) != len(frames) {
+		t.Error("frames are blended with 0")
+	}
+}
+
+func TestBlendTransparent(t *testing.T) {
+	// transparent red pixels of a png don't tint the fade into blue
+	a := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	for i := 0; i < len(a.Pix); i += 4 {
+		a.Pix[i] = 255
+	}
+	b := testFrame(2, 2, color.RGBA{0, 0, 200, 200})
+	frames := blendImages(framesOf(a, b), 1, Options{})
+	got := color.RGBAModel.Convert(frames[1].img.At(1, 1)).(color.RGBA)
+	if got != (color.RGBA{0, 0, 100, 100}) {
+		t.Errorf("got %v, want half of the blue", got)
+	}
+}
+
+func TestBlendSizes(t *testing.T) {
+	warnings := []string{}
+	opts := Options{warn: func(msg string) { warnings = append(warnings, msg) }}
+	frames := blendImages(framesOf(testFrame(2, 2, color.Black), testFrame(4, 4, color.White), testFrame(4, 4, color.Black)), 1, opts)
+	if len(frames) != 4 {
+		t.Errorf("got %d frames, want only the pair of the same size blended", len(frames))
+	}
+	if len(warnings) != 1 || warnings[0] != "1 pairs of frames with different sizes are not blended" {
+		t.Errorf("got warnings %q", warnings)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// cacheKey is the crc of names and contents of input files and options that change the output.
+func cacheKey(files []string, opts Options) (string, error) {
+	h := crc32.NewIEEE()
+	for _, file := range files {
+		f, err := opts.open(file)
+		if err != nil {
+			return "", err
+		}
+		io.WriteString(h, filepath.Base(file))
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	io.WriteString(h, cacheOptions(opts))
+	return strconv.FormatUint(uint64(h.Sum32()), 16), nil
+}
+
+// cacheOptions returns the options that change the output as text, which is the same between runs.
+func cacheOptions(opts Options) string {
+	// callbacks don't change the output, and their addresses change between runs
+	opts.log, opts.progress, opts.warn = nil, nil, nil
+	opts.fsys, opts.create = nil, nil
+	opts.timeout, opts.httpTimeout = 0, 0
+	// times of -since and -until only filter the input files, which are hashed with their contents,
+	// and relative ones are resolved from the current time
+	opts.since, opts.until = time.Time{}, time.Time{}
+	// pointers are written by their values
+	bgIndex, canvasAt := "nil", "nil"
+	if opts.bgIndex != nil {
+		bgIndex = strconv.Itoa(*opts.bgIndex)
+	}
+	if opts.canvasAt != nil {
+		canvasAt = opts.canvasAt.String()
+	}
+	opts.bgIndex, opts.canvasAt = nil, nil
+	return fmt.Sprintf("%#v bgIndex=%s canvasAt=%s", opts, bgIndex, canvasAt)
+}
+
+// cachePath returns the path to the file with the cache key of the output, keyed by its absolute path.
+func cachePath(out string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(out)
+	if err != nil {
+		return "", err
+	}
+	name := strconv.FormatUint(uint64(crc32.ChecksumIEEE([]byte(abs))), 16)
+	return filepath.Join(dir, "png2gif", name), nil
+}
+
+// upToDate checks if all outputs exist and were built with the same cache key.
+func upToDate(outs []string, key string) bool {
+	for _, o := range outs {
+		if _, err := os.Stat(o); err != nil {
+			return false
+		}
+		p, err := cachePath(o)
+		if err != nil {
+			return false
+		}
+		saved, err := os.ReadFile(p)
+		if err != nil || string(saved) != key {
+			return false
+		}
+	}
+	return true
+}
+
+// saveCache saves the cache key of each output.
+func saveCache(outs []string, key string) error {
+	for _, o := range outs {
+		p, err := cachePath(o)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(p, []byte(key), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	writeTestPngs(t, dir, 8, 8, testRed, testBlue)
+	out := filepath.Join(t.TempDir(), "out.gif")
+	build := func(opts Options) resultMsg {
+		t.Helper()
+		msg := gen(context.Background(), dir, out, opts, nil)().(resultMsg)
+		if msg.err != nil {
+			t.Fatal(msg.err)
+		}
+		return msg
+	}
+
+	if build(Options{cache: true}).upToDate {
+		t.Error("the first build is up to date")
+	}
+	if !build(Options{cache: true}).upToDate {
+		t.Error("the build of unchanged images isn't up to date")
+	}
+	// other options change the output
+	if build(Options{cache: true, numColors: 16}).upToDate {
+		t.Error("the build with other options is up to date")
+	}
+	// changed images change the output
+	writeTestPngs(t, dir, 8, 8, testGreen, testBlue)
+	if build(Options{cache: true, numColors: 16}).upToDate {
+		t.Error("the build of changed images is up to date")
+	}
+	// the removed output is built again
+	if err := os.Remove(out); err != nil {
+		t.Fatal(err)
+	}
+	if build(Options{cache: true, numColors: 16}).upToDate {
+		t.Error("the build of the removed output is up to date")
+	}
+}
+
+func TestCacheKeyStable(t *testing.T) {
+	keys := map[string]bool{}
+	for _, opts := range []Options{
+		{},
+		// callbacks and timeouts don't change the output
+		{timeout: time.Minute, httpTimeout: time.Second, warn: func(string) {}},
+	} {
+		key, err := cacheKey(nil, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[key] = true
+	}
+	if len(keys) != 1 {
+		t.Errorf("keys of the same output differ: %v", keys)
+	}
+	if a, b := cacheOptions(Options{}), cacheOptions(Options{numColors: 16}); a == b {
+		t.Error("options with other colors are equal")
+	}
+}
+
+func TestCacheKeyOptions(t *testing.T) {
+	// relative times are resolved when the flags are parsed, so they differ between runs
+	since := parseTestFlags(t, "-since", "2h")
+	time.Sleep(time.Millisecond)
+	if cacheOptions(since.opts) != cacheOptions(parseTestFlags(t, "-since", "2h").opts) {
+		t.Error("options of the same -since differ")
+	}
+
+	// options parsed again point to other values, keys of equal ones are the same
+	a, b := parseTestFlags(t, "-canvas", "100x80+4+6"), parseTestFlags(t, "-canvas", "100x80+4+6")
+	if cacheOptions(a.opts) != cacheOptions(b.opts) {
+		t.Error("options with equal canvas positions differ")
+	}
+	if cacheOptions(a.opts) == cacheOptions(parseTestFlags(t, "-canvas", "100x80+4+8").opts) {
+		t.Error("options with other canvas positions are equal")
+	}
+	if cacheOptions(Options{}) == cacheOptions(Options{canvasAt: &image.Point{}}) {
+		t.Error("centered frames are equal to frames at 0,0")
+	}
+
+	index := func(i int) *int { return &i }
+	if cacheOptions(Options{bgIndex: index(3)}) != cacheOptions(Options{bgIndex: index(3)}) {
+		t.Error("options with equal background indexes differ")
+	}
+	if cacheOptions(Options{bgIndex: index(3)}) == cacheOptions(Options{bgIndex: index(4)}) {
+		t.Error("options with other background indexes are equal")
+	}
+	if cacheOptions(Options{}) == cacheOptions(Options{bgIndex: index(0)}) {
+		t.Error("unset background index is equal to index 0")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+)
+
+// parseCanvas parses the canvas size in WxH format, with an optional position of frames on it in WxH+X+Y format,
+// e.g. 640x480+10+20. Returns a nil position if frames are centered.
+func parseCanvas(s string) (image.Point, *image.Point, error) {
+	var w, h, x, y int
+	if n, _ := fmt.Sscanf(strings.ToLower(s), "%dx%d+%d+%d", &w, &h, &x, &y); n == 4 && w > 0 && h > 0 && x >= 0 && y >= 0 {
+		return image.Pt(w, h), &image.Point{x, y}, nil
+	}
+	size, err := parseSize(s)
+	if err != nil || strings.Contains(s, "+") {
+		return image.Point{}, nil, fmt.Errorf("invalid canvas %q, use WxH or WxH+X+Y, e.g. 640x480 or 640x480+10+20", s)
+	}
+	return size, nil, nil
+}
+
+// placeOnCanvas moves frames to their position on the canvas, they keep their size.
+// Frames are replaced with moved copies sharing pixels, as the same frame can repeat or be encoded again.
+// The first frame is extended to the whole canvas filled with the color, so the border stays in later frames,
+// a nil color leaves the border transparent.
+func placeOnCanvas(frames []*image.Paletted, canvas image.Point, at *image.Point, fill color.Color) error {
+	if len(frames) == 0 {
+		return nil
+	}
+	bounds := image.Rectangle{}
+	for _, p := range frames {
+		bounds = bounds.Union(p.Rect)
+	}
+	size := bounds.Max
+	offset := canvas.Sub(size).Div(2)
+	if at != nil {
+		offset = *at
+	}
+	if offset.X < 0 || offset.Y < 0 || offset.X+size.X > canvas.X || offset.Y+size.Y > canvas.Y {
+		return fmt.Errorf("frames of %dx%d don't fit into the canvas of %dx%d", size.X, size.Y, canvas.X, canvas.Y)
+	}
+
+	for i, p := range frames {
+		moved := *p
+		moved.Rect = p.Rect.Add(offset)
+		frames[i] = &moved
+	}
+	if fill == nil {
+		return nil
+	}
+
+	first := frames[0]
+	pal, idx := fillPalette(first.Palette, fill)
+	dst := image.NewPaletted(image.Rect(0, 0, canvas.X, canvas.Y), pal)
+	for i := range dst.Pix {
+		dst.Pix[i] = uint8(idx)
+	}
+	// copy indices as they are, the palette of the frame is only extended
+	for y := first.Rect.Min.Y; y < first.Rect.Max.Y; y++ {
+		copy(dst.Pix[dst.PixOffset(first.Rect.Min.X, y):], first.Pix[first.PixOffset(first.Rect.Min.X, y):first.PixOffset(first.Rect.Max.X, y)])
+	}
+	frames[0] = dst
+	return nil
+}
+
+// fillPalette returns the palette with the fill color and its index,
+// a copy with the color added if there is room for it, or the same palette and the closest color if it's full.
+func fillPalette(pal color.Palette, fill color.Color) (color.Palette, int) {
+	c := color.NRGBAModel.Convert(fill)
+	for i, p := range pal {
+		if color.NRGBAModel.Convert(p) == c {
+			return pal, i
+		}
+	}
+	if len(pal) < 256 {
+		return append(append(color.Palette{}, pal...), c), len(pal)
+	}
+	return pal, pal.Index(c)
+}
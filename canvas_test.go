@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/gif"
+	"strings"
+	"testing"
+)
+
+func TestParseCanvas(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		size image.Point
+		at   *image.Point
+	}{
+		{"640x480", image.Pt(640, 480), nil},
+		{"640X480+10+20", image.Pt(640, 480), &image.Point{10, 20}},
+		{"32x32+0+0", image.Pt(32, 32), &image.Point{}},
+	} {
+		size, at, err := parseCanvas(tt.s)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.s, err)
+		}
+		if size != tt.size || (at == nil) != (tt.at == nil) || at != nil && *at != *tt.at {
+			t.Errorf("%s: got %v at %v, want %v at %v", tt.s, size, at, tt.size, tt.at)
+		}
+	}
+	for _, s := range []string{"640", "0x480", "640x480+10", "640x480+-1+0", "640x480+a+b"} {
+		if _, _, err := parseCanvas(s); err == nil {
+			t.Errorf("%s: got no error", s)
+		}
+	}
+}
+
+// encodeCanvasGif encodes 16x8 red and blue frames on the canvas of the options.
+func encodeCanvasGif(t *testing.T, opts Options) (*gif.GIF, error) {
+	t.Helper()
+	b := bytes.Buffer{}
+	if err := encodeTo(context.Background(), &b, formatGif, testFrames(16, 8, testRed, testBlue), 2, opts); err != nil {
+		return nil, err
+	}
+	return gif.DecodeAll(&b)
+}
+
+func TestCanvas(t *testing.T) {
+	for _, tt := range []struct {
+		args  []string
+		frame image.Rectangle
+	}{
+		{[]string{"-canvas", "40x20"}, image.Rect(12, 6, 28, 14)},
+		{[]string{"-canvas", "40x20+2+3"}, image.Rect(2, 3, 18, 11)},
+	} {
+		g, err := encodeCanvasGif(t, parseTestFlags(t, tt.args...).opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g.Config.Width != 40 || g.Config.Height != 20 {
+			t.Errorf("%v: got a screen of %dx%d, want 40x20", tt.args, g.Config.Width, g.Config.Height)
+		}
+		for n, p := range g.Image {
+			if p.Rect != tt.frame {
+				t.Errorf("%v: frame %d is at %v, want %v", tt.args, n, p.Rect, tt.frame)
+			}
+		}
+		if !colorsEqual(g.Image[1].At(tt.frame.Min.X, tt.frame.Min.Y), testBlue) {
+			t.Errorf("%v: pixels of the frame moved", tt.args)
+		}
+	}
+
+	// the first frame fills the canvas with the color around the frame
+	g, err := encodeCanvasGif(t, parseTestFlags(t, "-canvas", "40x20", "-canvas-color", "#00ff00").opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Image[0].Rect != image.Rect(0, 0, 40, 20) || g.Image[1].Rect != image.Rect(12, 6, 28, 14) {
+		t.Errorf("got frames at %v and %v", g.Image[0].Rect, g.Image[1].Rect)
+	}
+	if !colorsEqual(g.Image[0].At(0, 0), testGreen) || !colorsEqual(g.Image[0].At(12, 6), testRed) {
+		t.Errorf("got the border %v and the frame %v, want green and red", g.Image[0].At(0, 0), g.Image[0].At(12, 6))
+	}
+
+	if _, err := encodeCanvasGif(t, Options{canvas: image.Pt(10, 10)}); err == nil || !strings.Contains(err.Error(), "don't fit into the canvas") {
+		t.Errorf("got %v, want an error about the small canvas", err)
+	}
+	parseUsageError(t, "-canvas-color", "#000000")
+	parseUsageError(t, "-canvas", "40")
+}
@@ -0,0 +1,612 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// config is the configuration parsed from the command line flags.
+// @property {string} path - The path to the folder with images, if set the app runs without the UI.
+// @property {string} out - The path to the output file.
+// @property {bool} batch - Whether the path is a parent folder and a gif is built from each of its subfolders.
+// @property {slog.Level} logLevel - The min level of logged build events without the UI.
+// @property {bool} once - Whether the UI quits after a successful build and prints the output path.
+// @property {bool} ascii - Whether ASCII tokens are shown instead of emojis.
+// @property {theme} theme - The spinner and the accent color of the UI.
+// @property {bool} clipboard - Whether the output is copied to the clipboard after a successful build.
+// @property {Options} opts - The options to tweak the build.
+type config struct {
+	path      string
+	out       string
+	batch     bool
+	once      bool
+	ascii     bool
+	theme     theme
+	clipboard bool
+	logLevel  slog.Level
+	opts      Options
+}
+
+// exit codes, so scripts can tell wrong flags from failed builds.
+const (
+	exitError = 1
+	exitUsage = 2
+)
+
+// usageError is an error in flags or arguments of the command line.
+// @property {error} err - The cause of the error.
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *usageError) Unwrap() error {
+	return e.err
+}
+
+// exitCode returns the exit code of the error, usage errors exit with 2 and the rest with 1.
+func exitCode(err error) int {
+	var ue *usageError
+	if errors.As(err, &ue) {
+		return exitUsage
+	}
+	return exitError
+}
+
+// parseFlags parses command line arguments into the config.
+func parseFlags(args []string) (config, error) {
+	cfg := config{}
+	fs := flag.NewFlagSet("png2gif", flag.ContinueOnError)
+	fs.StringVar(&cfg.path, "path", "", "path to the folder with images, a manifest file, an animated webp or gif, a pdf or a video, runs without UI if set")
+	fs.StringVar(&cfg.out, "out", defaultOutput, "path to the output file, out.gif is written into it if it's a directory;\ncomma separated paths write several formats by extension: .gif, .png or .apng (animated png), .webm (needs ffmpeg)")
+	fs.Var(fpsValue{&cfg.opts.fps, &cfg.opts.autoFps}, "fps", fmt.Sprintf("frame rate of the gif, %d if it's not set, or auto to detect it from modification times of images", defaultFps))
+	fs.StringVar(&cfg.opts.format, "format", "", "format of all outputs: gif, apng, webm or png-sequence (numbered pngs of frames in the -out folder), by the extensions of -out by default")
+	fs.StringVar(&cfg.opts.compare, "compare", compareRGB, "mode to compare frames: rgb or alpha (includes transparency)")
+	fs.StringVar(&cfg.opts.keep, "keep", keepFirst, "frame kept from equal frames in a row: first or last")
+	fs.StringVar(&cfg.opts.dedup, "dedup", dedupNormal, "preset of thresholds to merge equal frames: strict, normal or loose")
+	fs.IntVar(&cfg.opts.dedupWindow, "dedup-window", 1, "number of previous frames an image is compared with, so it merges back into one after a brief change and frames in between are dropped")
+	fs.Float64Var(&cfg.opts.thresholds.Prop, "threshold-prop", 0, "max difference of proportions of equal frames, overrides the -dedup preset if not 0")
+	fs.Float64Var(&cfg.opts.thresholds.Y, "threshold-y", 0, "max distance of brightness (Y) of equal frames, overrides the -dedup preset if not 0")
+	fs.Float64Var(&cfg.opts.thresholds.CbCr, "threshold-cbcr", 0, "max distance of colors (Cb and Cr) of equal frames, overrides the -dedup preset if not 0")
+	fs.BoolVar(&cfg.batch, "batch", false, "build a gif from each subfolder of -path, named after the subfolder and written next to it or into the -out folder")
+	fs.StringVar(&cfg.opts.onGap, "on-gap", gapIgnore, "what to do with missing numbers in file names of frames: ignore, error, warn or hold (the previous frame holds for missing ones)")
+	fs.TextVar(&cfg.logLevel, "log-level", slog.LevelInfo, "min level of logged build events without the UI: debug, info, warn or error")
+	fs.BoolVar(&cfg.ascii, "ascii", false, "show ASCII tokens like [done] instead of emojis, for terminals without emoji fonts")
+	spinnerName := fs.String("spinner", envOr(envSpinner, defaultSpinner), "spinner of the UI: line, dot, minidot, jump, pulse, points, globe, moon, monkey, meter or hamburger, also set by $"+envSpinner)
+	accent := fs.String("color", envOr(envColor, string(hotPink)), "accent color of the UI as #RRGGBB or an ANSI color from 0 to 255, also set by $"+envColor)
+	fs.BoolVar(&cfg.clipboard, "clipboard", false, "copy the gif to the clipboard after the build, or its path where images can't be copied (pbcopy, clip, wl-copy, xclip or xsel)")
+	fs.BoolVar(&cfg.once, "once", false, "quit the UI after a successful build and print the path to the output")
+	fs.BoolVar(&cfg.opts.keepEndpoints, "keep-endpoints", false, "keep the first and the last images as separate frames, even if they are equal to their neighbors")
+	fs.BoolVar(&cfg.opts.deflicker, "deflicker", false, "drop single frames that differ from both neighbors while the neighbors are equal, like blank frames of screen recordings")
+	fs.BoolVar(&cfg.opts.noDedup, "no-dedup", false, "keep all frames, even if they are equal")
+	fs.BoolVar(&cfg.opts.preserveTiming, "preserve-timing", false, "keep delays of frames of an animated gif -path instead of playing them at -fps")
+	fs.BoolVar(&cfg.opts.trimStatic, "trim-static", false, "trim still frames at the start and the end, so the animation starts and ends on motion")
+	fs.BoolVar(&cfg.opts.dropBlank, "drop-blank", false, "drop images of a single color, like accidental exports of an empty canvas")
+	fs.BoolVar(&cfg.opts.allowStatic, "allow-static", false, "build a single-frame gif without a warning when all images are equal, even below -min-frames")
+	fs.IntVar(&cfg.opts.firstHold, "first-hold", 0, "delay of the first frame in 100ths of a second, 0 to use the frame rate")
+	fs.IntVar(&cfg.opts.lastHold, "last-hold", 0, "delay of the last frame in 100ths of a second, 0 to use the frame rate")
+	fs.Float64Var(&cfg.opts.speed, "speed", 1, "factor the animation plays faster by, e.g. 2 halves delays and 0.5 doubles them, holds are kept")
+	fs.IntVar(&cfg.opts.delayMs, "delay-ms", 0, "delay of each image in milliseconds instead of -fps, e.g. 40, rounded to 100ths of a second without drifting over the animation")
+	fs.BoolVar(&cfg.opts.uniformDelay, "uniform-delay", false, "show every frame for the same time at the frame rate, merged equal images don't make frames longer")
+	fs.IntVar(&cfg.opts.maxDelay, "max-delay", 0, "max delay of a frame in 100ths of a second, longer frames are split into repeated ones for players that don't handle long delays, 0 for no limit")
+	fs.BoolVar(&cfg.opts.fromVideo, "frames-from-video", false, "extract frames from the video passed as -path with ffmpeg, videos are detected by extension otherwise")
+	fs.IntVar(&cfg.opts.sample, "sample", 1, "keep every Nth image and hold it N times longer to preserve timing")
+	fs.IntVar(&cfg.opts.minFrames, "min-frames", 2, "min number of frames left after merging equal images, fewer is an error as it's likely a wrong folder")
+	fs.StringVar(&cfg.opts.sort, "sort", sortName, "order of images in the folder: name, created (by modification time), exif (by capture time of jpeg photos) or natural (by a number in names)")
+	fs.StringVar(&cfg.opts.template, "template", "", "printf-style file name of numbered frames in -path, e.g. frame%04d.png, frames are taken by numbers from -start to -end instead of listing the folder")
+	fs.IntVar(&cfg.opts.templateStart, "start", 1, "number of the first frame of -template")
+	fs.IntVar(&cfg.opts.templateEnd, "end", 0, "number of the last frame of -template, 0 to take frames until the first missing one")
+	fs.BoolVar(&cfg.opts.sortDesc, "sort-desc", false, "reverse the order of images in the folder, works with any -sort mode, e.g. natural for a countdown")
+	sortNumber := fs.String("sort-number", sortNumberLast, "which number in file names natural sort uses: first, last or a position from 1, e.g. 2")
+	fs.BoolVar(&cfg.opts.stream, "stream", false, "write gif frames one by one instead of encoding the whole gif in memory")
+	fs.BoolVar(&cfg.opts.appendOutput, "append", false, "append frames to the existing gif at -out instead of overwriting it, its delays and loop count are kept")
+	fs.BoolVar(&cfg.opts.optimizeStatic, "optimize-static", false, "write only the changed area of gif frames after the first one, smaller files for animations over a static background")
+	fs.BoolVar(&cfg.opts.commentFps, "comment-fps", false, "record the frame rate in a comment of the gif, e.g. png2gif fps=30, for editors and other tools")
+	fs.BoolVar(&cfg.opts.stripMetadata, "strip-metadata", false, "leave comments, text chunks and other metadata out of the output, only data needed to play it is written")
+	fs.BoolVar(&cfg.opts.interlace, "interlace", false, "interlace gif frames, so they show progressively while loading")
+	fs.IntVar(&cfg.opts.jobs, "jobs", 0, "number of CPUs to use, 0 for the available ones up to -max-jobs")
+	fs.IntVar(&cfg.opts.maxJobs, "max-jobs", defaultMaxJobs, "max number of CPUs used by default, so builds on shared machines leave room for others")
+	fs.IntVar(&cfg.opts.threadsIO, "threads-io", 0, "max number of images decoded at once, 0 for twice the number of CPUs used")
+	fs.IntVar(&cfg.opts.threadsEncode, "threads-encode", 0, "max number of frames encoded at once, 0 for the number of CPUs used")
+	fs.StringVar(&cfg.opts.quantizer, "quantizer", quantizerDefault, "algorithm to build palettes of gif frames: default (plan9 palette), kmeans, median-cut or octree")
+	fs.BoolVar(&cfg.opts.linear, "linear", false, "resize and blend frames, and build palettes, in linear light, so gradients and fine details don't get darker")
+	fs.Int64Var(&cfg.opts.seed, "seed", 0, "seed of the quantizer random generator, the same seed gives the same palettes")
+	fs.StringVar(&cfg.opts.paletteMode, "palette-mode", paletteModePerFrame, "palettes of gif frames: per-frame (best quality), global (one from all frames written once, smaller) or shared-sampled (one from sampled frames)")
+	globalPalette := fs.Bool("global-palette", false, "same as -palette-mode shared-sampled")
+	fs.BoolVar(&cfg.opts.overlayFrameNumber, "overlay-frame-number", false, "draw the index of the source image in the corner of each frame")
+	annotations := fs.String("annotations", "", "json file with captions drawn on frames by the index of the source image from 0 or its file name")
+	fs.BoolVar(&cfg.opts.overlayFilename, "overlay-filename", false, "draw the file name of the source image in the corner of each frame")
+	fs.IntVar(&cfg.opts.numColors, "colors", 256, "max number of colors in palettes of gif frames, from 2 to 256")
+	fs.IntVar(&cfg.opts.targetSize, "target-size", 0, "max size of the gif in bytes, colors, size and frames are reduced to fit, 0 for no limit")
+	fs.IntVar(&cfg.opts.blend, "blend", 0, "number of crossfaded frames inserted between consecutive frames for smoother motion, 0 for none")
+	fs.StringVar(&cfg.opts.reportPath, "report", "", "path of a csv report of output frames: source files, delay, size and similarity to the previous frame")
+	fs.BoolVar(&cfg.opts.verify, "verify", false, "decode written gifs again to check they are valid and have all frames, broken ones are removed")
+	fs.BoolVar(&cfg.opts.outInInput, "out-in-input", false, "write outputs given without a folder, or the default out.gif, into the input folder instead of the working one")
+	fs.BoolVar(&cfg.opts.cache, "cache", false, "skip the build if images and options didn't change since the last build of the output")
+	fs.DurationVar(&cfg.opts.httpTimeout, "http-timeout", defaultURLTimeout, "max duration of fetching an image by its http URL")
+	fs.DurationVar(&cfg.opts.timeout, "timeout", 0, "max duration of the build, e.g. 2m, the partial output is removed if it's exceeded, 0 for no limit")
+	size := fs.String("size", "", "size of frames, e.g. 640x480, images are scaled to fit keeping the aspect ratio and padded")
+	scale := fs.String("scale", "", "size of frames as a percentage of the size of images, e.g. 50%")
+	bgIndex := fs.Int("bg-index", -1, "background color index in the global palette of the gif, written with the palette of the first frame as the global one")
+	bgColor := fs.String("bg-color", "", "background color of the gif, e.g. #000000, the index of the closest color in the global palette is written")
+	canvas := fs.String("canvas", "", "logical screen size of the gif as WxH, frames keep their size and are centered on it, or placed at X,Y with WxH+X+Y")
+	canvasColor := fs.String("canvas-color", "", "color of the -canvas around frames, e.g. #000000, transparent by default")
+	padColor := fs.String("pad-color", "", "color of padding around images scaled with -size, e.g. #000000, transparent by default (black in gifs with the default palette)")
+	palette := fs.String("palette", "", "fixed palette for all frames: comma separated hex colors, e.g. #1d3557,#f1faee, or a gray ramp gray2 to gray256")
+	dither := fs.Float64("dither-strength", 1, "part of the color error diffused to neighbor pixels of gif frames, from 0 (no dithering, banding) to 1 (full dithering, noise)")
+	compression := fs.Int("compression", 6, "compression level of animated png from 0 (none, fastest) to 9 (smallest)")
+	autoDownscale := fs.Bool("auto-downscale", false, fmt.Sprintf("scale frames larger than %dpx down to fit, some players choke on huge gifs", autoDownscaleSize))
+	lossless := fs.Bool("lossless", false, "keep all frames and use exact colors of each frame when possible (-no-dedup with exact palettes)")
+	since := fs.String("since", "", "skip images in the folder modified before the time, e.g. 2024-01-01, 2024-01-01 15:04 or 2h for 2 hours ago")
+	until := fs.String("until", "", "skip images in the folder modified at or after the time, in the formats of -since")
+	exts := fs.String("ext", "", "comma separated extensions of files listed as images, e.g. .png,.jpg,.jpeg, the ones of built-in formats by default")
+	stdin := fs.Bool("stdin", false, "read the list of images from the standard input, one path, glob or http URL per line, like a manifest")
+	configPath := fs.String("config", "", "path to a yaml or json file with default values of flags, .png2gif.yaml, .png2gif.yml or .png2gif.json in the working directory is used if not set")
+
+	// errors are printed by the caller in one line, only the help prints the usage
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fs.SetOutput(os.Stderr)
+			fs.Usage()
+			return cfg, err
+		}
+		return cfg, &usageError{err}
+	}
+
+	// flags passed on the command line override the ones from the config file
+	if *configPath == "" {
+		*configPath = findConfigFile()
+	}
+	if *configPath != "" {
+		if err := applyConfigFile(fs, *configPath); err != nil {
+			return cfg, &usageError{err}
+		}
+	}
+
+	if cfg.opts.format != "" {
+		if _, err := outputFormat("", cfg.opts.format); err != nil {
+			return cfg, &usageError{err}
+		}
+	}
+	if cfg.opts.format == formatPngSequence {
+		if cfg.batch {
+			return cfg, &usageError{fmt.Errorf("-format png-sequence can't be used with -batch")}
+		}
+		// the default out.gif would be a strange name of a folder
+		if cfg.out == defaultOutput {
+			cfg.out = defaultSequenceOutput
+		}
+	}
+
+	if *palette != "" {
+		p, err := parsePalette(*palette)
+		if err != nil {
+			return cfg, &usageError{err}
+		}
+		cfg.opts.palette = p
+	}
+
+	if *size != "" {
+		s, err := parseSize(*size)
+		if err != nil {
+			return cfg, &usageError{err}
+		}
+		cfg.opts.size = s
+	}
+
+	if *scale != "" {
+		s, err := parseScale(*scale)
+		if err != nil {
+			return cfg, &usageError{err}
+		}
+		cfg.opts.scale = s
+	}
+
+	if *bgIndex >= 0 && *bgColor != "" {
+		return cfg, &usageError{fmt.Errorf("-bg-index and -bg-color can't be used together")}
+	}
+	if *bgIndex > 255 {
+		return cfg, &usageError{fmt.Errorf("background index should be from 0 to 255, got %d", *bgIndex)}
+	}
+	if *bgIndex >= 0 {
+		cfg.opts.bgIndex = bgIndex
+	}
+	if *bgColor != "" {
+		c, err := parseHexColor(*bgColor)
+		if err != nil {
+			return cfg, &usageError{err}
+		}
+		cfg.opts.bgColor = c
+	}
+	if (cfg.opts.bgIndex != nil || cfg.opts.bgColor != nil) && (cfg.opts.stream || cfg.opts.interlace) {
+		return cfg, &usageError{fmt.Errorf("a background of the gif can't be set with -stream or -interlace, streamed gifs have no global palette")}
+	}
+
+	now := time.Now()
+	if *since != "" {
+		t, err := parseTime(*since, now)
+		if err != nil {
+			return cfg, &usageError{err}
+		}
+		cfg.opts.since = t
+	}
+	if *until != "" {
+		t, err := parseTime(*until, now)
+		if err != nil {
+			return cfg, &usageError{err}
+		}
+		cfg.opts.until = t
+	}
+	if !cfg.opts.since.IsZero() && !cfg.opts.until.IsZero() && !cfg.opts.since.Before(cfg.opts.until) {
+		return cfg, &usageError{fmt.Errorf("-since should be before -until")}
+	}
+
+	if *exts != "" {
+		e, err := parseExts(*exts)
+		if err != nil {
+			return cfg, &usageError{err}
+		}
+		cfg.opts.exts = e
+	}
+
+	if *annotations != "" {
+		a, err := readAnnotations(*annotations)
+		if err != nil {
+			return cfg, &usageError{err}
+		}
+		cfg.opts.annotations = a
+	}
+
+	if *canvas != "" {
+		size, at, err := parseCanvas(*canvas)
+		if err != nil {
+			return cfg, &usageError{err}
+		}
+		cfg.opts.canvas, cfg.opts.canvasAt = size, at
+	}
+
+	if *canvasColor != "" {
+		if *canvas == "" {
+			return cfg, &usageError{fmt.Errorf("-canvas-color needs -canvas")}
+		}
+		c, err := parseHexColor(*canvasColor)
+		if err != nil {
+			return cfg, &usageError{err}
+		}
+		cfg.opts.canvasColor = c
+	}
+
+	if *padColor != "" {
+		c, err := parseHexColor(*padColor)
+		if err != nil {
+			return cfg, &usageError{err}
+		}
+		cfg.opts.padColor = c
+	}
+
+	// 0 means the default full dithering in options, so no dithering is -1 there
+	cfg.opts.ditherStrength = *dither
+	if *dither == 0 {
+		cfg.opts.ditherStrength = -1
+	}
+
+	// 0 means the default level in options, so no compression is -1 there
+	cfg.opts.compression = *compression
+	if *compression == 0 {
+		cfg.opts.compression = -1
+	}
+
+	if *stdin {
+		if cfg.path != "" {
+			return cfg, &usageError{fmt.Errorf("-stdin and -path can't be used together")}
+		}
+		cfg.path = stdinPath
+	}
+
+	th, err := parseTheme(*spinnerName, *accent)
+	if err != nil {
+		return cfg, &usageError{err}
+	}
+	cfg.theme = th
+
+	n, err := parseSortNumber(*sortNumber)
+	if err != nil {
+		return cfg, &usageError{err}
+	}
+	cfg.opts.sortNumber = n
+
+	if *globalPalette {
+		cfg.opts.paletteMode = paletteModeSampled
+	}
+
+	if *autoDownscale {
+		cfg.opts.maxDimension = autoDownscaleSize
+	}
+
+	if *lossless {
+		cfg.opts.noDedup = true
+		cfg.opts.exactPalette = true
+	}
+
+	if err := validateConfig(cfg); err != nil {
+		return cfg, &usageError{err}
+	}
+	return cfg, nil
+}
+
+// validateConfig checks that the values of flags are valid.
+func validateConfig(cfg config) error {
+	if _, err := normalizeFps(cfg.opts.fps); err != nil {
+		return err
+	}
+	if cfg.opts.compare != compareRGB && cfg.opts.compare != compareAlpha {
+		return fmt.Errorf("invalid compare mode: %s", cfg.opts.compare)
+	}
+	if cfg.opts.keep != keepFirst && cfg.opts.keep != keepLast {
+		return fmt.Errorf("invalid keep mode: %s", cfg.opts.keep)
+	}
+	if _, ok := dedupPresets[cfg.opts.dedup]; !ok {
+		return fmt.Errorf("invalid dedup preset: %s", cfg.opts.dedup)
+	}
+	th := cfg.opts.thresholds
+	if th.Prop < 0 || th.Y < 0 || th.CbCr < 0 {
+		return fmt.Errorf("thresholds should not be negative")
+	}
+	if cfg.opts.maxDelay < 0 {
+		return fmt.Errorf("max delay should not be negative")
+	}
+	if cfg.opts.firstHold < 0 || cfg.opts.lastHold < 0 {
+		return fmt.Errorf("hold durations should not be negative")
+	}
+	if cfg.opts.sort != sortName && cfg.opts.sort != sortCreated && cfg.opts.sort != sortExif && cfg.opts.sort != sortNatural {
+		return fmt.Errorf("invalid sort mode: %s", cfg.opts.sort)
+	}
+	switch cfg.opts.onGap {
+	case gapIgnore, gapError, gapWarn, gapHold:
+	default:
+		return fmt.Errorf("invalid on-gap mode: %s", cfg.opts.onGap)
+	}
+	if cfg.opts.minFrames < 0 {
+		return fmt.Errorf("min frames should not be negative")
+	}
+	if cfg.opts.sample < 1 {
+		return fmt.Errorf("sample should be at least 1")
+	}
+	switch cfg.opts.quantizer {
+	case quantizerDefault, quantizerKmeans, quantizerMedianCut, quantizerOctree:
+	default:
+		return fmt.Errorf("invalid quantizer: %s", cfg.opts.quantizer)
+	}
+	switch cfg.opts.paletteMode {
+	case paletteModePerFrame, paletteModeGlobal, paletteModeSampled:
+	default:
+		return fmt.Errorf("invalid palette mode: %s", cfg.opts.paletteMode)
+	}
+	// -lossless sets exact palettes, which other palettes and fewer colors would change
+	if cfg.opts.exactPalette {
+		switch {
+		case cfg.opts.paletteMode != paletteModePerFrame:
+			return fmt.Errorf("-lossless can't be used with -global-palette or -palette-mode %s, frames keep palettes of their own colors", cfg.opts.paletteMode)
+		case cfg.opts.palette != nil:
+			return fmt.Errorf("-lossless can't be used with -palette, frames keep palettes of their own colors")
+		case cfg.opts.numColors != 256:
+			return fmt.Errorf("-lossless can't be used with -colors, frames keep all of their colors")
+		}
+	}
+	if cfg.opts.paletteMode == paletteModeGlobal && (cfg.opts.stream || cfg.opts.interlace) {
+		return fmt.Errorf("-palette-mode global can't be used with -stream or -interlace, streamed gifs have no global palette, use shared-sampled instead")
+	}
+	if cfg.opts.numColors < 2 || cfg.opts.numColors > 256 {
+		return fmt.Errorf("number of colors should be from 2 to 256")
+	}
+	if cfg.opts.scale > 0 && cfg.opts.size != (image.Point{}) {
+		return fmt.Errorf("-scale and -size can't be used together")
+	}
+	if cfg.opts.timeout < 0 {
+		return fmt.Errorf("timeout should not be negative")
+	}
+	if cfg.opts.ditherStrength != -1 && (cfg.opts.ditherStrength < 0 || cfg.opts.ditherStrength > 1) {
+		return fmt.Errorf("dither strength should be from 0 to 1, got %g", cfg.opts.ditherStrength)
+	}
+	if cfg.opts.httpTimeout < 0 {
+		return fmt.Errorf("http timeout should not be negative")
+	}
+	if cfg.opts.template != "" {
+		if err := validateTemplate(cfg.opts.template); err != nil {
+			return err
+		}
+		if cfg.opts.templateStart < 0 {
+			return fmt.Errorf("start of the template should not be negative")
+		}
+		if cfg.opts.templateEnd != 0 && cfg.opts.templateEnd < cfg.opts.templateStart {
+			return fmt.Errorf("end of the template should not be before its start")
+		}
+	}
+	if cfg.opts.speed <= 0 {
+		return fmt.Errorf("speed should be positive, got %g", cfg.opts.speed)
+	}
+	if cfg.opts.delayMs < 0 {
+		return fmt.Errorf("delay should not be negative")
+	}
+	if cfg.opts.dedupWindow < 0 {
+		return fmt.Errorf("dedup window should not be negative")
+	}
+	if cfg.opts.blend < 0 {
+		return fmt.Errorf("number of blended frames should not be negative")
+	}
+	if cfg.opts.targetSize < 0 {
+		return fmt.Errorf("target size should not be negative")
+	}
+	if cfg.opts.compression > 9 || cfg.opts.compression < -1 {
+		return fmt.Errorf("compression level should be from 0 to 9")
+	}
+	if cfg.opts.threadsIO < 0 || cfg.opts.threadsEncode < 0 {
+		return fmt.Errorf("number of threads should not be negative")
+	}
+	if cfg.opts.jobs < 0 || cfg.opts.maxJobs < 1 {
+		return fmt.Errorf("jobs should not be negative and max jobs should be at least 1")
+	}
+	if cfg.opts.stripMetadata && cfg.opts.commentFps {
+		return fmt.Errorf("-strip-metadata can't be used with -comment-fps, the comment is metadata")
+	}
+	return nil
+}
+
+// runHeadless builds the gif without the UI and prints the result.
+func runHeadless(cfg config) error {
+	cfg.opts.log = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: cfg.logLevel,
+		// the time is noise for a single run
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	if cfg.batch {
+		return runBatch(cfg)
+	}
+	res, _ := gen(context.Background(), cfg.path, cfg.out, cfg.opts, nil)().(resultMsg)
+	if res.err != nil {
+		return res.err
+	}
+	for _, w := range res.warnings {
+		fmt.Fprintf(os.Stderr, "%s %s\n", indicator(emojiWarning, cfg.ascii), w)
+	}
+
+	for _, o := range res.outputs {
+		outPath, _ := filepath.Abs(o)
+		if res.upToDate {
+			fmt.Printf("%s %s (up to date)\n", indicator(res.emoji, cfg.ascii), outPath)
+			continue
+		}
+		size := ""
+		if fi, err := os.Stat(o); err == nil && fi.Mode().IsRegular() {
+			size = ", " + formatBytes(fi.Size())
+		}
+		fmt.Printf("%s %s (%s%s)\n", indicator(res.emoji, cfg.ascii), outPath, res.duration.Round(time.Millisecond), size)
+	}
+	if cfg.clipboard && len(res.outputs) > 0 {
+		copyOutput(res.outputs[0], cfg.ascii)
+	}
+	return nil
+}
+
+// copyOutput copies the output to the clipboard and prints what was copied,
+// the build has succeeded, so a failure is only a warning.
+func copyOutput(path string, ascii bool) {
+	image, err := copyToClipboard(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", indicator(emojiWarning, ascii), err)
+		return
+	}
+	what := "path"
+	if image {
+		what = "gif"
+	}
+	fmt.Printf("%s %s copied to the clipboard\n", indicator("📋", ascii), what)
+}
+
+// formatBytes formats a file size in bytes with a binary unit, e.g. 1.5 MiB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// runList prints images of the path in the order they are used, after sorting and sampling.
+func runList(cfg config, w io.Writer) error {
+	if cfg.path == "" {
+		return &usageError{fmt.Errorf("list: -path is required")}
+	}
+	if cfg.opts.fromVideo || isVideo(cfg.path) || isWebp(cfg.path) || isGif(cfg.path) || isPdf(cfg.path) {
+		return fmt.Errorf("list: frames of videos, webp, gif and pdf files can't be listed")
+	}
+	files, err := listFiles(cfg.path, cfg.opts)
+	if err != nil {
+		return err
+	}
+	paths, _ := sampleFiles(*files, cfg.opts.sample)
+	for _, p := range paths {
+		if _, err := fmt.Fprintln(w, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runBatch builds a gif from each subfolder of the path one by one and prints the result of each.
+func runBatch(cfg config) error {
+	entries, err := os.ReadDir(cfg.path)
+	if err != nil {
+		return err
+	}
+
+	// outputs are written into the -out folder if it exists, next to subfolders in formats of -out otherwise
+	outDir, exts := cfg.path, []string{}
+	for _, o := range splitOutputs(cfg.out) {
+		exts = append(exts, filepath.Ext(o))
+	}
+	if fi, err := os.Stat(cfg.out); err == nil && fi.IsDir() {
+		outDir, exts = cfg.out, []string{filepath.Ext(defaultOutput)}
+	}
+
+	// with -out-in-input each gif is written into its subfolder, paths of outputs are resolved here
+	inInput := cfg.opts.outInInput && outDir == cfg.path
+	opts := cfg.opts
+	opts.outInInput = false
+
+	failed := 0
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		folder := filepath.Join(cfg.path, e.Name())
+		dir := outDir
+		if inInput {
+			dir = folder
+		}
+		outs := make([]string, len(exts))
+		for i, ext := range exts {
+			outs[i] = filepath.Join(dir, e.Name()+ext)
+		}
+		res, _ := gen(context.Background(), folder, strings.Join(outs, ","), opts, nil)().(resultMsg)
+		if res.err != nil {
+			failed++
+			fmt.Printf("%s %s: %v\n", indicator(res.emoji, cfg.ascii), folder, res.err)
+			continue
+		}
+		for _, w := range res.warnings {
+			fmt.Fprintf(os.Stderr, "%s %s: %s\n", indicator(emojiWarning, cfg.ascii), folder, w)
+		}
+		for _, o := range res.outputs {
+			outPath, _ := filepath.Abs(o)
+			fmt.Printf("%s %s (%s)\n", indicator(res.emoji, cfg.ascii), outPath, res.duration.Round(time.Millisecond))
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to build %d gif(s)", failed)
+	}
+	return nil
+}
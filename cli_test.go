@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// parseUsageError parses the args and fails the test if they aren't rejected as a usage error.
+func parseUsageError(t *testing.T, args ...string) {
+	t.Helper()
+	_, err := parseFlags(args)
+	if _, ok := err.(*usageError); !ok {
+		t.Errorf("%v: got %v, want a usage error", args, err)
+	}
+}
+
+func TestParseKeep(t *testing.T) {
+	cfg, err := parseFlags([]string{"-keep", "last"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.opts.keep != keepLast {
+		t.Errorf("got keep %q, want %q", cfg.opts.keep, keepLast)
+	}
+	parseUsageError(t, "-keep", "median")
+}
+
+func TestRunList(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"frame10.png", "frame2.png", "frame1.png", "frame3.png", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tt := range []struct {
+		args []string
+		want []string
+	}{
+		{nil, []string{"frame1.png", "frame10.png", "frame2.png", "frame3.png"}},
+		{[]string{"-sort", "natural"}, []string{"frame1.png", "frame2.png", "frame3.png", "frame10.png"}},
+		{[]string{"-sort", "natural", "-sort-desc"}, []string{"frame10.png", "frame3.png", "frame2.png", "frame1.png"}},
+		{[]string{"-sort", "natural", "-sample", "2"}, []string{"frame1.png", "frame3.png"}},
+	} {
+		cfg, err := parseFlags(append([]string{"-path", dir}, tt.args...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := strings.Builder{}
+		if err := runList(cfg, &b); err != nil {
+			t.Fatal(err)
+		}
+		want := ""
+		for _, name := range tt.want {
+			want += filepath.Join(dir, name) + "\n"
+		}
+		if b.String() != want {
+			t.Errorf("%v: got\n%s\nwant\n%s", tt.args, b.String(), want)
+		}
+	}
+
+	if err := runList(config{}, io.Discard); err == nil {
+		t.Error("got no error without a path")
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	parent := t.TempDir()
+	for _, name := range []string{"walk", "jump"} {
+		if err := os.Mkdir(filepath.Join(parent, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTestPngs(t, filepath.Join(parent, "walk"), 8, 8, testRed, testGreen)
+	writeTestPngs(t, filepath.Join(parent, "jump"), 8, 8, testRed, testGreen, testBlue)
+	if err := os.WriteFile(filepath.Join(parent, "notes.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := parseTestFlags(t, "-path", parent, "-batch")
+	if err := runBatch(cfg); err != nil {
+		t.Fatal(err)
+	}
+	for name, frames := range map[string]int{"walk.gif": 2, "jump.gif": 3} {
+		if n := len(decodeTestGif(t, filepath.Join(parent, name)).Image); n != frames {
+			t.Errorf("%s: got %d frames, want %d", name, n, frames)
+		}
+	}
+
+	// outputs go into the -out folder if it exists
+	out := t.TempDir()
+	if err := runBatch(parseTestFlags(t, "-path", parent, "-batch", "-out", out)); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Name() != "jump.gif" || entries[1].Name() != "walk.gif" {
+		t.Errorf("got %v in the -out folder, want jump.gif and walk.gif", entries)
+	}
+
+	// a folder without images fails, the others are still built
+	if err := os.Mkdir(filepath.Join(parent, "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(filepath.Join(parent, "walk.gif"))
+	if err := runBatch(cfg); err == nil || err.Error() != "failed to build 1 gif(s)" {
+		t.Errorf("got %v, want 1 failed gif", err)
+	}
+	if _, err := os.Stat(filepath.Join(parent, "walk.gif")); err != nil {
+		t.Error("walk.gif isn't built after the failed folder")
+	}
+}
+
+func TestRunSummary(t *testing.T) {
+	dir := t.TempDir()
+	a, b, c := testPattern(32, 32, 0), testPattern(32, 32, 60), testPattern(32, 32, 120)
+	writeTestImages(t, dir, a, a, a, b, c, c)
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "frame 1: 3 image(s): 0001.png, 0002.png, 0003.png\n" +
+			"frame 2: 1 image(s): 0004.png\n" +
+			"frame 3: 2 image(s): 0005.png, 0006.png\n" +
+			"6 image(s) in 3 unique frame(s)\n"},
+		{[]string{"-no-dedup", "-sample", "2"}, "frame 1: 1 image(s): 0001.png\n" +
+			"frame 2: 1 image(s): 0003.png\n" +
+			"frame 3: 1 image(s): 0005.png\n" +
+			"3 image(s) in 3 unique frame(s)\n"},
+	} {
+		b := strings.Builder{}
+		if err := runSummary(parseTestFlags(t, append([]string{"-path", dir}, tt.args...)...), &b); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("%v: got\n%s\nwant\n%s", tt.args, b.String(), tt.want)
+		}
+	}
+
+	for _, args := range [][]string{nil, {"-path", "clip.mp4"}} {
+		if err := runSummary(parseTestFlags(t, args...), io.Discard); !errors.As(err, new(*usageError)) {
+			t.Errorf("%v: got %v, want a usage error", args, err)
+		}
+	}
+	if err := runSummary(parseTestFlags(t, "-path", t.TempDir()), io.Discard); err == nil || !strings.Contains(err.Error(), "no images found") {
+		t.Errorf("got %v, want an error about no images", err)
+	}
+	if _, code := runMain(t, "summary"); code != exitUsage {
+		t.Errorf("got exit code %d without -path, want %d", code, exitUsage)
+	}
+}
+
+// runMain runs main with the args in a subprocess of the test binary, and returns its stderr and exit code.
+func runMain(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestMainProcess$")
+	cmd.Env = append(os.Environ(), "PNG2GIF_TEST_ARGS="+strings.Join(args, "\n"))
+	stderr := strings.Builder{}
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		return stderr.String(), exit.ExitCode()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stderr.String(), 0
+}
+
+// TestMainProcess runs main with the args of runMain, it does nothing in a normal test run.
+func TestMainProcess(t *testing.T) {
+	args, ok := os.LookupEnv("PNG2GIF_TEST_ARGS")
+	if !ok {
+		return
+	}
+	os.Args = append([]string{"png2gif"}, strings.Split(args, "\n")...)
+	main()
+	os.Exit(0)
+}
+
+func TestExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPngs(t, dir, 8, 8, testRed, testBlue)
+	empty := t.TempDir()
+	for _, tt := range []struct {
+		name   string
+		args   []string
+		code   int
+		stderr string
+	}{
+		{"unknown flag", []string{"-path", dir, "-colour", "red"}, exitUsage, "png2gif: flag provided but not defined: -colour\n"},
+		{"invalid value", []string{"-path", dir, "-keep", "median"}, exitUsage, "png2gif: "},
+		{"no images", []string{"-path", empty, "-out", filepath.Join(empty, "out.gif")}, exitError, "png2gif: "},
+		{"success", []string{"-path", dir, "-out", filepath.Join(t.TempDir(), "out.gif")}, 0, ""},
+		{"list usage", []string{"list"}, exitUsage, "png2gif: list: -path is required\n"},
+	} {
+		stderr, code := runMain(t, tt.args...)
+		if code != tt.code {
+			t.Errorf("%s: got exit code %d, want %d, stderr:\n%s", tt.name, code, tt.code, stderr)
+		}
+		if !strings.HasPrefix(stderr, tt.stderr) || (tt.code != 0 && strings.Count(stderr, "\n") != 1) {
+			t.Errorf("%s: got stderr %q, want one line starting with %q", tt.name, stderr, tt.stderr)
+		}
+	}
+}
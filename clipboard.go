@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// clipboardTool is a command that copies its standard input to the clipboard.
+// @property {[]string} args - The command and its arguments.
+// @property {bool} image - Whether the command copies the gif itself, otherwise its path is copied as text.
+type clipboardTool struct {
+	args  []string
+	image bool
+}
+
+// clipboardTools returns the clipboard commands of the OS in the order they are tried,
+// the ones that copy image data come first where the OS has them. wayland is whether it's a Wayland session.
+func clipboardTools(goos string, wayland bool) []clipboardTool {
+	switch goos {
+	case "darwin":
+		return []clipboardTool{{[]string{"pbcopy"}, false}}
+	case "windows":
+		return []clipboardTool{{[]string{"clip"}, false}}
+	}
+	if wayland {
+		return []clipboardTool{
+			{[]string{"wl-copy", "--type", "image/gif"}, true},
+			{[]string{"wl-copy"}, false},
+		}
+	}
+	return []clipboardTool{
+		{[]string{"xclip", "-selection", "clipboard", "-t", "image/gif", "-i"}, true},
+		{[]string{"xclip", "-selection", "clipboard", "-i"}, false},
+		{[]string{"xsel", "--clipboard", "--input"}, false},
+	}
+}
+
+// findClipboardTool returns the first clipboard command of the OS that is installed.
+// Commands copying image data are only used for gifs, other outputs copy their path.
+func findClipboardTool(goos string, wayland bool, path string, lookPath func(string) (string, error)) (clipboardTool, error) {
+	gif := strings.ToLower(filepath.Ext(path)) == ".gif"
+	for _, t := range clipboardTools(goos, wayland) {
+		if t.image && !gif {
+			continue
+		}
+		if _, err := lookPath(t.args[0]); err == nil {
+			return t, nil
+		}
+	}
+	names := []string{}
+	for _, t := range clipboardTools(goos, wayland) {
+		names = append(names, t.args[0])
+	}
+	return clipboardTool{}, fmt.Errorf("no clipboard tool found, install %s", strings.Join(uniqueStrings(names), " or "))
+}
+
+// copyToClipboard copies the output to the clipboard, the gif itself if the OS supports it or its absolute path.
+// Returns whether image data was copied.
+func copyToClipboard(path string) (bool, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	t, err := findClipboardTool(runtime.GOOS, os.Getenv("WAYLAND_DISPLAY") != "", abs, exec.LookPath)
+	if err != nil {
+		return false, err
+	}
+
+	input := []byte(abs)
+	if t.image {
+		if input, err = os.ReadFile(abs); err != nil {
+			return false, err
+		}
+	}
+	// the output isn't read, as xclip forks to serve the clipboard and keeps it open
+	cmd := exec.Command(t.args[0], t.args[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	if err := cmd.Run(); err != nil {
+		return false, fmt.Errorf("copy to clipboard with %s: %w", t.args[0], err)
+	}
+	return t.image, nil
+}
+
+// uniqueStrings returns the strings without repeats, in the order of their first occurrence.
+func uniqueStrings(s []string) []string {
+	seen := map[string]bool{}
+	res := []string{}
+	for _, v := range s {
+		if !seen[v] {
+			seen[v] = true
+			res = append(res, v)
+		}
+	}
+	return res
+}
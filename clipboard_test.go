@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// lookPathOf returns a lookPath that finds only the commands.
+func lookPathOf(commands ...string) func(string) (string, error) {
+	return func(name string) (string, error) {
+		if slices.Contains(commands, name) {
+			return "/usr/bin/" + name, nil
+		}
+		return "", errors.New("not found")
+	}
+}
+
+func TestFindClipboardTool(t *testing.T) {
+	all := lookPathOf("pbcopy", "clip", "wl-copy", "xclip", "xsel")
+	for _, tt := range []struct {
+		goos     string
+		wayland  bool
+		path     string
+		lookPath func(string) (string, error)
+		want     []string
+		image    bool
+	}{
+		{"darwin", false, "out.gif", all, []string{"pbcopy"}, false},
+		{"windows", false, "out.gif", all, []string{"clip"}, false},
+		{"linux", true, "out.gif", all, []string{"wl-copy", "--type", "image/gif"}, true},
+		{"linux", true, "out.png", all, []string{"wl-copy"}, false},
+		{"linux", false, "out.GIF", all, []string{"xclip", "-selection", "clipboard", "-t", "image/gif", "-i"}, true},
+		{"linux", false, "out.webm", all, []string{"xclip", "-selection", "clipboard", "-i"}, false},
+		{"freebsd", false, "out.gif", lookPathOf("xsel"), []string{"xsel", "--clipboard", "--input"}, false},
+	} {
+		tool, err := findClipboardTool(tt.goos, tt.wayland, tt.path, tt.lookPath)
+		if err != nil {
+			t.Fatalf("%s %s: %v", tt.goos, tt.path, err)
+		}
+		if !slices.Equal(tool.args, tt.want) || tool.image != tt.image {
+			t.Errorf("%s wayland %v %s: got %v copying the image %v, want %v and %v", tt.goos, tt.wayland, tt.path, tool.args, tool.image, tt.want, tt.image)
+		}
+	}
+
+	_, err := findClipboardTool("linux", false, "out.gif", lookPathOf())
+	if err == nil || !strings.Contains(err.Error(), "install xclip or xsel") {
+		t.Errorf("got %v, want an error naming xclip and xsel once", err)
+	}
+}
+
+// TestCopyToClipboard copies outputs with a stand-in for xclip, which saves its input and arguments.
+func TestCopyToClipboard(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the stand-in clipboard tool is a linux shell script")
+	}
+	copied := filepath.Join(t.TempDir(), "clipboard")
+	fakeCommand(t, "xclip", fmt.Sprintf("echo \"$@\" > %[1]s.args\ncat > %[1]s\n", copied))
+	t.Setenv("WAYLAND_DISPLAY", "")
+
+	dir := t.TempDir()
+	data := []byte("GIF89a")
+	for _, name := range []string{"out.gif", "out.png"} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	image, err := copyToClipboard(filepath.Join(dir, "out.gif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(copied); !image || !bytes.Equal(got, data) {
+		t.Errorf("got %q copied as image %v, want the gif", got, image)
+	}
+	if args, _ := os.ReadFile(copied + ".args"); !strings.Contains(string(args), "image/gif") {
+		t.Errorf("got arguments %q, want the gif type", args)
+	}
+
+	image, err = copyToClipboard(filepath.Join(dir, "out.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(copied); image || string(got) != filepath.Join(dir, "out.png") {
+		t.Errorf("got %q copied as image %v, want the absolute path", got, image)
+	}
+}
+
+func TestUniqueStrings(t *testing.T) {
+	if got, want := uniqueStrings([]string{"xclip", "xclip", "xsel", "xclip"}), []string{"xclip", "xsel"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if cfg := parseTestFlags(t, "-clipboard"); !cfg.clipboard {
+		t.Error("-clipboard isn't set")
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFiles are the names of the config file looked up in the working directory if -config isn't set.
+var configFiles = []string{".png2gif.yaml", ".png2gif.yml", ".png2gif.json"}
+
+// findConfigFile returns the path to the config file in the working directory, or an empty string if there is none.
+func findConfigFile() string {
+	for _, name := range configFiles {
+		if fi, err := os.Stat(name); err == nil && !fi.IsDir() {
+			return name
+		}
+	}
+	return ""
+}
+
+// applyConfigFile sets flags from a yaml or json file with flag names as keys, e.g. `fps: 25`.
+// Flags passed on the command line are not changed, so the precedence is flags > file > defaults.
+func applyConfigFile(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	values := map[string]any{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	// sort names to report errors in the same order every time
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == "config" || fs.Lookup(name) == nil {
+			return fmt.Errorf("config %s: unknown option %q", path, name)
+		}
+		if explicit[name] {
+			continue
+		}
+		v := values[name]
+		if v == nil {
+			return fmt.Errorf("config %s: option %q has no value", path, name)
+		}
+		if err := fs.Set(name, fmt.Sprint(v)); err != nil {
+			return fmt.Errorf("config %s: invalid value of %q: %w", path, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes the config file to the dir and returns its path.
+func writeConfig(t *testing.T, dir, name, data string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := writeConfig(t, dir, "a.yaml", "fps: 25\nsort: natural\nthreshold-y: 50\n")
+	jsonPath := writeConfig(t, dir, "b.json", `{"fps": 12, "dedup": "loose"}`)
+
+	for _, tt := range []struct {
+		args  []string
+		fps   int
+		sort  string
+		dedup string
+		y     float64
+	}{
+		// built-in defaults
+		{nil, 0, sortName, dedupNormal, 0},
+		// the file overrides defaults
+		{[]string{"-config", yamlPath}, 25, sortNatural, dedupNormal, 50},
+		{[]string{"-config", jsonPath}, 12, sortName, dedupLoose, 0},
+		// flags override the file
+		{[]string{"-config", yamlPath, "-fps", "10", "-threshold-y", "7"}, 10, sortNatural, dedupNormal, 7},
+		{[]string{"-fps", "10", "-config", jsonPath}, 10, sortName, dedupLoose, 0},
+	} {
+		cfg, err := parseFlags(tt.args)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		o := cfg.opts
+		if cfg.opts.fps != tt.fps || o.sort != tt.sort || o.dedup != tt.dedup || o.thresholds.Y != tt.y {
+			t.Errorf("%v: got fps %d, sort %q, dedup %q, threshold-y %v, want %d, %q, %q, %v",
+				tt.args, cfg.opts.fps, o.sort, o.dedup, o.thresholds.Y, tt.fps, tt.sort, tt.dedup, tt.y)
+		}
+	}
+}
+
+func TestConfigWorkingDir(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, ".png2gif.yaml", "fps: 40\n")
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	cfg, err := parseFlags(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.opts.fps != 40 {
+		t.Errorf("got fps %d from the working directory, want 40", cfg.opts.fps)
+	}
+	// -config takes the place of the file in the working directory
+	other := writeConfig(t, t.TempDir(), "other.yaml", "sort: natural\n")
+	if cfg, err = parseFlags([]string{"-config", other}); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.opts.fps != 0 || cfg.opts.sort != sortNatural {
+		t.Errorf("got fps %d and sort %q, want only the -config file applied", cfg.opts.fps, cfg.opts.sort)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		data string
+		want string
+	}{
+		{"colour: 5\n", `unknown option "colour"`},
+		{"config: other.yaml\n", `unknown option "config"`},
+		{"fps:\n", `option "fps" has no value`},
+		{"fps: fast\n", `invalid value of "fps"`},
+		{"fps: [\n", "config"},
+	} {
+		path := writeConfig(t, dir, "c.yaml", tt.data)
+		_, err := parseFlags([]string{"-config", path})
+		if _, ok := err.(*usageError); !ok || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: got %v, want a usage error about %s", tt.data, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+// deflickerImages drops single frames that differ from both neighbors while the neighbors are equal,
+// like a blank frame dropped by a screen recorder, the previous frame holds for the dropped one.
+// A single frame is one decoded image, whatever its delay is with -sample or -preserve-timing.
+// The neighbors are merged then, unless dedup is off.
+func deflickerImages(images []imgWithDelay, opts Options) []imgWithDelay {
+	if len(images) < 3 {
+		return images
+	}
+	th := opts.similarity()
+	frames := []imgWithDelay{images[0]}
+	dropped := 0
+	for i := 1; i < len(images); i++ {
+		cur := images[i]
+		prev := &frames[len(frames)-1]
+		if i < len(images)-1 {
+			next := images[i+1]
+			if len(cur.sources) == 1 && !FramesSimilar(prev.img, cur.img, th) && !FramesSimilar(cur.img, next.img, th) && FramesSimilar(prev.img, next.img, th) {
+				opts.logger().Debug("flicker frame dropped", "index", i)
+				dropped++
+				prev.delay += cur.delay
+				prev.sources = append(prev.sources, cur.sources...)
+				if !opts.noDedup {
+					if opts.keep == keepLast {
+						prev.img = next.img
+					}
+					prev.delay += next.delay
+					prev.sources = append(prev.sources, next.sources...)
+					i++
+				}
+				continue
+			}
+		}
+		frames = append(frames, cur)
+	}
+	if dropped > 0 {
+		opts.logger().Info("flicker frames dropped", "count", dropped)
+	}
+	return frames
+}
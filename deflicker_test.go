@@ -0,0 +1,45 @@
+package main
+
+import (
+	"image/color"
+	"slices"
+	"testing"
+)
+
+func TestDeflickerImages(t *testing.T) {
+	tests := []struct {
+		name    string
+		colors  []color.Color
+		delays  []int
+		sources []int
+		opts    Options
+		want    []int
+	}{
+		{"flicker", []color.Color{testRed, testBlue, testRed}, []int{1, 1, 1}, []int{1, 1, 1}, Options{}, []int{3}},
+		{"flicker without dedup", []color.Color{testRed, testBlue, testRed}, []int{1, 1, 1}, []int{1, 1, 1}, Options{noDedup: true}, []int{2, 1}},
+		// a sampled frame stands for 3 sources, it's still one decoded image
+		{"sampled flicker", []color.Color{testRed, testBlue, testRed}, []int{3, 3, 3}, []int{1, 1, 1}, Options{sample: 3}, []int{9}},
+		// a frame of a gif input with its own delay
+		{"timed flicker", []color.Color{testRed, testBlue, testRed}, []int{5, 7, 5}, []int{1, 1, 1}, Options{preserveTiming: true}, []int{17}},
+		// a run of merged images isn't a flicker
+		{"merged run", []color.Color{testRed, testBlue, testRed}, []int{1, 2, 1}, []int{1, 2, 1}, Options{}, []int{1, 2, 1}},
+		{"change", []color.Color{testRed, testBlue, testGreen}, []int{1, 1, 1}, []int{1, 1, 1}, Options{}, []int{1, 1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images := *testFrames(4, 4, tt.colors...)
+			for n := range images {
+				images[n].delay = tt.delays[n]
+				images[n].sources = make([]string, tt.sources[n])
+			}
+			frames := deflickerImages(images, tt.opts)
+			got := []int{}
+			for _, f := range frames {
+				got = append(got, f.delay)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got delays %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// diskSpaceMargin is how many times compression can shrink the output at best,
+// the build fails only if the output can't fit even then.
+const diskSpaceMargin = 10
+
+// estimateOutputSize returns the size of the output in the format without compression, 0 if it's unknown.
+// Compressed outputs are usually much smaller.
+func estimateOutputSize(images []imgWithDelay, format string) uint64 {
+	size := uint64(0)
+	for _, im := range images {
+		s := im.img.Bounds().Size()
+		pixels := uint64(s.X) * uint64(s.Y)
+		switch format {
+		case formatGif:
+			// a byte per palette index, a local palette and the frame headers
+			size += pixels + 3*256 + 32
+		case formatApng, formatPngSequence:
+			// 4 bytes per pixel, a filter byte per row and the frame chunks
+			size += 4*pixels + uint64(s.Y) + 64
+		default:
+			return 0
+		}
+	}
+	return size
+}
+
+// checkSpace checks the estimated size of the output against the free space,
+// returns an error if it can't fit even compressed at best and whether to warn that it may not fit.
+func checkSpace(estimate, free uint64) (bool, error) {
+	if estimate/diskSpaceMargin > free {
+		return false, fmt.Errorf("not enough disk space: at least %d bytes needed, %d bytes free", estimate/diskSpaceMargin, free)
+	}
+	return estimate > free, nil
+}
+
+// checkDiskSpace checks that the output fits into the free space of its volume before it's written,
+// so full disks don't leave half-written files. The check is skipped where the free space is unknown.
+func checkDiskSpace(images []imgWithDelay, format, path string, opts Options) error {
+	// outputs of the factory in options are not necessarily files
+	if opts.create != nil {
+		return nil
+	}
+	estimate := estimateOutputSize(images, format)
+	if estimate == 0 {
+		return nil
+	}
+	free, ok := freeSpace(filepath.Dir(path))
+	if !ok {
+		return nil
+	}
+	warn, err := checkSpace(estimate, free)
+	if err != nil {
+		return &fileError{"write output", path, err}
+	}
+	if warn {
+		opts.warnf("%s may not fit into %d bytes of free disk space", path, free)
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd
+
+package main
+
+// freeSpace returns false, the free space is not checked on this platform.
+func freeSpace(dir string) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestEstimateOutputSize(t *testing.T) {
+	images := *testFrames(10, 20, testRed, testGreen)
+	cases := []struct {
+		format string
+		want   uint64
+	}{
+		{formatGif, 2 * (200 + 3*256 + 32)},
+		{formatApng, 2 * (4*200 + 20 + 64)},
+		{formatPngSequence, 2 * (4*200 + 20 + 64)},
+		{formatWebm, 0},
+	}
+	for _, c := range cases {
+		if got := estimateOutputSize(images, c.format); got != c.want {
+			t.Errorf("%s: got %d, want %d", c.format, got, c.want)
+		}
+	}
+}
+
+func TestCheckSpace(t *testing.T) {
+	cases := []struct {
+		estimate, free uint64
+		warn, fail     bool
+	}{
+		{1000, 2000, false, false},
+		{1000, 1000, false, false},
+		{1000, 999, true, false},
+		{1000, 100, true, false},
+		{1000, 99, false, true},
+		{1000, 0, false, true},
+	}
+	for _, c := range cases {
+		warn, err := checkSpace(c.estimate, c.free)
+		if warn != c.warn || (err != nil) != c.fail {
+			t.Errorf("%d bytes in %d free: got warning %v and error %v, want %v and failure %v", c.estimate, c.free, warn, err, c.warn, c.fail)
+		}
+	}
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.gif")
+	warnings := []string{}
+	opts := Options{warn: func(s string) { warnings = append(warnings, s) }}
+	if err := checkDiskSpace(*testFrames(8, 8, testRed), formatGif, path, opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("got warnings %v for a tiny output", warnings)
+	}
+
+	// an output that can't fit anywhere fails with the path, unless outputs are not files
+	huge := []imgWithDelay{{img: hugeImage{}}}
+	err := checkDiskSpace(huge, formatGif, path, opts)
+	if _, ok := freeSpace(filepath.Dir(path)); ok {
+		fe := &fileError{}
+		if !errors.As(err, &fe) || fe.path != path {
+			t.Errorf("got %v, want a file error for %s", err, path)
+		}
+	}
+	opts.create = func(string) (io.WriteCloser, error) { return &memFile{}, nil }
+	if err := checkDiskSpace(huge, formatGif, path, opts); err != nil {
+		t.Errorf("got %v with the output factory, want no check", err)
+	}
+}
+
+// hugeImage is an image too big for any disk, only its bounds are used.
+type hugeImage struct{ image.Image }
+
+func (hugeImage) Bounds() image.Rectangle { return image.Rect(0, 0, 1<<31, 1<<31) }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// drawer returns the drawer that maps frames onto palettes with the dither strength from options,
+// full strength is the standard Floyd-Steinberg diffusion and none maps each pixel to the nearest color.
+func (o Options) drawer() draw.Drawer {
+	switch s := o.dither(); {
+	case s >= 1:
+		return draw.FloydSteinberg
+	case s <= 0:
+		return draw.Src
+	default:
+		return scaledFloydSteinberg{s}
+	}
+}
+
+// dither returns the dither strength from 0 to 1.
+func (o Options) dither() float64 {
+	switch {
+	case o.ditherStrength < 0:
+		return 0
+	case o.ditherStrength == 0 || o.ditherStrength > 1:
+		return 1
+	}
+	return o.ditherStrength
+}
+
+// scaledFloydSteinberg is a Floyd-Steinberg drawer that diffuses only a part of the quantization error,
+// less noise than the full diffusion and less banding than none.
+// @property {float64} strength - The part of the error diffused to neighbor pixels, from 0 to 1.
+type scaledFloydSteinberg struct {
+	strength float64
+}
+
+// Draw maps the source onto the palette of the destination, other destinations are drawn as is.
+func (d scaledFloydSteinberg) Draw(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point) {
+	p, ok := dst.(*image.Paletted)
+	if !ok || len(p.Palette) == 0 {
+		draw.Draw(dst, r, src, sp, draw.Src)
+		return
+	}
+	r = r.Intersect(p.Bounds())
+	w := r.Dx()
+	// errors of the current and the next row, with a pixel of padding on each side
+	cur, next := make([][4]float64, w+2), make([][4]float64, w+2)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			i := x - r.Min.X + 1
+			sr, sg, sb, sa := src.At(sp.X+x-r.Min.X, sp.Y+y-r.Min.Y).RGBA()
+			want := [4]float64{
+				clamp16(float64(sr) + cur[i][0]),
+				clamp16(float64(sg) + cur[i][1]),
+				clamp16(float64(sb) + cur[i][2]),
+				clamp16(float64(sa) + cur[i][3]),
+			}
+			idx := p.Palette.Index(color.RGBA64{uint16(want[0]), uint16(want[1]), uint16(want[2]), uint16(want[3])})
+			p.SetColorIndex(x, y, uint8(idx))
+
+			pr, pg, pb, pa := p.Palette[idx].RGBA()
+			got := [4]float64{float64(pr), float64(pg), float64(pb), float64(pa)}
+			for c := range want {
+				e := (want[c] - got[c]) * d.strength
+				cur[i+1][c] += e * 7 / 16
+				next[i-1][c] += e * 3 / 16
+				next[i][c] += e * 5 / 16
+				next[i+1][c] += e * 1 / 16
+			}
+		}
+		cur, next = next, cur
+		for i := range next {
+			next[i] = [4]float64{}
+		}
+	}
+}
+
+// clamp16 clamps the value to the range of 16-bit color channels.
+func clamp16(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 0xffff {
+		return 0xffff
+	}
+	return v
+}
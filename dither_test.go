@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"testing"
+)
+
+// ditherTestGradient maps the gradient onto the web-safe palette with the drawer.
+func ditherTestGradient(d draw.Drawer) (*image.Paletted, image.Image) {
+	src := gradient16(128, 32)
+	p := image.NewPaletted(src.Bounds(), palette.WebSafe)
+	d.Draw(p, p.Bounds(), src, image.Point{})
+	return p, src
+}
+
+// ditherNoise is the mean difference of neighbor pixels of the paletted image, dithering scatters them.
+func ditherNoise(p *image.Paletted) float64 {
+	sum, b := 0.0, p.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X + 1; x < b.Max.X; x++ {
+			r1, g1, b1, _ := p.At(x-1, y).RGBA()
+			r2, g2, b2, _ := p.At(x, y).RGBA()
+			for _, d := range []float64{float64(r1) - float64(r2), float64(g1) - float64(g2), float64(b1) - float64(b2)} {
+				sum += max(d, -d) / 0x101
+			}
+		}
+	}
+	return sum / float64(3*(b.Dx()-1)*b.Dy())
+}
+
+func TestDitherStrength(t *testing.T) {
+	banding, noise := []float64{}, []float64{}
+	for _, s := range []float64{-1, 0.5, 1} {
+		p, src := ditherTestGradient(Options{ditherStrength: s}.drawer())
+		banding = append(banding, bandingError(src, p))
+		noise = append(noise, ditherNoise(p))
+	}
+	// stronger dithering trades bands for noise
+	if !(banding[0] > banding[1] && banding[1] > banding[2]) {
+		t.Errorf("got banding errors %v for strengths 0, 0.5 and 1, want them decreasing", banding)
+	}
+	if !(noise[0] < noise[1] && noise[1] < noise[2]) {
+		t.Errorf("got noise %v for strengths 0, 0.5 and 1, want it increasing", noise)
+	}
+
+	// the scaled drawer matches nearest colors at strength 0
+	none, _ := ditherTestGradient(scaledFloydSteinberg{0})
+	nearest, _ := ditherTestGradient(draw.Src)
+	if !bytes.Equal(none.Pix, nearest.Pix) {
+		t.Error("strength 0 doesn't map pixels to the nearest colors")
+	}
+}
+
+func TestDitherStrengthFlag(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want float64
+	}{
+		{nil, 1},
+		{[]string{"-dither-strength", "0.5"}, 0.5},
+		{[]string{"-dither-strength", "0"}, -1},
+	} {
+		cfg := parseTestFlags(t, tt.args...)
+		if cfg.opts.ditherStrength != tt.want {
+			t.Errorf("%v: got %g, want %g", tt.args, cfg.opts.ditherStrength, tt.want)
+		}
+	}
+	for _, v := range []string{"-0.5", "1.5"} {
+		parseUsageError(t, "-dither-strength", v)
+	}
+	if s := (Options{}).dither(); s != 1 {
+		t.Errorf("got the default strength %g, want 1", s)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// EncodeTo encodes frames to the writer in the format, "gif", "apng" or "webm", regardless of any file name.
+// "png-sequence" writes png images of frames one after another.
+// opts: options to tweak the encoding, e.g. the frame rate.
+func EncodeTo(w io.Writer, format string, frames []imgWithDelay, opts Options) error {
+	fps, err := normalizeFps(opts.fps)
+	if err != nil {
+		return err
+	}
+	return encodeTo(context.Background(), w, format, &frames, 100/fps, opts)
+}
+
+// encodeTo encodes frames to the writer in the format, delay in 100ths of a second per source image.
+func encodeTo(ctx context.Context, w io.Writer, format string, images *[]imgWithDelay, delay int, opts Options) error {
+	if opts.stripMetadata && (format == formatGif || format == formatApng) {
+		s := &strippingWriter{w: w, strip: stripGifMetadata}
+		if format == formatApng {
+			s.strip = stripPngMetadata
+		}
+		opts.stripMetadata = false
+		if err := encodeTo(ctx, s, format, images, delay, opts); err != nil {
+			return err
+		}
+		return s.Close()
+	}
+	switch format {
+	case formatGif:
+		if opts.targetSize > 0 {
+			return fitGif(ctx, w, images, delay, opts)
+		}
+		im_p, err := encodeImgPaletted(ctx, images, opts)
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		opts.report(phaseWriting, 0, 0)
+		return encodeGif(w, &im_p, delay, opts)
+	case formatApng:
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		opts.report(phaseWriting, 0, 0)
+		return encodeApng(w, images, delay, opts)
+	case formatWebm:
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		opts.report(phaseWriting, 0, 0)
+		args := webmArgs
+		if opts.stripMetadata {
+			args = append(append([]string{}, webmArgs...), webmStripArgs...)
+		}
+		return encodeVideo(ctx, w, images, delay, args, opts)
+	case formatPngSequence:
+		return encodePngs(ctx, w, images, opts)
+	}
+	return fmt.Errorf("unsupported output format: %s", format)
+}
+
+// writeOutput encodes frames to the file in the format of its extension, the partial file is removed on errors.
+// An appended gif is encoded to a temporary file next to it, which replaces it once it's written,
+// so the existing gif is kept on errors and timeouts.
+func writeOutput(ctx context.Context, images *[]imgWithDelay, delay int, path string, opts Options) (err error) {
+	format, err := outputFormat(path, opts.format)
+	if err != nil {
+		return err
+	}
+	if err := checkDiskSpace(*images, format, path, opts); err != nil {
+		return err
+	}
+	if format == formatPngSequence {
+		return writePngSequence(ctx, images, path, opts)
+	}
+	var f io.WriteCloser
+	name := path
+	if opts.appendOutput && opts.create == nil {
+		tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+		if err != nil {
+			return &fileError{"create temporary file", path, err}
+		}
+		f, name = tmp, tmp.Name()
+	} else if f, err = opts.createFile(path); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			opts.removeFile(name)
+		}
+	}()
+
+	// written bytes are kept to verify them, as files of the create factory can't be read back
+	verify := opts.verify && format == formatGif
+	written := bytes.Buffer{}
+	var out io.Writer = f
+	if verify {
+		out = io.MultiWriter(f, &written)
+	}
+	w := bufio.NewWriter(out)
+	if err := encodeTo(ctx, w, format, images, delay, opts); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// a broken gif is removed
+	if verify {
+		if err := verifyGif(&written, path, expectedFrames(*images, delay, opts)); err != nil {
+			return err
+		}
+	}
+	if name != path {
+		if err := os.Rename(name, path); err != nil {
+			return &fileError{"replace gif", path, err}
+		}
+	}
+	opts.logger().Debug("output written", "path", path, "format", format, "frames", len(*images))
+	return nil
+}
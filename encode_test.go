@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"image/gif"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEncodeToFormats(t *testing.T) {
+	frames := *testFrames(8, 8, testRed, testGreen, testBlue)
+	frames[1].delay = 2
+
+	b := bytes.Buffer{}
+	if err := EncodeTo(&b, formatGif, frames, Options{fps: 20}); err != nil {
+		t.Fatal(err)
+	}
+	g, err := gif.DecodeAll(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(g.Delay, []int{5, 10, 5}) {
+		t.Errorf("gif: got delays %v, want [5 10 5]", g.Delay)
+	}
+
+	b.Reset()
+	if err := EncodeTo(&b, formatApng, frames, Options{fps: 20}); err != nil {
+		t.Fatal(err)
+	}
+	decoded := apngFrames(t, b.Bytes())
+	if len(decoded) != len(frames) {
+		t.Fatalf("apng: got %d frames, want %d", len(decoded), len(frames))
+	}
+	for n, f := range decoded {
+		if !colorsEqual(f.At(0, 0), frames[n].img.At(0, 0)) {
+			t.Errorf("apng: frame %d is %v, want %v", n, f.At(0, 0), frames[n].img.At(0, 0))
+		}
+	}
+
+	// the format is chosen explicitly, not by a file name
+	if err := EncodeTo(io.Discard, "bmp", frames, Options{}); err == nil || !strings.Contains(err.Error(), "unsupported output format: bmp") {
+		t.Errorf("got %v, want an unsupported format error", err)
+	}
+	if err := EncodeTo(io.Discard, formatGif, frames, Options{fps: 101}); err == nil {
+		t.Error("got no error for 101 fps")
+	}
+}
+
+func TestMaxDelay(t *testing.T) {
+	// 200 equal images at 10 fps are merged into one frame of 20 seconds
+	frames := []imgWithDelay{{img: testPattern(16, 16, 0), delay: 200}, {img: testPattern(16, 16, 60), delay: 1}}
+	for _, tt := range []struct {
+		maxDelay int
+		delays   []int
+	}{
+		{0, []int{2000, 10}},
+		{500, []int{500, 500, 500, 500, 10}},
+		{300, []int{300, 300, 300, 300, 300, 300, 200, 10}},
+	} {
+		images := append([]imgWithDelay{}, frames...)
+		b := bytes.Buffer{}
+		if err := encodeTo(context.Background(), &b, formatGif, &images, 10, Options{maxDelay: tt.maxDelay}); err != nil {
+			t.Fatal(err)
+		}
+		g, err := gif.DecodeAll(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(g.Delay, tt.delays) {
+			t.Errorf("max delay %d: got delays %v, want %v", tt.maxDelay, g.Delay, tt.delays)
+		}
+		// split frames repeat the image
+		for n := range tt.delays[:len(tt.delays)-1] {
+			if !slices.Equal(g.Image[n].Pix, g.Image[0].Pix) {
+				t.Errorf("max delay %d: frame %d differs from the first one", tt.maxDelay, n)
+			}
+		}
+
+		b.Reset()
+		if err := encodeTo(context.Background(), &b, formatApng, &images, 10, Options{maxDelay: tt.maxDelay}); err != nil {
+			t.Fatal(err)
+		}
+		if n, _, _ := apngInfo(t, b.Bytes()); int(n) != len(tt.delays) {
+			t.Errorf("max delay %d: got %d apng frames, want %d", tt.maxDelay, n, len(tt.delays))
+		}
+	}
+	parseUsageError(t, "-max-delay", "-1")
+}
+
+// TestEncodeImgPalettedConcurrent encodes frames on several go routines, run it with -race to check their writes.
+func TestEncodeImgPalettedConcurrent(t *testing.T) {
+	colors := []color.Color{}
+	for n := 0; n < 64; n++ {
+		colors = append(colors, color.RGBA{uint8(n * 4), 255 - uint8(n*4), uint8(n), 255})
+	}
+	for _, exact := range []bool{false, true} {
+		want, err := encodeImgPaletted(context.Background(), testFrames(8, 8, colors...), Options{threadsEncode: 1, exactPalette: exact})
+		if err != nil {
+			t.Fatal(err)
+		}
+		im_p, err := encodeImgPaletted(context.Background(), testFrames(8, 8, colors...), Options{threadsEncode: 8, exactPalette: exact})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(im_p) != len(colors) {
+			t.Fatalf("exact %v: got %d frames, want %d", exact, len(im_p), len(colors))
+		}
+		for n, p := range im_p {
+			if p == nil || p.paletted == nil {
+				t.Fatalf("exact %v: frame %d is nil", exact, n)
+			}
+			// frames encoded at once are the same as encoded one by one
+			if !slices.Equal(p.paletted.Pix, want[n].paletted.Pix) || !slices.Equal(p.paletted.Palette, want[n].paletted.Palette) {
+				t.Errorf("exact %v: frame %d differs from the one encoded alone", exact, n)
+			}
+			if exact && !colorsEqual(p.paletted.At(0, 0), colors[n]) {
+				t.Errorf("exact %v: frame %d has color %v, want %v", exact, n, p.paletted.At(0, 0), colors[n])
+			}
+		}
+	}
+}
+
+func TestBackgroundIndex(t *testing.T) {
+	encode := func(args ...string) ([]byte, error) {
+		b := bytes.Buffer{}
+		err := encodeTo(context.Background(), &b, formatGif, testFrames(8, 8, testRed, testBlue), 4, parseTestFlags(t, args...).opts)
+		return b.Bytes(), err
+	}
+	for _, args := range [][]string{{"-bg-index", "3"}, {"-bg-color", "#0000ff"}, nil} {
+		data, err := encode(args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the logical screen descriptor has the global palette flag and the background index
+		hasGlobal := data[10]&0x80 != 0
+		want := byte(0)
+		switch {
+		case args == nil:
+			if hasGlobal {
+				t.Error("a global palette is written without a background")
+			}
+		case args[0] == "-bg-index":
+			want = 3
+		default:
+			pal := g.Config.ColorModel.(color.Palette)
+			want = byte(pal.Index(testBlue))
+			if !colorsEqual(pal[want], testBlue) {
+				t.Errorf("background color %v isn't blue", pal[want])
+			}
+		}
+		if args != nil && !hasGlobal {
+			t.Errorf("%v: no global palette for the background index", args)
+		}
+		if data[11] != want || g.BackgroundIndex != want {
+			t.Errorf("%v: got the background index %d, want %d", args, data[11], want)
+		}
+	}
+
+	if _, err := encode("-bg-index", "200", "-colors", "4"); err == nil || !strings.Contains(err.Error(), "out of the palette") {
+		t.Errorf("got %v, want an error about the index out of the palette", err)
+	}
+	parseUsageError(t, "-bg-index", "256")
+	parseUsageError(t, "-bg-index", "1", "-bg-color", "#000000")
+	parseUsageError(t, "-bg-color", "black")
+	parseUsageError(t, "-bg-index", "1", "-interlace")
+}
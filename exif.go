@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// exif tags to find the capture time.
+const (
+	exifIFDPointer       = 0x8769
+	exifDateTimeOriginal = 0x9003
+)
+
+// exifTimeLayout is the format of exif dates, e.g. 2023:01:02 15:04:05.
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// errNoExif is returned when the image has no capture time in its exif data.
+var errNoExif = errors.New("no exif capture time")
+
+// readExifTime reads DateTimeOriginal from the exif segment of a jpeg image.
+// The time is in the local time of the camera, as exif doesn't store the time zone.
+func readExifTime(r io.Reader) (time.Time, error) {
+	br := bufio.NewReader(r)
+	soi := make([]byte, 2)
+	if _, err := io.ReadFull(br, soi); err != nil || soi[0] != 0xff || soi[1] != 0xd8 {
+		return time.Time{}, errNoExif
+	}
+
+	// walk segments until the exif one, or the start of the image data
+	for {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(br, header); err != nil || header[0] != 0xff {
+			return time.Time{}, errNoExif
+		}
+		marker := header[1]
+		size := int(binary.BigEndian.Uint16(header[2:])) - 2
+		if marker == 0xda || size < 0 {
+			return time.Time{}, errNoExif
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return time.Time{}, errNoExif
+		}
+		if marker == 0xe1 && bytes.HasPrefix(data, []byte("Exif\x00\x00")) {
+			return parseExifTime(data[6:])
+		}
+	}
+}
+
+// parseExifTime finds DateTimeOriginal in the exif sub IFD of the tiff structure.
+func parseExifTime(tiff []byte) (time.Time, error) {
+	if len(tiff) < 8 {
+		return time.Time{}, errNoExif
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return time.Time{}, errNoExif
+	}
+
+	// the exif IFD is referenced from IFD0
+	ifd0 := order.Uint32(tiff[4:8])
+	exifIFD, ok := findExifTag(tiff, order, ifd0, exifIFDPointer)
+	if !ok {
+		return time.Time{}, errNoExif
+	}
+	entry, ok := findExifTag(tiff, order, order.Uint32(exifIFD[8:12]), exifDateTimeOriginal)
+	if !ok {
+		return time.Time{}, errNoExif
+	}
+
+	// ascii values longer than 4 bytes are stored at the offset
+	count := order.Uint32(entry[4:8])
+	offset := order.Uint32(entry[8:12])
+	if count < 19 || uint64(offset)+uint64(count) > uint64(len(tiff)) {
+		return time.Time{}, errNoExif
+	}
+	value := strings.TrimRight(string(tiff[offset:offset+count]), "\x00 ")
+	return time.ParseInLocation(exifTimeLayout, value, time.Local)
+}
+
+// findExifTag returns the 12 byte entry of the tag in the IFD at the offset.
+func findExifTag(tiff []byte, order binary.ByteOrder, offset uint32, tag uint16) ([]byte, bool) {
+	if uint64(offset)+2 > uint64(len(tiff)) {
+		return nil, false
+	}
+	n := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < n; i++ {
+		start := int(offset) + 2 + i*12
+		if start+12 > len(tiff) {
+			return nil, false
+		}
+		entry := tiff[start : start+12]
+		if order.Uint16(entry) == tag {
+			return entry, true
+		}
+	}
+	return nil, false
+}
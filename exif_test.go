@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/jpeg"
+	"slices"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// exifJpeg returns a jpeg image with the capture time in its exif segment, in the byte order.
+func exifJpeg(t *testing.T, captured string, order binary.ByteOrder) []byte {
+	t.Helper()
+	// tiff header, IFD0 with the pointer to the exif IFD at 26, the exif IFD with the time at 44
+	tiff := make([]byte, 44, 64)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	ifdEntry := func(at int, tag, typ uint16, count, value uint32) {
+		order.PutUint16(tiff[at:], 1)
+		order.PutUint16(tiff[at+2:], tag)
+		order.PutUint16(tiff[at+4:], typ)
+		order.PutUint32(tiff[at+6:], count)
+		order.PutUint32(tiff[at+10:], value)
+	}
+	ifdEntry(8, exifIFDPointer, 4, 1, 26)
+	ifdEntry(26, exifDateTimeOriginal, 2, uint32(len(captured)+1), 44)
+	tiff = append(append(tiff, captured...), 0)
+
+	img := bytes.Buffer{}
+	if err := jpeg.Encode(&img, testFrame(8, 8, testRed), nil); err != nil {
+		t.Fatal(err)
+	}
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	b := bytes.Buffer{}
+	b.Write([]byte{0xff, 0xd8, 0xff, 0xe1})
+	binary.Write(&b, binary.BigEndian, uint16(len(app1)+2))
+	b.Write(app1)
+	b.Write(img.Bytes()[2:])
+	return b.Bytes()
+}
+
+func TestReadExifTime(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		data := exifJpeg(t, "2023:01:02 15:04:05", order)
+		if _, err := jpeg.Decode(bytes.NewReader(data)); err != nil {
+			t.Fatalf("%v: fixture isn't a jpeg: %v", order, err)
+		}
+		got, err := readExifTime(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%v: %v", order, err)
+		}
+		if want := time.Date(2023, 1, 2, 15, 4, 5, 0, time.Local); !got.Equal(want) {
+			t.Errorf("%v: got %v, want %v", order, got, want)
+		}
+	}
+	img := bytes.Buffer{}
+	if err := jpeg.Encode(&img, testFrame(8, 8, testRed), nil); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{"plain jpeg": img.Bytes(), "png": []byte("\x89PNG\r\n"), "empty": nil} {
+		if _, err := readExifTime(bytes.NewReader(data)); err != errNoExif {
+			t.Errorf("%s: got %v, want no exif", name, err)
+		}
+	}
+}
+
+func TestSortExif(t *testing.T) {
+	// names and modification times don't follow the capture order
+	mtime := time.Date(2023, 1, 2, 12, 0, 0, 0, time.Local)
+	fsys := fstest.MapFS{
+		"a.jpg": {Data: exifJpeg(t, "2023:01:02 10:00:03", binary.LittleEndian), ModTime: mtime},
+		"b.jpg": {Data: exifJpeg(t, "2023:01:02 10:00:01", binary.BigEndian), ModTime: mtime},
+		"c.jpg": {Data: exifJpeg(t, "2023:01:02 10:00:02", binary.LittleEndian), ModTime: mtime.Add(-time.Hour)},
+		// without exif the modification time is used
+		"d.jpg": {ModTime: time.Date(2023, 1, 2, 10, 0, 2, 500, time.Local)},
+	}
+	got := listTestFiles(t, fsys, parseTestFlags(t, "-sort", "exif").opts)
+	if want := []string{"b.jpg", "c.jpg", "d.jpg", "a.jpg"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+)
+
+// fitStep is a set of settings tried to make the gif smaller.
+// @property {int} colors - The number of colors in palettes of frames.
+// @property {float64} scale - The scale of frames.
+// @property {int} step - Keep every Nth frame.
+type fitStep struct {
+	colors int
+	scale  float64
+	step   int
+}
+
+// String returns a human readable description of the settings.
+func (s fitStep) String() string {
+	return fmt.Sprintf("%d colors, %d%% scale, every %d frame(s)", s.colors, int(s.scale*100), s.step)
+}
+
+// fitSteps are settings tried in order, from the best quality to the smallest size.
+var fitSteps = []fitStep{
+	{256, 1, 1},
+	{128, 1, 1},
+	{64, 1, 1},
+	{64, 0.75, 1},
+	{32, 0.75, 1},
+	{32, 0.5, 1},
+	{32, 0.5, 2},
+	{16, 0.5, 2},
+	{16, 0.35, 3},
+	{8, 0.25, 4},
+}
+
+// fitGif encodes the gif with fewer colors, smaller frames and fewer frames until it fits
+// into the target size of bytes from options, then writes it to the writer.
+// images are replaced by the frames of the written gif.
+func fitGif(ctx context.Context, w io.Writer, images *[]imgWithDelay, delay int, opts Options) error {
+	smallest := -1
+	for _, s := range fitSteps {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		opts.report(phaseFitting+" "+s.String(), 0, 0)
+
+		frames := dropFrames(*images, s.step)
+		if s.scale < 1 {
+			for i, f := range frames {
+				frames[i].img = scaleImage(f.img, s.scale, opts.linear)
+			}
+		}
+		stepOpts := opts
+		stepOpts.numColors = s.colors
+		im_p, err := encodeImgPaletted(ctx, &frames, stepOpts)
+		if err != nil {
+			return err
+		}
+
+		b := bytes.Buffer{}
+		if err := encodeGif(&b, &im_p, delay, stepOpts); err != nil {
+			return err
+		}
+		if b.Len() <= opts.targetSize {
+			opts.logger().Info("gif fitted", "bytes", b.Len(), "settings", s.String())
+			*images = frames
+			_, err := w.Write(b.Bytes())
+			return err
+		}
+		if smallest < 0 || b.Len() < smallest {
+			smallest = b.Len()
+		}
+	}
+	return fmt.Errorf("failed to fit the gif into %d bytes, the smallest was %d bytes", opts.targetSize, smallest)
+}
+
+// dropFrames keeps every nth frame, the kept frame holds for the dropped ones after it and takes their sources.
+func dropFrames(images []imgWithDelay, n int) []imgWithDelay {
+	frames := make([]imgWithDelay, 0, len(images))
+	for i, im := range images {
+		if n <= 1 || i%n == 0 {
+			im.sources = append([]string{}, im.sources...)
+			frames = append(frames, im)
+			continue
+		}
+		frames[len(frames)-1].delay += im.delay
+		frames[len(frames)-1].sources = append(frames[len(frames)-1].sources, im.sources...)
+	}
+	return frames
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/gif"
+	"strings"
+	"testing"
+)
+
+func TestTargetSize(t *testing.T) {
+	// random colors compress badly
+	images := []image.Image{}
+	for n := 0; n < 8; n++ {
+		images = append(images, noisyImage(96, 96, int64(n)))
+	}
+	frames := framesOf(images...)
+	full := bytes.Buffer{}
+	if err := encodeTo(context.Background(), &full, formatGif, &frames, 4, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	target := full.Len() / 5
+	fitted := append([]imgWithDelay{}, frames...)
+	b := bytes.Buffer{}
+	if err := encodeTo(context.Background(), &b, formatGif, &fitted, 4, Options{targetSize: target}); err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() > target {
+		t.Errorf("got %d bytes, want at most %d", b.Len(), target)
+	}
+	g, err := gif.DecodeAll(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// frames are replaced by the fitted ones, which are written
+	if len(g.Image) != len(fitted) || len(g.Image) == 0 {
+		t.Errorf("got %d frames, %d fitted", len(g.Image), len(fitted))
+	}
+	// dropped frames are held by the kept ones
+	total := 0
+	for _, d := range g.Delay {
+		total += d
+	}
+	if total != 4*len(frames) {
+		t.Errorf("got the total delay %d, want %d", total, 4*len(frames))
+	}
+
+	// a gif fits the target as is
+	same := append([]imgWithDelay{}, frames...)
+	b.Reset()
+	if err := encodeTo(context.Background(), &b, formatGif, &same, 4, Options{targetSize: full.Len()}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b.Bytes(), full.Bytes()) {
+		t.Error("a gif that fits is changed")
+	}
+
+	err = encodeTo(context.Background(), &b, formatGif, &frames, 4, Options{targetSize: 100})
+	if err == nil || !strings.Contains(err.Error(), "failed to fit the gif into 100 bytes") {
+		t.Errorf("got %v, want an error about the target size", err)
+	}
+}
+
+func TestParseTargetSize(t *testing.T) {
+	cfg, err := parseFlags([]string{"-target-size", "1000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.opts.targetSize != 1000 {
+		t.Errorf("got the target size %d, want 1000", cfg.opts.targetSize)
+	}
+	parseUsageError(t, "-target-size", "-1")
+}
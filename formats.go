@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// imageExts are extensions of files listed as images in a folder, filled by formats with registered decoders.
+var imageExts = map[string]bool{}
+
+// registerImageExt lists files with the extensions as images,
+// the decoder of the format should be registered with image.RegisterFormat too.
+func registerImageExt(exts ...string) {
+	for _, ext := range exts {
+		imageExts[ext] = true
+	}
+}
+
+// ImageExtensions returns the sorted extensions of files listed as images in a folder.
+// Formats are left out of a build with the nopng and nojpeg build tags.
+func ImageExtensions() []string {
+	exts := make([]string, 0, len(imageExts))
+	for ext := range imageExts {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+// isImage checks if the file is an image of a registered format by its extension.
+func isImage(name string) bool {
+	return imageExts[filepath.Ext(name)]
+}
+
+// isImage checks if the file is an image by its extension,
+// one of the extensions in options if they are set, or of a registered format otherwise.
+func (o Options) isImage(name string) bool {
+	if o.exts != nil {
+		return o.exts[filepath.Ext(name)]
+	}
+	return isImage(name)
+}
+
+// parseExts parses comma separated extensions of files listed as images, e.g. .png,.jpg,.jpeg.
+// Files are decoded by their content, so an extension of any registered format can be added, e.g. .PNG.
+func parseExts(s string) (map[string]bool, error) {
+	exts := map[string]bool{}
+	for _, ext := range strings.Split(s, ",") {
+		ext = strings.TrimSpace(ext)
+		if len(ext) < 2 || ext[0] != '.' || strings.ContainsAny(ext[1:], "./\\") {
+			return nil, fmt.Errorf("invalid extension %q, extensions should start with a dot, e.g. .png,.jpg", ext)
+		}
+		exts[ext] = true
+	}
+	return exts, nil
+}
@@ -0,0 +1,9 @@
+//go:build !nojpeg
+
+package main
+
+import _ "image/jpeg"
+
+func init() {
+	registerImageExt(".jpg")
+}
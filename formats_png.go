@@ -0,0 +1,9 @@
+//go:build !nopng
+
+package main
+
+import _ "image/png"
+
+func init() {
+	registerImageExt(".png")
+}
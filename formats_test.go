@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestImageExtensions(t *testing.T) {
+	exts := ImageExtensions()
+	if !slices.Equal(exts, []string{".jpg", ".png"}) {
+		t.Errorf("got extensions %v, want .jpg and .png without build tags", exts)
+	}
+
+	// listed files follow the registered extensions
+	fsys := fstest.MapFS{"a.png": {}, "b.jpg": {}, "c.gif": {}, "d.jpeg": {}, "e.PNG": {}, "f.txt": {}}
+	want := []string{}
+	for _, name := range []string{"a.png", "b.jpg", "c.gif", "d.jpeg", "e.PNG", "f.txt"} {
+		if slices.Contains(exts, filepath.Ext(name)) {
+			want = append(want, name)
+		}
+	}
+	if got := listTestFiles(t, fsys, Options{}); !slices.Equal(got, want) {
+		t.Errorf("got files %v, want %v", got, want)
+	}
+	// -ext replaces the registered extensions
+	if got := listTestFiles(t, fsys, Options{exts: map[string]bool{".PNG": true}}); !slices.Equal(got, []string{"e.PNG"}) {
+		t.Errorf("-ext .PNG: got files %v", got)
+	}
+
+	// each listed format has a registered decoder
+	encoders := map[string]func(*bytes.Buffer, image.Image) error{
+		".png": func(b *bytes.Buffer, img image.Image) error { return png.Encode(b, img) },
+		".jpg": func(b *bytes.Buffer, img image.Image) error { return jpeg.Encode(b, img, nil) },
+	}
+	for _, ext := range exts {
+		b := bytes.Buffer{}
+		if err := encoders[ext](&b, testFrame(4, 4, testRed)); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := image.Decode(&b); err != nil {
+			t.Errorf("%s: %v", ext, err)
+		}
+	}
+}
+
+func TestParseExts(t *testing.T) {
+	exts, err := parseExts(".png, .jpg,.PNG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exts) != 3 || !exts[".png"] || !exts[".jpg"] || !exts[".PNG"] {
+		t.Errorf("got %v, want .png, .jpg and .PNG", exts)
+	}
+	for _, s := range []string{"png", ".png,jpg", ".", ".png,", ".tar.gz", "./png"} {
+		if _, err := parseExts(s); err == nil {
+			t.Errorf("%q: got no error", s)
+		}
+	}
+	parseUsageError(t, "-ext", "png")
+}
+
+func TestCustomExts(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.png":        {},
+		"b.jpg":        {},
+		"c.jpeg":       {},
+		"d.PNG":        {},
+		"scene/e.png":  {},
+		"scene/f.jpeg": {},
+		"list.txt":     {Data: []byte("scene/*\n")},
+	}
+	for _, tt := range []struct {
+		ext  string
+		want []string
+	}{
+		// a format can be restricted to some of its extensions or left out
+		{".png", []string{"a.png"}},
+		{".jpg,.jpeg", []string{"b.jpg", "c.jpeg"}},
+		{".png,.PNG,.jpeg", []string{"a.png", "c.jpeg", "d.PNG"}},
+	} {
+		if got := listTestFiles(t, fsys, parseTestFlags(t, "-ext", tt.ext).opts); !slices.Equal(got, tt.want) {
+			t.Errorf("-ext %s: got %v, want %v", tt.ext, got, tt.want)
+		}
+	}
+
+	// manifest globs match the extensions too
+	files, err := listFiles("list.txt", Options{fsys: fsys, exts: map[string]bool{".jpeg": true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"scene/f.jpeg"}; !slices.Equal(*files, want) {
+		t.Errorf("manifest: got %v, want %v", *files, want)
+	}
+
+	// files are decoded by their content, whatever the extension
+	dir := t.TempDir()
+	writeTestImages(t, dir, testPattern(32, 32, 0), testPattern(32, 32, 60))
+	for _, name := range []string{"0001", "0002"} {
+		if err := os.Rename(filepath.Join(dir, name+".png"), filepath.Join(dir, name+".frame")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := filepath.Join(t.TempDir(), "out.gif")
+	msg := gen(context.Background(), dir, out, parseTestFlags(t, "-ext", ".frame").opts, nil)().(resultMsg)
+	if msg.err != nil {
+		t.Fatal(msg.err)
+	}
+	if n := len(decodeTestGif(t, out).Image); n != 2 {
+		t.Errorf("got %d frames, want 2", n)
+	}
+}
+
+func TestFormatMismatch(t *testing.T) {
+	dir := t.TempDir()
+	writeTestImages(t, dir, testPattern(32, 32, 0), testPattern(32, 32, 60))
+	for _, tt := range []struct {
+		name, format string
+		warnings     []string
+	}{
+		{"movie.gif", formatApng, []string{"movie.gif is written as apng as -format is set, its .gif extension doesn't match"}},
+		{"movie.png", formatApng, nil},
+		{"movie", formatApng, nil},
+		{"movie.png", formatGif, []string{"movie.png is written as gif as -format is set, its .png extension doesn't match"}},
+	} {
+		out := filepath.Join(t.TempDir(), tt.name)
+		msg := gen(context.Background(), dir, out, Options{fps: 25, format: tt.format}, nil)().(resultMsg)
+		if msg.err != nil {
+			t.Fatal(msg.err)
+		}
+		got := []string{}
+		for _, w := range msg.warnings {
+			got = append(got, strings.TrimPrefix(w, filepath.Dir(out)+string(filepath.Separator)))
+		}
+		if !slices.Equal(got, tt.warnings) {
+			t.Errorf("%s as %s: got warnings %q, want %q", tt.name, tt.format, got, tt.warnings)
+		}
+		// the content is in the format whatever the extension
+		switch tt.format {
+		case formatApng:
+			data, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if frames, _, _ := apngInfo(t, data); frames != 2 {
+				t.Errorf("%s: got %d apng frames, want 2", tt.name, frames)
+			}
+		case formatGif:
+			if g := decodeTestGif(t, out); len(g.Image) != 2 {
+				t.Errorf("%s: got %d gif frames, want 2", tt.name, len(g.Image))
+			}
+		}
+	}
+}
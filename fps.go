@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// defaultFps is the frame rate used when it's not set.
+const defaultFps = 30
+
+// normalizeFps returns the default frame rate for 0 and rejects the ones gif delays can't represent,
+// delays are in 100ths of a second, so the frame rate should be from 1 to 100.
+func normalizeFps(fps int) (int, error) {
+	if fps == 0 {
+		return defaultFps, nil
+	}
+	if fps < 0 || fps > 100 {
+		return 0, fmt.Errorf("fps should be from 1 to 100, got %d", fps)
+	}
+	return fps, nil
+}
+
+// fpsAuto is the value of the fps flag to detect the frame rate from modification times of images.
+const fpsAuto = "auto"
+
+// fpsValue is a flag value for the frame rate, it's either a number or "auto".
+// @property {*int} fps - The frame rate, 0 if it's detected automatically.
+// @property {*bool} auto - Whether the frame rate is detected automatically.
+type fpsValue struct {
+	fps  *int
+	auto *bool
+}
+
+func (v fpsValue) String() string {
+	if v.auto != nil && *v.auto {
+		return fpsAuto
+	}
+	// the default frame rate is unset, so it's told apart from the one passed explicitly
+	if v.fps == nil || *v.fps == 0 {
+		return ""
+	}
+	return strconv.Itoa(*v.fps)
+}
+
+func (v fpsValue) Set(s string) error {
+	if s == fpsAuto {
+		*v.fps, *v.auto = 0, true
+		return nil
+	}
+	fps, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*v.fps, *v.auto = fps, false
+	return nil
+}
+
+// detectFps infers the frame rate from the median gap between modification times of files in a row.
+// Returns false if times can't be used, e.g. all files have the same time.
+func detectFps(files []string, opts Options) (int, bool) {
+	if len(files) < 2 {
+		return 0, false
+	}
+
+	times := make([]time.Time, len(files))
+	for i, f := range files {
+		fi, err := opts.stat(f)
+		if err != nil {
+			return 0, false
+		}
+		times[i] = fi.ModTime()
+	}
+
+	gaps := make([]time.Duration, len(times)-1)
+	for i := 1; i < len(times); i++ {
+		gaps[i-1] = times[i].Sub(times[i-1])
+	}
+	gap := medianDuration(gaps)
+	if gap <= 0 {
+		return 0, false
+	}
+
+	// gif delays are in 100ths of a second, so fps above 100 can't be represented.
+	fps := int(math.Round(float64(time.Second) / float64(gap)))
+	if fps < 1 {
+		fps = 1
+	}
+	if fps > 100 {
+		fps = 100
+	}
+	return fps, true
+}
+
+// medianDuration returns the median of durations, it sorts a copy of them.
+func medianDuration(d []time.Duration) time.Duration {
+	if len(d) == 0 {
+		return 0
+	}
+	s := append([]time.Duration{}, d...)
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	if len(s)%2 == 1 {
+		return s[len(s)/2]
+	}
+	return (s[len(s)/2-1] + s[len(s)/2]) / 2
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// timedFS returns a file system of n images modified the gaps apart in order, the last gap repeats.
+func timedFS(n int, gaps ...time.Duration) (fstest.MapFS, []string) {
+	fsys := fstest.MapFS{}
+	files := []string{}
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("%04d.png", i+1)
+		fsys[name] = &fstest.MapFile{ModTime: at}
+		files = append(files, name)
+		at = at.Add(gaps[min(i, len(gaps)-1)])
+	}
+	return fsys, files
+}
+
+func TestDetectFps(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		n     int
+		gaps  []time.Duration
+		fps   int
+		known bool
+	}{
+		{"40ms", 10, []time.Duration{40 * time.Millisecond}, 25, true},
+		{"100ms", 5, []time.Duration{100 * time.Millisecond}, 10, true},
+		// a pause doesn't change the median gap
+		{"pause", 6, []time.Duration{40 * time.Millisecond, 40 * time.Millisecond, 5 * time.Second, 40 * time.Millisecond}, 25, true},
+		{"slow", 3, []time.Duration{10 * time.Second}, 1, true},
+		{"fast", 3, []time.Duration{time.Millisecond}, 100, true},
+		{"equal times", 4, []time.Duration{0}, 0, false},
+		{"single file", 1, []time.Duration{time.Second}, 0, false},
+	} {
+		fsys, files := timedFS(tt.n, tt.gaps...)
+		fps, known := detectFps(files, Options{fsys: fsys})
+		if fps != tt.fps || known != tt.known {
+			t.Errorf("%s: got %d %v, want %d %v", tt.name, fps, known, tt.fps, tt.known)
+		}
+	}
+}
+
+func TestMedianDuration(t *testing.T) {
+	for _, tt := range []struct {
+		d    []time.Duration
+		want time.Duration
+	}{
+		{nil, 0},
+		{[]time.Duration{3, 1, 2}, 2},
+		{[]time.Duration{4, 1, 3, 2}, 2},
+	} {
+		if got := medianDuration(tt.d); got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestFpsFlag(t *testing.T) {
+	cfg := parseTestFlags(t, "-fps", "auto")
+	if !cfg.opts.autoFps || cfg.opts.fps != 0 {
+		t.Errorf("auto: got fps %d and auto %v", cfg.opts.fps, cfg.opts.autoFps)
+	}
+	cfg = parseTestFlags(t, "-fps", "12")
+	if cfg.opts.autoFps || cfg.opts.fps != 12 {
+		t.Errorf("12: got fps %d and auto %v", cfg.opts.fps, cfg.opts.autoFps)
+	}
+	parseUsageError(t, "-fps", "fast")
+}
+
+func TestAutoFpsBuild(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPngs(t, dir, 4, 4, testRed, testGreen, testBlue)
+	at := time.Now()
+	for n := 1; n <= 3; n++ {
+		mtime := at.Add(time.Duration(n) * 50 * time.Millisecond)
+		if err := os.Chtimes(filepath.Join(dir, fmt.Sprintf("%04d.png", n)), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := filepath.Join(dir, "out.gif")
+	if msg := gen(context.Background(), dir, out, Options{autoFps: true}, nil)().(resultMsg); msg.err != nil {
+		t.Fatal(msg.err)
+	}
+	// 20 fps is 5 100ths of a second per frame
+	if g := decodeTestGif(t, out); !slices.Equal(g.Delay, []int{5, 5, 5}) {
+		t.Errorf("got delays %v, want 5 each", g.Delay)
+	}
+}
+
+func TestNormalizeFps(t *testing.T) {
+	for _, tt := range []struct {
+		fps, want int
+		err       bool
+	}{
+		{0, defaultFps, false},
+		{1, 1, false},
+		{25, 25, false},
+		{100, 100, false},
+		{-1, 0, true},
+		{-30, 0, true},
+		{101, 0, true},
+	} {
+		got, err := normalizeFps(tt.fps)
+		if got != tt.want || (err != nil) != tt.err {
+			t.Errorf("%d: got %d, %v, want %d, error %v", tt.fps, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestBuildGifFps(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPngs(t, dir, 8, 8, testRed, testBlue)
+	files, err := listFiles(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out.gif")
+	// 0 fps plays at the default rate
+	if err := BuildGif(context.Background(), files, out, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	g := decodeTestGif(t, out)
+	for _, d := range g.Delay {
+		if d != 100/defaultFps {
+			t.Errorf("got delays %v, want %d", g.Delay, 100/defaultFps)
+			break
+		}
+	}
+	for _, fps := range []int{-1, -25, 101} {
+		if err := BuildGif(context.Background(), files, out, Options{fps: fps}); err == nil {
+			t.Errorf("%d: got no error", fps)
+		}
+	}
+	parseUsageError(t, "-fps", "-5")
+}
+
+func TestDelayMs(t *testing.T) {
+	reps := []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}
+	for _, tt := range []struct {
+		ms   int
+		want []int
+	}{
+		{16, []int{2, 1, 2, 1, 2, 2, 1, 2, 1, 2}},
+		{33, []int{3, 4, 3, 3, 4, 3, 3, 3, 4, 3}},
+		{40, []int{4, 4, 4, 4, 4, 4, 4, 4, 4, 4}},
+		{100, []int{10, 10, 10, 10, 10, 10, 10, 10, 10, 10}},
+	} {
+		got := frameDelays(reps, 3, Options{delayMs: tt.ms})
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%dms: got %v, want %v", tt.ms, got, tt.want)
+		}
+		// every frame ends at the closest 100th of a second to its time, so the animation doesn't drift
+		shown := 0
+		for n, d := range got {
+			shown += d
+			if diff := shown*10 - (n+1)*tt.ms; diff > 5 || diff < -5 {
+				t.Errorf("%dms: frame %d ends at %dms, want %dms", tt.ms, n, shown*10, (n+1)*tt.ms)
+			}
+		}
+	}
+	// merged frames stand for several images
+	if got := frameDelays([]int{3, 1, 2}, 3, Options{delayMs: 33}); !slices.Equal(got, []int{10, 3, 7}) {
+		t.Errorf("got %v, want [10 3 7]", got)
+	}
+
+	dir := t.TempDir()
+	writeTestImages(t, dir, testPattern(32, 32, 0), testPattern(32, 32, 60), testPattern(32, 32, 120))
+	for _, tt := range []struct {
+		ms       int
+		warnings []string
+	}{
+		{40, nil},
+		{33, []string{"33ms can't be represented exactly in 100ths of a second, delays of frames alternate to keep the total time"}},
+		{16, []string{
+			"16ms can't be represented exactly in 100ths of a second, delays of frames alternate to keep the total time",
+			"browsers slow down frames shorter than 20ms, use -delay-ms 20 or more",
+		}},
+	} {
+		out := filepath.Join(t.TempDir(), "out.gif")
+		msg := gen(context.Background(), dir, out, parseTestFlags(t, "-delay-ms", strconv.Itoa(tt.ms)).opts, nil)().(resultMsg)
+		if msg.err != nil {
+			t.Fatal(msg.err)
+		}
+		if !slices.Equal(msg.warnings, tt.warnings) {
+			t.Errorf("%dms: got warnings %q, want %q", tt.ms, msg.warnings, tt.warnings)
+		}
+		if got, want := decodeTestGif(t, out).Delay, frameDelays([]int{1, 1, 1}, 3, Options{delayMs: tt.ms}); !slices.Equal(got, want) {
+			t.Errorf("%dms: got delays %v, want %v", tt.ms, got, want)
+		}
+	}
+	parseUsageError(t, "-delay-ms", "-40")
+}
+
+func TestSpeed(t *testing.T) {
+	for _, tt := range []struct {
+		delays []int
+		speed  float64
+		want   []int
+	}{
+		{[]int{4, 4, 8, 4}, 2, []int{2, 2, 4, 2}},
+		{[]int{4, 4, 8, 4}, 0.5, []int{8, 8, 16, 8}},
+		// odd delays alternate to keep the total time
+		{[]int{3, 3, 3, 3}, 2, []int{2, 1, 2, 1}},
+		// delays stay from 1 to the max of a gif
+		{[]int{4, 4}, 100, []int{1, 1}},
+		{[]int{100}, 0.001, []int{maxGifDelay}},
+	} {
+		if got := scaleDelays(tt.delays, tt.speed); !slices.Equal(got, tt.want) {
+			t.Errorf("%v at %gx: got %v, want %v", tt.delays, tt.speed, got, tt.want)
+		}
+	}
+
+	// merged frames are scaled with their counts, holds are kept
+	if got := frameDelays([]int{3, 1, 1}, 4, Options{speed: 2}); !slices.Equal(got, []int{6, 2, 2}) {
+		t.Errorf("got %v, want [6 2 2]", got)
+	}
+	if got := frameDelays([]int{1, 1, 1}, 4, Options{speed: 0.5, firstHold: 50}); !slices.Equal(got, []int{50, 8, 8}) {
+		t.Errorf("got %v, want [50 8 8]", got)
+	}
+
+	dir := t.TempDir()
+	writeTestImages(t, dir, testPattern(32, 32, 0), testPattern(32, 32, 60), testPattern(32, 32, 120))
+	for speed, want := range map[string][]int{"2": {2, 2, 2}, "0.5": {8, 8, 8}} {
+		out := filepath.Join(t.TempDir(), "out.gif")
+		msg := gen(context.Background(), dir, out, parseTestFlags(t, "-fps", "25", "-speed", speed).opts, nil)().(resultMsg)
+		if msg.err != nil {
+			t.Fatal(msg.err)
+		}
+		if got := decodeTestGif(t, out).Delay; !slices.Equal(got, want) {
+			t.Errorf("%sx: got delays %v, want %v", speed, got, want)
+		}
+	}
+	parseUsageError(t, "-speed", "0")
+	parseUsageError(t, "-speed", "-2")
+}
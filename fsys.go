@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// open opens the file from the file system in options, or from the os one if there is none.
+// URLs are fetched over http.
+func (o Options) open(name string) (fs.File, error) {
+	if isURL(name) {
+		return openURL(name, o)
+	}
+	if o.fsys != nil {
+		return o.fsys.Open(filepath.ToSlash(name))
+	}
+	return os.Open(name)
+}
+
+// stat returns the file info from the file system in options, or from the os one if there is none.
+func (o Options) stat(name string) (fs.FileInfo, error) {
+	if o.fsys != nil {
+		return fs.Stat(o.fsys, filepath.ToSlash(name))
+	}
+	return os.Stat(name)
+}
+
+// readFile reads the file from the file system in options, or from the os one if there is none.
+func (o Options) readFile(name string) ([]byte, error) {
+	if isURL(name) {
+		f, err := openURL(name, o)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return io.ReadAll(f)
+	}
+	if o.fsys != nil {
+		return fs.ReadFile(o.fsys, filepath.ToSlash(name))
+	}
+	return os.ReadFile(name)
+}
+
+// glob returns paths matching the pattern in lexical order from the file system in options, or from the os one.
+func (o Options) glob(pattern string) ([]string, error) {
+	if o.fsys != nil {
+		return fs.Glob(o.fsys, filepath.ToSlash(pattern))
+	}
+	return filepath.Glob(pattern)
+}
+
+// createFile creates the output file with the factory in options, or in the os file system if there is none.
+func (o Options) createFile(name string) (io.WriteCloser, error) {
+	if o.create != nil {
+		return o.create(name)
+	}
+	return os.Create(name)
+}
+
+// removeFile removes a partially written output, outputs of the factory in options are left to its owner.
+func (o Options) removeFile(name string) {
+	if o.create == nil {
+		os.Remove(name)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/gif"
+	"image/png"
+	"io"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+// memFiles keeps outputs of the create factory in memory.
+type memFiles map[string]*memFile
+
+func (m memFiles) create(name string) (io.WriteCloser, error) {
+	f := &memFile{}
+	m[name] = f
+	return f, nil
+}
+
+func TestPipelineInMemory(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for n, img := range []image.Image{testPattern(32, 32, 0), testPattern(32, 32, 0), testPattern(32, 32, 60)} {
+		b := bytes.Buffer{}
+		if err := png.Encode(&b, img); err != nil {
+			t.Fatal(err)
+		}
+		fsys[[]string{"frames/a.png", "frames/b.png", "frames/c.png"}[n]] = &fstest.MapFile{Data: b.Bytes()}
+	}
+	fsys["frames/notes.txt"] = &fstest.MapFile{Data: []byte("not an image")}
+
+	outputs := memFiles{}
+	opts := Options{fsys: fsys, create: outputs.create}
+	files, err := listFiles("frames", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(*files, []string{"frames/a.png", "frames/b.png", "frames/c.png"}) {
+		t.Fatalf("got files %v", *files)
+	}
+	// the output folder doesn't exist on disk, the gif is only written to the factory
+	if err := BuildGif(context.Background(), files, "missing/out.gif,missing/out.apng", opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 2 || !outputs["missing/out.gif"].closed || !outputs["missing/out.apng"].closed {
+		t.Fatalf("got outputs %v, want out.gif and out.apng closed", outputs)
+	}
+	g, err := gif.DecodeAll(&outputs["missing/out.gif"].Buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(g.Delay, []int{6, 3}) {
+		t.Errorf("got delays %v, want [6 3]", g.Delay)
+	}
+	if frames, _, _ := apngInfo(t, outputs["missing/out.apng"].Bytes()); frames != 2 {
+		t.Errorf("got %d apng frames, want 2", frames)
+	}
+
+	// missing files are reported by their paths in the file system
+	delete(fsys, "frames/b.png")
+	if _, err := readImages(context.Background(), &[]string{"frames/a.png", "frames/b.png"}, opts); err == nil {
+		t.Error("got no error for a missing file")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// modes to handle gaps in numbered frames.
+const (
+	gapIgnore = "ignore"
+	gapError  = "error"
+	gapWarn   = "warn"
+	gapHold   = "hold"
+)
+
+// frameNumber matches the last number in a file name, e.g. 12 in frame_012.png.
+var frameNumber = regexp.MustCompile(`(\d+)\D*$`)
+
+// frameIndex returns the number in the file name without its extension, false if there is none.
+func frameIndex(file string) (int, bool) {
+	name := filepath.Base(file)
+	m := frameNumber.FindStringSubmatch(strings.TrimSuffix(name, filepath.Ext(name)))
+	if m == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(m[1])
+	return n, err == nil
+}
+
+// frameGaps returns the number of missing frames after each file by numbers in file names,
+// nil if some file has no number, so gaps can't be detected.
+func frameGaps(files []string) []int {
+	gaps := make([]int, len(files))
+	prev := 0
+	for i, f := range files {
+		n, ok := frameIndex(f)
+		if !ok {
+			return nil
+		}
+		if i > 0 && n > prev+1 {
+			gaps[i-1] = n - prev - 1
+		}
+		prev = n
+	}
+	return gaps
+}
+
+// gapHolds checks gaps in numbered frames in the mode from options,
+// returns the number of frames each file is held for in place of missing ones in the hold mode.
+func gapHolds(files []string, opts Options) ([]int, error) {
+	if opts.onGap == "" || opts.onGap == gapIgnore {
+		return nil, nil
+	}
+	gaps := frameGaps(files)
+	missing := 0
+	for i, g := range gaps {
+		if g == 0 {
+			continue
+		}
+		missing += g
+		if opts.onGap == gapError {
+			return nil, fmt.Errorf("%d frame(s) missing after %s", g, files[i])
+		}
+	}
+	if missing == 0 {
+		return nil, nil
+	}
+	if opts.onGap == gapWarn {
+		opts.warnf("%d frame(s) missing in the numbered sequence", missing)
+		return nil, nil
+	}
+	return gaps, nil
+}
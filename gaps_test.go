@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"image/color"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFrameIndex(t *testing.T) {
+	for _, tt := range []struct {
+		file string
+		n    int
+		ok   bool
+	}{
+		{"frames/frame_012.png", 12, true},
+		{"0007.jpg", 7, true},
+		{"take2_frame10_final.png", 10, true},
+		{"cover.png", 0, false},
+	} {
+		if n, ok := frameIndex(tt.file); n != tt.n || ok != tt.ok {
+			t.Errorf("%s: got %d, %v, want %d, %v", tt.file, n, ok, tt.n, tt.ok)
+		}
+	}
+	if gaps := frameGaps([]string{"a1.png", "a4.png", "a5.png", "a7.png"}); !slices.Equal(gaps, []int{2, 0, 1, 0}) {
+		t.Errorf("got gaps %v, want [2 0 1 0]", gaps)
+	}
+	if gaps := frameGaps([]string{"a1.png", "cover.png", "a5.png"}); gaps != nil {
+		t.Errorf("got gaps %v of files without numbers", gaps)
+	}
+}
+
+func TestOnGap(t *testing.T) {
+	// frame_002 is missing
+	dir := t.TempDir()
+	for name, c := range map[string]color.Color{"frame_001.png": testRed, "frame_003.png": testGreen, "frame_004.png": testBlue} {
+		if err := os.WriteFile(filepath.Join(dir, name), testPng(t, testFrame(8, 8, c)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := listFiles(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		mode     string
+		delays   []int
+		warnings []string
+		err      string
+	}{
+		{gapIgnore, []int{1, 1, 1}, nil, ""},
+		{"", []int{1, 1, 1}, nil, ""},
+		{gapWarn, []int{1, 1, 1}, []string{"1 frame(s) missing in the numbered sequence"}, ""},
+		{gapHold, []int{2, 1, 1}, nil, ""},
+		{gapError, nil, nil, "1 frame(s) missing after " + filepath.Join(dir, "frame_001.png")},
+	} {
+		warnings := []string(nil)
+		opts := Options{onGap: tt.mode, warn: func(msg string) { warnings = append(warnings, msg) }}
+		frames, err := readImages(context.Background(), files, opts)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%q: got %v, want an error about %s", tt.mode, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := frameDelaysOf(frames); !slices.Equal(got, tt.delays) {
+			t.Errorf("%q: got delays %v, want %v", tt.mode, got, tt.delays)
+		}
+		if !slices.Equal(warnings, tt.warnings) {
+			t.Errorf("%q: got warnings %q, want %q", tt.mode, warnings, tt.warnings)
+		}
+	}
+	parseUsageError(t, "-on-gap", "skip")
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// isGif checks if the path points to a gif by its extension.
+func isGif(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".gif"
+}
+
+// extractGifFrames decodes frames of an animated gif into a temporary folder of png images,
+// and returns the delays of frames in 100ths of a second by paths of their images.
+// The caller should remove the folder when it's done with the frames.
+func extractGifFrames(path string, opts Options) (string, map[string]int, error) {
+	data, err := opts.readFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, &fileError{"decode gif", path, err}
+	}
+
+	dir, err := os.MkdirTemp("", "png2gif-frames-")
+	if err != nil {
+		return "", nil, err
+	}
+	delays := make(map[string]int, len(g.Image))
+	enc := png.Encoder{CompressionLevel: png.BestSpeed}
+	for n, frame := range composeGifFrames(g) {
+		name := filepath.Join(dir, fmt.Sprintf("frame_%06d.png", n+1))
+		if err := writePng(&enc, name, frame); err != nil {
+			os.RemoveAll(dir)
+			return "", nil, err
+		}
+		delays[name] = g.Delay[n]
+	}
+	return dir, delays, nil
+}
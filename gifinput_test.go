@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeTestGif writes an animated gif of distinct frames with the delays.
+func writeTestGif(t *testing.T, path string, delays ...int) {
+	t.Helper()
+	g := &gif.GIF{}
+	for n, d := range delays {
+		p := image.NewPaletted(image.Rect(0, 0, 32, 32), palette.Plan9)
+		draw.Draw(p, p.Rect, testPattern(32, 32, n*60), image.Point{}, draw.Src)
+		g.Image = append(g.Image, p)
+		g.Delay = append(g.Delay, d)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := gif.EncodeAll(f, g); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPreserveTiming(t *testing.T) {
+	in := filepath.Join(t.TempDir(), "in.gif")
+	delays := []int{3, 10, 7, 25}
+	writeTestGif(t, in, delays...)
+
+	for _, tt := range []struct {
+		args []string
+		want []int
+	}{
+		{[]string{"-preserve-timing"}, delays},
+		{[]string{"-preserve-timing", "-fps", "10"}, delays},
+		{[]string{"-fps", "25"}, []int{4, 4, 4, 4}},
+	} {
+		out := filepath.Join(t.TempDir(), "out.gif")
+		msg := gen(context.Background(), in, out, parseTestFlags(t, tt.args...).opts, nil)().(resultMsg)
+		if msg.err != nil {
+			t.Fatal(msg.err)
+		}
+		if got := decodeTestGif(t, out).Delay; !slices.Equal(got, tt.want) {
+			t.Errorf("%v: got delays %v, want %v", tt.args, got, tt.want)
+		}
+	}
+
+	// folders of images have no delays to keep
+	dir := t.TempDir()
+	writeTestImages(t, dir, testPattern(32, 32, 0), testPattern(32, 32, 60))
+	msg := gen(context.Background(), dir, filepath.Join(t.TempDir(), "out.gif"), Options{preserveTiming: true, fps: 25}, nil)().(resultMsg)
+	if msg.err != nil {
+		t.Fatal(msg.err)
+	}
+	if want := []string{"-preserve-timing needs an animated gif input, frames play at the frame rate"}; !slices.Equal(msg.warnings, want) {
+		t.Errorf("got warnings %q, want %q", msg.warnings, want)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/gif"
+	"io"
+)
+
+// gifHeaderLen is the length of the gif header and the logical screen descriptor
+// written by the standard encoder before the first image block, when there is no global color table.
+const gifHeaderLen = 13
+
+// gifStreamWriter writes a gif frame by frame, instead of building the whole gif.GIF in memory.
+// The standard encoder doesn't stream, so each frame is encoded as a single frame gif and its image block is copied.
+// @property w - The writer to write the gif to.
+// @property {image.Config} config - The logical screen size of the gif.
+// @property {bool} interlace - Whether frames are interlaced to show progressively while loading.
+// @property {error} err - The first error that happened while writing, next writes are skipped.
+type gifStreamWriter struct {
+	w         io.Writer
+	config    image.Config
+	interlace bool
+	err       error
+}
+
+// newGifStreamWriter writes the gif header with the logical screen size and the loop extension for animations,
+// loopCount is as in gif.GIF, 0 loops forever and -1 plays once.
+func newGifStreamWriter(w io.Writer, width, height, frames, loopCount int) *gifStreamWriter {
+	s := &gifStreamWriter{w: w, config: image.Config{Width: width, Height: height}}
+
+	header := make([]byte, gifHeaderLen)
+	copy(header, "GIF89a")
+	binary.LittleEndian.PutUint16(header[6:8], uint16(width))
+	binary.LittleEndian.PutUint16(header[8:10], uint16(height))
+	s.write(header)
+
+	// the same netscape extension the standard encoder writes to loop an animation.
+	if frames > 1 && loopCount >= 0 {
+		s.write([]byte{0x21, 0xff, 0x0b})
+		s.write([]byte("NETSCAPE2.0"))
+		s.write([]byte{0x03, 0x01, byte(loopCount), byte(loopCount >> 8), 0x00})
+	}
+	return s
+}
+
+// write writes bytes to the underlying writer if there were no errors.
+func (s *gifStreamWriter) write(b []byte) {
+	if s.err != nil {
+		return
+	}
+	_, s.err = s.w.Write(b)
+}
+
+// writeFrame encodes a frame with the delay in 100ths of a second and the disposal method and appends it to the gif.
+func (s *gifStreamWriter) writeFrame(p *image.Paletted, delay int, disposal byte) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.interlace {
+		p = interlaceRows(p)
+	}
+	b := bytes.Buffer{}
+	s.err = gif.EncodeAll(&b, &gif.GIF{
+		Image:    []*image.Paletted{p},
+		Delay:    []int{delay},
+		Disposal: []byte{disposal},
+		Config:   s.config,
+	})
+	if s.err != nil {
+		return s.err
+	}
+	// skip the header and the trailer of the single frame gif.
+	block := b.Bytes()[gifHeaderLen : b.Len()-1]
+	if s.interlace {
+		setInterlaceFlag(block)
+	}
+	s.write(block)
+	return s.err
+}
+
+// gifInterlacePasses are the first rows and steps of rows of the four interlace passes.
+var gifInterlacePasses = []struct{ start, step int }{{0, 8}, {4, 8}, {2, 4}, {1, 2}}
+
+// interlaceRows returns a copy of the frame with rows in the order of interlace passes,
+// the standard encoder writes rows as they are, so decoders put them back in place by the interlace flag.
+func interlaceRows(p *image.Paletted) *image.Paletted {
+	b := p.Bounds()
+	dst := image.NewPaletted(b, p.Palette)
+	row := 0
+	for _, pass := range gifInterlacePasses {
+		for y := pass.start; y < b.Dy(); y += pass.step {
+			copy(dst.Pix[row*dst.Stride:row*dst.Stride+b.Dx()], p.Pix[y*p.Stride:y*p.Stride+b.Dx()])
+			row++
+		}
+	}
+	return dst
+}
+
+// setInterlaceFlag sets the interlace bit of the image descriptor in the image block,
+// the block starts with an optional graphic control extension.
+func setInterlaceFlag(block []byte) {
+	i := 0
+	if len(block) > 0 && block[0] == 0x21 {
+		i = 8
+	}
+	// image separator, left, top, width and height are followed by the packed fields.
+	if len(block) > i+9 && block[i] == 0x2c {
+		block[i+9] |= 0x40
+	}
+}
+
+// gifCommentWriter inserts a comment extension after the header of the gif written through it,
+// the standard encoder doesn't write comments.
+// @property w - The writer to write the gif to.
+// @property {string} comment - The text of the comment.
+// @property {[]byte} header - The bytes of the header written so far, until it's complete.
+// @property {bool} done - Whether the comment is written and the rest is passed as is.
+type gifCommentWriter struct {
+	w       io.Writer
+	comment string
+	header  []byte
+	done    bool
+}
+
+func (c *gifCommentWriter) Write(p []byte) (int, error) {
+	if c.done {
+		return c.w.Write(p)
+	}
+	c.header = append(c.header, p...)
+	// the header is followed by the global color table if its flag is set
+	size := gifHeaderLen
+	if len(c.header) >= gifHeaderLen && c.header[10]&0x80 != 0 {
+		size += 3 << (c.header[10]&0x07 + 1)
+	}
+	if len(c.header) < size {
+		return len(p), nil
+	}
+	c.done = true
+
+	b := bytes.Buffer{}
+	b.Write(c.header[:size])
+	b.Write([]byte{0x21, 0xfe})
+	// the text is split into sub-blocks of up to 255 bytes
+	for s := []byte(c.comment); len(s) > 0; {
+		n := len(s)
+		if n > 255 {
+			n = 255
+		}
+		b.WriteByte(byte(n))
+		b.Write(s[:n])
+		s = s[n:]
+	}
+	b.WriteByte(0x00)
+	b.Write(c.header[size:])
+	if _, err := c.w.Write(b.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// close writes the gif trailer.
+func (s *gifStreamWriter) close() error {
+	s.write([]byte{0x3b})
+	return s.err
+}
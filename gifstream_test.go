@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/gif"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// encodeTestGif encodes the frames as a gif with the options and decodes it.
+func encodeTestGif(t *testing.T, images []image.Image, opts Options) *gif.GIF {
+	t.Helper()
+	frames := framesOf(images...)
+	for n := range frames {
+		frames[n].delay = n%2 + 1
+	}
+	b := bytes.Buffer{}
+	if err := encodeTo(context.Background(), &b, formatGif, &frames, 4, opts); err != nil {
+		t.Fatal(err)
+	}
+	g, err := gif.DecodeAll(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// gifsEqual checks that gifs show the same frames for the same time.
+func gifsEqual(t *testing.T, got, want *gif.GIF) {
+	t.Helper()
+	if len(got.Image) != len(want.Image) {
+		t.Fatalf("got %d frames, want %d", len(got.Image), len(want.Image))
+	}
+	if !slices.Equal(got.Delay, want.Delay) {
+		t.Errorf("got delays %v, want %v", got.Delay, want.Delay)
+	}
+	if got.LoopCount != want.LoopCount {
+		t.Errorf("got loop count %d, want %d", got.LoopCount, want.LoopCount)
+	}
+	for n := range want.Image {
+		a, b := got.Image[n], want.Image[n]
+		if a.Bounds() != b.Bounds() {
+			t.Fatalf("frame %d: got bounds %v, want %v", n, a.Bounds(), b.Bounds())
+		}
+		for y := b.Rect.Min.Y; y < b.Rect.Max.Y; y++ {
+			for x := b.Rect.Min.X; x < b.Rect.Max.X; x++ {
+				if !colorsEqual(a.At(x, y), b.At(x, y)) {
+					t.Fatalf("frame %d: got %v at %d,%d, want %v", n, a.At(x, y), x, y, b.At(x, y))
+				}
+			}
+		}
+	}
+}
+
+func TestStreamEqualsBatch(t *testing.T) {
+	images := []image.Image{testPattern(24, 16, 0), testPattern(24, 16, 60), testGradient(24, 16, testBlue)}
+	for _, loopCount := range []int{0, -1, 3} {
+		batch := encodeTestGif(t, images, Options{loopCount: loopCount})
+		stream := encodeTestGif(t, images, Options{loopCount: loopCount, stream: true})
+		gifsEqual(t, stream, batch)
+	}
+}
+
+// gifInterlaceFlags returns the interlace bit of each image descriptor of the gif data.
+func gifInterlaceFlags(t *testing.T, data []byte) []bool {
+	t.Helper()
+	skipBlocks := func(i int) int {
+		end, err := skipSubBlocks(data, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return end
+	}
+	// header and logical screen descriptor, followed by the global color table if there is one
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&7 + 1)
+	}
+	flags := []bool{}
+	for i < len(data) {
+		switch data[i] {
+		case 0x21:
+			i = skipBlocks(i + 2)
+		case 0x2c:
+			packed := data[i+9]
+			flags = append(flags, packed&0x40 != 0)
+			i += 10
+			if packed&0x80 != 0 {
+				i += 3 << (packed&7 + 1)
+			}
+			// the lzw minimum code size precedes the image data
+			i = skipBlocks(i + 1)
+		case 0x3b:
+			return flags
+		default:
+			t.Fatalf("unexpected block 0x%02x at %d", data[i], i)
+		}
+	}
+	t.Fatal("gif has no trailer")
+	return nil
+}
+
+func TestInterlace(t *testing.T) {
+	// 13 rows aren't a multiple of any pass step
+	images := []image.Image{testPattern(24, 13, 0), testPattern(24, 13, 60), testGradient(24, 13, testBlue)}
+	frames := framesOf(images...)
+	for _, interlace := range []bool{false, true} {
+		b := bytes.Buffer{}
+		if err := encodeTo(context.Background(), &b, formatGif, &frames, 4, Options{interlace: interlace}); err != nil {
+			t.Fatal(err)
+		}
+		flags := gifInterlaceFlags(t, b.Bytes())
+		if len(flags) != len(images) {
+			t.Fatalf("got %d image descriptors, want %d", len(flags), len(images))
+		}
+		for n, f := range flags {
+			if f != interlace {
+				t.Errorf("interlace %v: frame %d has the flag %v", interlace, n, f)
+			}
+		}
+	}
+	// decoders put interlaced rows back in place
+	gifsEqual(t, encodeTestGif(t, images, Options{interlace: true}), encodeTestGif(t, images, Options{}))
+
+	cfg := parseTestFlags(t, "-interlace")
+	if !cfg.opts.interlace {
+		t.Error("-interlace isn't set")
+	}
+}
+
+// gifComment returns the text of the comment extension right after the header of the gif data.
+func gifComment(t *testing.T, data []byte) (string, bool) {
+	t.Helper()
+	i := gifHeaderLen
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&7 + 1)
+	}
+	if data[i] != 0x21 || data[i+1] != 0xfe {
+		return "", false
+	}
+	text := []byte{}
+	for i += 2; data[i] != 0; i += int(data[i]) + 1 {
+		text = append(text, data[i+1:i+1+int(data[i])]...)
+	}
+	return string(text), true
+}
+
+func TestStreamRejectsGlobalPalette(t *testing.T) {
+	parseUsageError(t, "-stream", "-palette-mode", "global")
+	parseUsageError(t, "-interlace", "-palette-mode", "global")
+}
+
+func TestCommentFps(t *testing.T) {
+	cases := []struct {
+		opts Options
+		want string
+	}{
+		{Options{commentFps: true, fps: 25}, "png2gif fps=25"},
+		{Options{commentFps: true}, fmt.Sprintf("png2gif fps=%d", defaultFps)},
+	}
+	for _, c := range cases {
+		b := bytes.Buffer{}
+		if err := encodeTo(context.Background(), &b, formatGif, testFrames(8, 8, testRed, testBlue), 3, c.opts); err != nil {
+			t.Fatal(err)
+		}
+		comment, ok := gifComment(t, b.Bytes())
+		if !ok || comment != c.want {
+			t.Errorf("fps %d: got comment %q (found %v), want %q", c.opts.fps, comment, ok, c.want)
+		}
+		g, err := gif.DecodeAll(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(g.Image) != 2 {
+			t.Errorf("fps %d: got %d frames, want 2", c.opts.fps, len(g.Image))
+		}
+	}
+
+	b := bytes.Buffer{}
+	if err := encodeTo(context.Background(), &b, formatGif, testFrames(8, 8, testRed, testBlue), 3, Options{fps: 25}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := gifComment(t, b.Bytes()); ok {
+		t.Error("comment is written without -comment-fps")
+	}
+
+	cfg := parseTestFlags(t, "-comment-fps", "-fps", "12")
+	if !cfg.opts.commentFps || cfg.opts.fps != 12 {
+		t.Errorf("got commentFps %v and fps %d, want true and 12", cfg.opts.commentFps, cfg.opts.fps)
+	}
+}
+
+func TestGifCommentWriterLongComment(t *testing.T) {
+	// the comment is split into sub-blocks and the header may arrive in pieces
+	comment := strings.Repeat("x", 600)
+	src := bytes.Buffer{}
+	if err := encodeTo(context.Background(), &src, formatGif, testFrames(4, 4, testRed, testGreen), 2, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	b := bytes.Buffer{}
+	w := &gifCommentWriter{w: &b, comment: comment}
+	for data := src.Bytes(); len(data) > 0; {
+		n := min(len(data), 5)
+		if _, err := w.Write(data[:n]); err != nil {
+			t.Fatal(err)
+		}
+		data = data[n:]
+	}
+	if got, ok := gifComment(t, b.Bytes()); !ok || got != comment {
+		t.Errorf("got comment of %d bytes (found %v), want %d bytes", len(got), ok, len(comment))
+	}
+	if _, err := gif.DecodeAll(&b); err != nil {
+		t.Fatal(err)
+	}
+}
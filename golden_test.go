@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files of encoders in testdata with the current outputs")
+
+// goldenCase builds an output from fixed synthetic frames.
+// @property {string} name - The name of the golden file in testdata.
+// @property {func(t *testing.T) []byte} build - Builds the output.
+type goldenCase struct {
+	name  string
+	build func(t *testing.T) []byte
+}
+
+// goldenColors are colors of frames of golden cases.
+var goldenColors = []color.Color{testRed, testGreen, testBlue, color.RGBA{255, 200, 0, 255}}
+
+var goldenCases = []goldenCase{
+	{"basic.gif", func(t *testing.T) []byte {
+		return goldenEncode(t, formatGif, Options{}, goldenColors...)
+	}},
+	{"basic.apng", func(t *testing.T) []byte {
+		return goldenEncode(t, formatApng, Options{}, goldenColors...)
+	}},
+	// equal images in a row are merged into one frame holding for all of them
+	{"dedup.gif", func(t *testing.T) []byte {
+		dir := t.TempDir()
+		writeTestImages(t, dir, goldenFrames(testRed, testRed, testRed, testGreen, testBlue, testBlue)...)
+		files, err := listFiles(dir, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := filepath.Join(dir, "out.gif")
+		if err := BuildGif(context.Background(), files, out, Options{minFrames: 2}); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}},
+}
+
+// TestGolden compares outputs of encoders byte by byte with golden files, go test -run Golden -update rewrites them.
+func TestGolden(t *testing.T) {
+	for _, c := range goldenCases {
+		t.Run(c.name, func(t *testing.T) {
+			got := c.build(t)
+			path := filepath.Join("testdata", c.name)
+			if *update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v, run go test -run Golden -update to create it", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("output differs from %s: got %d bytes, want %d, run go test -run Golden -update if the change is expected", path, len(got), len(want))
+			}
+		})
+	}
+}
+
+// goldenFrames returns gradient frames in the colors, so palettes of frames matter.
+func goldenFrames(colors ...color.Color) []image.Image {
+	images := []image.Image{}
+	for _, c := range colors {
+		images = append(images, testGradient(24, 16, c))
+	}
+	return images
+}
+
+// goldenEncode encodes gradient frames in the colors in the format.
+func goldenEncode(t *testing.T, format string, opts Options, colors ...color.Color) []byte {
+	t.Helper()
+	images := framesOf(goldenFrames(colors...)...)
+	b := bytes.Buffer{}
+	if err := encodeTo(context.Background(), &b, format, &images, 10, opts); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
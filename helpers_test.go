@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// testFrame returns a w x h image filled with c.
+func testFrame(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), &image.Uniform{c}, image.Point{}, draw.Src)
+	return img
+}
+
+// framesOf returns frames of the images, each standing for a single source image named a, b, c and so on.
+func framesOf(images ...image.Image) []imgWithDelay {
+	frames := make([]imgWithDelay, len(images))
+	for n, img := range images {
+		frames[n] = imgWithDelay{img: img, delay: 1, sources: []string{string(rune('a' + n))}}
+	}
+	return frames
+}
+
+// testFrames returns frames of one frame each in the colors.
+func testFrames(w, h int, colors ...color.Color) *[]imgWithDelay {
+	images := []image.Image{}
+	for _, c := range colors {
+		images = append(images, testFrame(w, h, c))
+	}
+	frames := framesOf(images...)
+	return &frames
+}
+
+var (
+	testRed   = color.RGBA{255, 0, 0, 255}
+	testGreen = color.RGBA{0, 255, 0, 255}
+	testBlue  = color.RGBA{0, 0, 255, 255}
+)
+
+// testGradient returns a w x h image fading from c on the left to black on the right.
+func testGradient(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	r, g, b, _ := c.RGBA()
+	for x := 0; x < w; x++ {
+		k := uint32(w - x)
+		shade := color.RGBA{uint8(r * k / uint32(w) >> 8), uint8(g * k / uint32(w) >> 8), uint8(b * k / uint32(w) >> 8), 255}
+		for y := 0; y < h; y++ {
+			img.Set(x, y, shade)
+		}
+	}
+	return img
+}
+
+// testPng encodes the image as a png.
+func testPng(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	b := bytes.Buffer{}
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// writeTestPngs writes pngs of frames in the colors to the dir, named 0001.png, 0002.png and so on.
+func writeTestPngs(t *testing.T, dir string, w, h int, colors ...color.Color) {
+	t.Helper()
+	images := []image.Image{}
+	for _, c := range colors {
+		images = append(images, testFrame(w, h, c))
+	}
+	writeTestImages(t, dir, images...)
+}
+
+// writeTestImages writes the images to the dir as pngs, named 0001.png, 0002.png and so on.
+func writeTestImages(t *testing.T, dir string, images ...image.Image) {
+	t.Helper()
+	for n, img := range images {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%04d.png", n+1)), testPng(t, img), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readTestImages writes the images to a temporary folder and reads them as frames with the options.
+func readTestImages(t *testing.T, opts Options, images ...image.Image) []imgWithDelay {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestImages(t, dir, images...)
+	files, err := listFiles(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames, err := readImages(context.Background(), files, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return frames
+}
+
+// frameDelaysOf returns delays of the frames in numbers of source images.
+func frameDelaysOf(frames []imgWithDelay) []int {
+	delays := []int{}
+	for _, f := range frames {
+		delays = append(delays, f.delay)
+	}
+	return delays
+}
+
+// colorsEqual compares colors by their RGBA values.
+func colorsEqual(a, b color.Color) bool {
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
+
+// buildTestGif writes the images to a temporary folder, builds a gif of them with the options and decodes it.
+func buildTestGif(t *testing.T, opts Options, images ...image.Image) *gif.GIF {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestImages(t, dir, images...)
+	files, err := listFiles(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out.gif")
+	if err := BuildGif(context.Background(), files, out, opts); err != nil {
+		t.Fatal(err)
+	}
+	return decodeTestGif(t, out)
+}
+
+// decodeTestGif decodes the gif file and fails the test on errors.
+func decodeTestGif(t *testing.T, path string) *gif.GIF {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	g, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// fakeCommand puts a shell script named as the command in front of PATH, so it stands in for the command.
+func fakeCommand(t *testing.T, name, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("stand-in commands are shell scripts")
+	}
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, name), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// parseTestFlags parses the args and fails the test on errors.
+func parseTestFlags(t *testing.T, args ...string) config {
+	t.Helper()
+	cfg, err := parseFlags(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// testPattern returns a w x h image of smooth colors, with red shifted by d, so it's d away from the one of 0.
+func testPattern(w, h, d int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(min(x*8+d, 255)), uint8(y * 8), uint8((x + y) * 4), 255})
+		}
+	}
+	return img
+}
+
+// memFile is a file of a create factory kept in memory.
+type memFile struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (f *memFile) Close() error {
+	f.closed = true
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sync"
+)
+
+// srgbToLinear converts a channel value of sRGB from 0 to 1 to linear light.
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// linearToSrgb converts a channel value of linear light from 0 to 1 to sRGB.
+func linearToSrgb(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// gammaTables returns lookup tables of 16-bit channel values from sRGB to linear light and back,
+// they are built once on the first use.
+var gammaTables = sync.OnceValues(func() (*[0x10000]uint16, *[0x10000]uint16) {
+	toLinear, toSrgb := &[0x10000]uint16{}, &[0x10000]uint16{}
+	for i := range toLinear {
+		v := float64(i) / 0xffff
+		toLinear[i] = uint16(srgbToLinear(v)*0xffff + 0.5)
+		toSrgb[i] = uint16(linearToSrgb(v)*0xffff + 0.5)
+	}
+	return toLinear, toSrgb
+})
+
+// toLinear converts the image to linear light, with colors premultiplied by alpha, so it can be resized or mixed.
+func toLinear(img image.Image) *image.RGBA64 {
+	toLinear, _ := gammaTables()
+	b := img.Bounds()
+	dst := image.NewRGBA64(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+			a := uint32(c.A)
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(uint32(toLinear[c.R]) * a / 0xffff),
+				G: uint16(uint32(toLinear[c.G]) * a / 0xffff),
+				B: uint16(uint32(toLinear[c.B]) * a / 0xffff),
+				A: c.A,
+			})
+		}
+	}
+	return dst
+}
+
+// fromLinear converts the image of linear light with premultiplied colors back to sRGB.
+func fromLinear(img *image.RGBA64) *image.NRGBA {
+	_, toSrgb := gammaTables()
+	b := img.Bounds()
+	dst := image.NewNRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := img.RGBA64At(x, y)
+			if c.A == 0 {
+				continue
+			}
+			// resampling can overshoot alpha, so channels are clamped after unpremultiplying
+			unmul := func(v uint16) uint8 {
+				l := min(uint32(v)*0xffff/uint32(c.A), 0xffff)
+				return uint8((uint32(toSrgb[l]) + 0x80) / 0x101)
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{unmul(c.R), unmul(c.G), unmul(c.B), uint8((uint32(c.A) + 0x80) / 0x101)})
+		}
+	}
+	return dst
+}
+
+// blendLinear returns n frames fading from a to b in linear light, not including a and b themselves.
+func blendLinear(a, b image.Image, n int) []image.Image {
+	la, lb := toLinear(a), toLinear(b)
+	ba, bb := la.Bounds(), lb.Bounds()
+	frames := make([]image.Image, n)
+	for i := range frames {
+		t := float64(i+1) / float64(n+1)
+		dst := image.NewRGBA64(image.Rect(0, 0, ba.Dx(), ba.Dy()))
+		for y := 0; y < ba.Dy(); y++ {
+			for x := 0; x < ba.Dx(); x++ {
+				c1 := la.RGBA64At(ba.Min.X+x, ba.Min.Y+y)
+				c2 := lb.RGBA64At(bb.Min.X+x, bb.Min.Y+y)
+				dst.SetRGBA64(x, y, color.RGBA64{
+					R: lerp16(c1.R, c2.R, t),
+					G: lerp16(c1.G, c2.G, t),
+					B: lerp16(c1.B, c2.B, t),
+					A: lerp16(c1.A, c2.A, t),
+				})
+			}
+		}
+		frames[i] = fromLinear(dst)
+	}
+	return frames
+}
+
+// lerp16 interpolates between 16-bit color values and returns the result rounded to the nearest.
+func lerp16(v1, v2 uint16, t float64) uint16 {
+	return uint16(float64(v1)*(1-t) + float64(v2)*t + 0.5)
+}
+
+// linearSample converts a sampled color with channels from 0 to 255 to linear light of the same range,
+// so quantizers average colors the way light mixes. Alpha is kept as it is.
+func linearSample(s [4]float64) [4]float64 {
+	for ch := 0; ch < 3; ch++ {
+		s[ch] = srgbToLinear(s[ch]/255) * 255
+	}
+	return s
+}
+
+// sampleColor converts a color a quantizer found back to a palette color, from linear light if it's linear.
+func sampleColor(s [4]float64, linear bool) color.NRGBA {
+	if linear {
+		for ch := 0; ch < 3; ch++ {
+			s[ch] = linearToSrgb(math.Min(math.Max(s[ch]/255, 0), 1)) * 255
+		}
+	}
+	return color.NRGBA{uint8(s[0] + 0.5), uint8(s[1] + 0.5), uint8(s[2] + 0.5), uint8(s[3] + 0.5)}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// checkerboard returns an image of black and white pixels in turn.
+func checkerboard(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{0, 0, 0, 255}
+			if (x+y)%2 == 0 {
+				c = color.RGBA{255, 255, 255, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// meanBrightness is the mean of the color channels of the image from 0 to 255.
+func meanBrightness(img image.Image) float64 {
+	sum, b := 0.0, img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			sum += float64(r+g+bl) / 3 / 0x101
+		}
+	}
+	return sum / float64(b.Dx()*b.Dy())
+}
+
+func TestGammaConversion(t *testing.T) {
+	for _, tt := range []struct{ srgb, linear float64 }{{0, 0}, {0.5, 0.2140}, {0.735, 0.5}, {1, 1}, {0.02, 0.02 / 12.92}} {
+		if got := srgbToLinear(tt.srgb); math.Abs(got-tt.linear) > 0.001 {
+			t.Errorf("%g sRGB: got %g linear, want %g", tt.srgb, got, tt.linear)
+		}
+		if got := linearToSrgb(tt.linear); math.Abs(got-tt.srgb) > 0.001 {
+			t.Errorf("%g linear: got %g sRGB, want %g", tt.linear, got, tt.srgb)
+		}
+	}
+
+	// images and quantizer samples come back as they were
+	src := testPattern(32, 32, 0)
+	src.Set(0, 0, color.NRGBA{200, 100, 50, 128})
+	back := fromLinear(toLinear(src))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			want := color.NRGBAModel.Convert(src.At(x, y)).(color.NRGBA)
+			got := back.NRGBAAt(x, y)
+			for _, d := range []int{int(got.R) - int(want.R), int(got.G) - int(want.G), int(got.B) - int(want.B), int(got.A) - int(want.A)} {
+				if d > 1 || d < -1 {
+					t.Fatalf("%d,%d: got %v, want %v", x, y, got, want)
+				}
+			}
+		}
+	}
+	s := [4]float64{200, 100, 50, 255}
+	if got := sampleColor(linearSample(s), true); got != (color.NRGBA{200, 100, 50, 255}) {
+		t.Errorf("got %v, want the sample back", got)
+	}
+}
+
+func TestLinearResize(t *testing.T) {
+	// a black and white checkerboard looks mid gray from afar, which is 188 in sRGB, not 128
+	board := checkerboard(64, 64)
+	naive := meanBrightness(resizeImage(board, 8, 8, false))
+	linear := meanBrightness(resizeImage(board, 8, 8, true))
+	if naive < 120 || naive > 136 {
+		t.Errorf("got brightness %.1f resizing in sRGB, want about 128", naive)
+	}
+	if linear < 180 || linear > 196 {
+		t.Errorf("got brightness %.1f resizing in linear light, want about 188", linear)
+	}
+
+	frames := readTestImages(t, parseTestFlags(t, "-scale", "0.125", "-linear").opts, board)
+	if got := meanBrightness(frames[0].img); math.Abs(got-linear) > 1 {
+		t.Errorf("got brightness %.1f with -linear, want %.1f", got, linear)
+	}
+
+	// blends of black and white meet at the same gray
+	black, white := testFrame(8, 8, color.Black), testFrame(8, 8, color.White)
+	if got := meanBrightness(blendLinear(black, white, 1)[0]); got < 186 || got > 190 {
+		t.Errorf("got brightness %.1f blending in linear light, want about 188", got)
+	}
+	if got := meanBrightness(blendFrames(black, white, 1)[0]); got < 126 || got > 130 {
+		t.Errorf("got brightness %.1f blending in sRGB, want about 128", got)
+	}
+}
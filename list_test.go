@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// bigFolder returns a file system with a folder of n images and n other files.
+func bigFolder(n int) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for i := 0; i < n; i++ {
+		fsys[fmt.Sprintf("frames/%06d.png", i)] = &fstest.MapFile{}
+		fsys[fmt.Sprintf("frames/%06d.txt", i)] = &fstest.MapFile{}
+	}
+	return fsys
+}
+
+// batchFS records the sizes of batches folder entries are read in.
+type batchFS struct {
+	fs.FS
+	batches *[]int
+}
+
+func (f batchFS) Open(name string) (fs.File, error) {
+	file, err := f.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if dir, ok := file.(fs.ReadDirFile); ok {
+		return batchDir{dir, f.batches}, nil
+	}
+	return file, nil
+}
+
+type batchDir struct {
+	fs.ReadDirFile
+	batches *[]int
+}
+
+func (d batchDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	*d.batches = append(*d.batches, n)
+	return d.ReadDirFile.ReadDir(n)
+}
+
+func TestListFilesBatches(t *testing.T) {
+	n := readdirBatch*2 + 100
+	batches := []int{}
+	files, err := listFiles("frames", Options{fsys: batchFS{bigFolder(n), &batches}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*files) != n {
+		t.Fatalf("got %d files, want %d", len(*files), n)
+	}
+	if !slices.IsSorted(*files) || (*files)[0] != "frames/000000.png" {
+		t.Errorf("files aren't sorted by name, the first is %s", (*files)[0])
+	}
+	// 4296 entries are read in 5 batches, then an empty one ends the folder
+	if len(batches) != 6 {
+		t.Errorf("got %d batches, want 6", len(batches))
+	}
+	for _, b := range batches {
+		if b <= 0 || b > readdirBatch {
+			t.Fatalf("got a batch of %d entries, want at most %d", b, readdirBatch)
+		}
+	}
+}
+
+func BenchmarkListFiles(b *testing.B) {
+	fsys := bigFolder(50000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := listFiles("frames", Options{fsys: fsys}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// deniedFS denies opening the names, like folders not readable by the current user.
+type deniedFS struct {
+	fs.FS
+	denied string
+}
+
+func (f deniedFS) Open(name string) (fs.File, error) {
+	if name == f.denied {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return f.FS.Open(name)
+}
+
+// deniedDirFS opens folders but denies reading their entries.
+type deniedDirFS struct{ fs.FS }
+
+func (f deniedDirFS) Open(name string) (fs.File, error) {
+	file, err := f.FS.Open(name)
+	if dir, ok := file.(fs.ReadDirFile); ok && err == nil {
+		return deniedDir{dir}, nil
+	}
+	return file, err
+}
+
+type deniedDir struct{ fs.ReadDirFile }
+
+func (d deniedDir) ReadDir(int) ([]fs.DirEntry, error) {
+	return nil, &fs.PathError{Op: "readdirent", Path: "frames", Err: fs.ErrPermission}
+}
+
+func TestListFilesPermission(t *testing.T) {
+	fsys := bigFolder(2)
+	for name, opts := range map[string]Options{
+		"open":    {fsys: deniedFS{fsys, "frames"}},
+		"readdir": {fsys: deniedDirFS{fsys}},
+	} {
+		_, err := listFiles("frames", opts)
+		if !errors.Is(err, fs.ErrPermission) {
+			t.Fatalf("%s: got %v, want a permission error", name, err)
+		}
+		want := "failed to read folder (frames): permission denied, check that the folder and its files are readable by the current user"
+		if err.Error() != want {
+			t.Errorf("%s: got %q, want %q", name, err, want)
+		}
+	}
+	// other errors are kept as they are
+	if _, err := listFiles("missing", Options{fsys: fsys}); !errors.Is(err, fs.ErrNotExist) || strings.Contains(err.Error(), "readable") {
+		t.Errorf("got %v for a missing folder", err)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// recordHandler keeps records of all levels, decoding goroutines log at once.
+type recordHandler struct {
+	mu      *sync.Mutex
+	records *[]slog.Record
+}
+
+func (h recordHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h recordHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	*h.records = append(*h.records, r)
+	return nil
+}
+
+func (h recordHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h recordHandler) WithGroup(string) slog.Handler      { return h }
+
+// recordAttrs returns attributes of the record by their keys.
+func recordAttrs(r slog.Record) map[string]slog.Value {
+	attrs := map[string]slog.Value{}
+	r.Attrs(func(a slog.Attr) bool {
+		attrs[a.Key] = a.Value
+		return true
+	})
+	return attrs
+}
+
+func TestBuildLogs(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPngs(t, dir, 16, 16, testRed, testRed, testBlue)
+	files, err := listFiles(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := []slog.Record{}
+	opts := Options{log: slog.New(recordHandler{&sync.Mutex{}, &records})}
+	out := filepath.Join(dir, "out.gif")
+	if err := BuildGif(context.Background(), files, out, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[string]int{}
+	for _, r := range records {
+		counts[r.Message]++
+		attrs := recordAttrs(r)
+		switch r.Message {
+		case "frame merged":
+			if f := attrs["file"].String(); f != filepath.Join(dir, "0002.png") {
+				t.Errorf("got the merged file %s, want 0002.png", f)
+			}
+		case "output written":
+			if r.Level != slog.LevelDebug || attrs["path"].String() != out || attrs["format"].String() != formatGif || attrs["frames"].Int64() != 2 {
+				t.Errorf("got %v output written with %v", r.Level, attrs)
+			}
+		}
+	}
+	if counts["frame decoded"] != 3 || counts["frame merged"] != 1 || counts["output written"] != 1 {
+		t.Errorf("got records %v, want 3 decoded, 1 merged and 1 written", counts)
+	}
+
+	// builds without a logger are fine
+	if err := BuildGif(context.Background(), files, out, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if cfg := parseTestFlags(t, "-log-level", "debug"); cfg.logLevel != slog.LevelDebug {
+		t.Errorf("got the log level %v, want debug", cfg.logLevel)
+	}
+	parseUsageError(t, "-log-level", "loud")
+}
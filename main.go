@@ -1,20 +1,26 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"compress/zlib"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"image"
+	"image/color"
 	"image/gif"
-	_ "image/jpeg"
-	_ "image/png"
-	"log"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math"
 	"os"
 	"path/filepath"
-	"sort"
+	"runtime"
 	"strconv"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/bubbles/spinner"
@@ -27,11 +33,87 @@ import (
 )
 
 func main() {
-	p := tea.NewProgram(initialModel())
+	// measure throughput of the pipeline stages without writing anything.
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		cfg, err := parseFlags(os.Args[2:])
+		if err != nil {
+			fatal(err)
+		}
+		if err := runBench(cfg, os.Stdout); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	// print the images that would be used without building anything.
+	if len(os.Args) > 1 && os.Args[1] == "list" {
+		cfg, err := parseFlags(os.Args[2:])
+		if err != nil {
+			fatal(err)
+		}
+		if err := runList(cfg, os.Stdout); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	// print the frames images are merged into without building anything.
+	if len(os.Args) > 1 && os.Args[1] == "summary" {
+		cfg, err := parseFlags(os.Args[2:])
+		if err != nil {
+			fatal(err)
+		}
+		if err := runSummary(cfg, os.Stdout); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	cfg, err := parseFlags(os.Args[1:])
+	if err != nil {
+		fatal(err)
+	}
+
+	// run without the UI if the input folder is passed as a flag.
+	if cfg.path != "" {
+		if err := runHeadless(cfg); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	m := initialModel(cfg.opts, cfg.theme)
+	m.once = cfg.once
+	m.ascii = cfg.ascii
+	p := tea.NewProgram(m)
+
+	res, err := p.Run()
+	if err != nil {
+		fatal(err)
+	}
+	// print outputs of the build the app quit after
+	if m, ok := res.(model); ok && m.once && m.finished {
+		for _, w := range m.warnings {
+			fmt.Fprintf(os.Stderr, "%s %s\n", indicator(emojiWarning, m.ascii), w)
+		}
+		for _, o := range m.outPaths {
+			outPath, _ := filepath.Abs(o)
+			fmt.Println(outPath)
+		}
+		if cfg.clipboard && len(m.outPaths) > 0 {
+			copyOutput(m.outPaths[0], m.ascii)
+		}
+	}
+}
 
-	if _, err := p.Run(); err != nil {
-		log.Fatal(err)
+// fatal prints the error in one line prefixed with the program name and exits with the code of its kind,
+// the help exits with 0.
+func fatal(err error) {
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
 	}
+	fmt.Fprintf(os.Stderr, "png2gif: %v\n", err)
+	os.Exit(exitCode(err))
 }
 
 // errMsg is a type for error message
@@ -43,18 +125,110 @@ type (
 // @property duration - The time it took to run the command.
 // @property {string} emoji - The emoji that will be displayed in the message.
 // @property {error} err - This is the error that occurred during the execution of the function.
+// @property {[]string} outputs - The resolved paths to the output files.
+// @property {[]string} warnings - The non-fatal problems with the result, e.g. a frame rate that can't be represented.
+// @property {bool} upToDate - Whether the build was skipped as inputs and options didn't change since the last one.
 type resultMsg struct {
 	duration time.Duration
 	emoji    string
 	err      error
+	outputs  []string
+	warnings []string
+	upToDate bool
+}
+
+// emojiWarning marks warnings about the result.
+const emojiWarning = "⚠️"
+
+// asciiIndicators are the tokens shown instead of emojis with the ascii flag, for terminals without emoji fonts.
+var asciiIndicators = map[string]string{
+	"💾":          "[out]",
+	"🎞":          "[video]",
+	"📄":          "[pdf]",
+	"📂":          "[dir]",
+	"✅":          "[ok]",
+	"🛑":          "[canceled]",
+	"⏱":          "[timeout]",
+	"🔨":          "[build]",
+	"🎉":          "[done]",
+	"📋":          "[clipboard]",
+	emojiWarning: "[warn]",
+}
+
+// indicator returns the emoji, or its ASCII token if ascii is set.
+func indicator(emoji string, ascii bool) string {
+	if t, ok := asciiIndicators[emoji]; ok && ascii {
+		return t
+	}
+	return emoji
+}
+
+// phaseMsg is a message with the current phase of the processing pipeline.
+// @property {string} phase - The name of the phase: decoding, encoding or writing.
+// @property {int} done - The number of frames processed in the phase.
+// @property {int} total - The total number of frames in the phase, 0 if unknown.
+type phaseMsg struct {
+	phase string
+	done  int
+	total int
+}
+
+// String returns a label of the phase, e.g. "decoding 120/500".
+func (p phaseMsg) String() string {
+	if p.total == 0 {
+		return p.phase
+	}
+	return fmt.Sprintf("%s %d/%d", p.phase, p.done, p.total)
+}
+
+// estimateRemaining estimates the time left to process the rest of the frames at the rate of the frames done in elapsed,
+// false if no frames are done yet or the total is unknown.
+func estimateRemaining(elapsed time.Duration, done, total int) (time.Duration, bool) {
+	if done <= 0 || total <= 0 || done > total {
+		return 0, false
+	}
+	return elapsed * time.Duration(total-done) / time.Duration(done), true
+}
+
+// phases of the processing pipeline.
+const (
+	phaseDecoding = "decoding"
+	phaseEncoding = "encoding"
+	phaseWriting  = "writing"
+	phaseFitting  = "fitting"
+)
+
+// scanMsg is a message with a quick summary of images in the input folder.
+// @property {string} path - The path that was scanned.
+// @property {int} count - The number of images found.
+// @property {image.Point} size - The size of the first image.
+// @property {error} err - The error that happened while scanning.
+type scanMsg struct {
+	path  string
+	count int
+	size  image.Point
+	err   error
+}
+
+// String returns a summary of the scan, e.g. "247 images found, 1920×1080".
+func (s scanMsg) String() string {
+	if s.err != nil {
+		return "error: " + s.err.Error()
+	}
+	if s.count == 0 {
+		return "no images found"
+	}
+	return fmt.Sprintf("%d images found, %d×%d", s.count, s.size.X, s.size.Y)
 }
 
 // ImgWithDelay is a struct that contains an image.Image and an delay in numbers of frames.
 // @property img - The image.Image object that represents the frame.
 // @property {int} delay - The delay in numbers of frames before the next image is shown.
+// @property {[]string} sources - The source files merged into the frame, empty for generated frames.
 type imgWithDelay struct {
-	img   image.Image
-	delay int
+	img     image.Image
+	delay   int
+	sources []string
 }
 
 // PalettedWithDelay is a struct that contains an image.Paletted and an delay in numbers of frames.
@@ -65,6 +239,288 @@ type palettedWithDelay struct {
 	delay    int
 }
 
+// Options tweaks how the gif is built.
+// The zero value builds with default settings.
+// @property {int} fps - The frame rate from 1 to 100, 0 for the default 30.
+// @property {string} compare - The comparison mode used to merge equal frames in a row, "rgb" or "alpha".
+// @property {string} keep - Which frame of merged equal frames in a row is kept, "first" or "last".
+// @property {bool} noDedup - Whether to keep all frames, even if they are equal.
+// @property {bool} keepEndpoints - Whether the first and the last images are kept as separate frames, even if they are equal to their neighbors.
+// @property {bool} deflicker - Whether to drop single frames that differ from both neighbors while the neighbors are equal.
+// @property {bool} exactPalette - Whether to build a palette from exact colors of a frame instead of a generic one.
+// @property {int} firstHold - The delay of the first frame in 100ths of a second, 0 to use the frame rate.
+// @property {int} lastHold - The delay of the last frame in 100ths of a second, 0 to use the frame rate.
+// @property {float64} speed - The factor the animation plays faster by, e.g. 2 halves delays and 0.5 doubles them, 0 for 1.
+// @property {int} delayMs - The delay of a source image in milliseconds used instead of the frame rate, 0 to use the frame rate.
+// @property {bool} uniformDelay - Whether every frame is shown for the same time, instead of the time of all images it stands for.
+// @property {int} maxDelay - The max delay of a frame in 100ths of a second, longer frames are repeated, 0 for no limit.
+// @property {bool} fromVideo - Whether the input path is a video to extract frames from, regardless of its extension.
+// @property {string} dedup - The preset of thresholds to check if images are equal, "strict", "normal" or "loose".
+// @property {int} dedupWindow - The number of previous frames an image is compared with, so it merges back after a brief change, 0 or 1 for the previous one only.
+// @property {SimilarityOptions} thresholds - The explicit thresholds that override the preset ones if not 0.
+// @property {int} sample - Keep only every Nth source image with its delay multiplied by N, 0 or 1 keeps all.
+// @property {int} minFrames - The min number of frames left after merging equal images, 0 for no limit.
+// @property {string} onGap - What to do with missing numbers in file names, "ignore", "error", "warn" or "hold", empty to ignore.
+// @property {string} sort - The order of images in a folder, "name", "created" (modification time), "exif" or "natural".
+// @property {int} sortNumber - The number in file names natural sort uses, 1 for the first, n for the nth, 0 or -1 for the last.
+// @property {string} template - The printf-style file name of numbered frames in the folder, e.g. frame%04d.png, empty to list all images.
+// @property {int} templateStart - The number of the first frame of the template.
+// @property {int} templateEnd - The number of the last frame of the template, 0 to take frames until the first missing one.
+// @property {bool} sortDesc - Whether images in a folder are in the reverse order of the sort mode.
+// @property {time.Time} since - Images in a folder modified before the time are skipped, zero for no limit.
+// @property {time.Time} until - Images in a folder modified at or after the time are skipped, zero for no limit.
+// @property {map[string]bool} exts - The extensions of files listed as images in a folder, nil for the ones of registered formats.
+// @property {bool} stream - Whether to write gif frames one by one instead of encoding the whole gif at once.
+// @property {bool} interlace - Whether gif frames are interlaced to show progressively while loading.
+// @property {bool} commentFps - Whether the frame rate is recorded in a comment of the gif.
+// @property {bool} stripMetadata - Whether comments, text chunks and other metadata are removed from the output.
+// @property {bool} preserveTiming - Whether frames of an animated gif input keep their delays instead of the frame rate.
+// @property {map[string]int} sourceDelays - The delays of images in 100ths of a second by their paths, nil to play them at the frame rate.
+// @property {bool} trimStatic - Whether leading and trailing runs of similar frames are trimmed to one short frame.
+// @property {bool} dropBlank - Whether images of a single color are dropped before merging equal images.
+// @property {bool} allowStatic - Whether a single frame left after merging equal images is expected, so there is no warning.
+// @property {bool} optimizeStatic - Whether gif frames after the first one carry only the area that changed.
+// @property {bool} appendOutput - Whether frames are appended to the existing gif output instead of overwriting it.
+// @property {int} loopCount - The loop count of the gif as in gif.GIF, 0 loops forever and -1 plays once.
+// @property {bool} autoFps - Whether to detect the frame rate from modification times of images if fps is not set.
+// @property {float64} ditherStrength - The part of the quantization error diffused to neighbor pixels from 0 to 1, 0 for the default full dithering, -1 for none.
+// @property {int} compression - The zlib compression level of animated png from 1 to 9, 0 for the default, -1 for none.
+// @property {bool} linear - Whether frames are resized and blended, and palettes are built, in linear light instead of sRGB.
+// @property {string} quantizer - The algorithm to build palettes of frames, "default" (plan9 palette), "kmeans", "median-cut" or "octree".
+// @property {int64} seed - The seed of the random generator of the quantizer, the same seed gives the same palettes.
+// @property {color.Palette} palette - The fixed palette to map all frames onto, nil to build palettes of frames.
+// @property {string} paletteMode - How palettes of gif frames are built if there is no fixed palette:
+// per-frame (the default), global from all frames and written once, or shared-sampled from sampled frames.
+// @property {bool} overlayFrameNumber - Whether to draw the index of the source image in the corner of the frame.
+// @property {bool} overlayFilename - Whether to draw the file name of the source image in the corner of the frame.
+// @property {map[string]annotation} annotations - The captions drawn on frames by the index of the source image or its file name.
+// @property {int} numColors - The max number of colors in palettes of frames, 0 for 256.
+// @property {int} targetSize - The max size of the gif in bytes, colors, size and frames are reduced to fit, 0 for no limit.
+// @property {bool} verify - Whether written gifs are decoded again to check they are valid and have all frames.
+// @property {string} format - The format of all outputs, e.g. png-sequence, empty to choose it by the extensions of outputs.
+// @property {bool} outInInput - Whether outputs without a folder are written into the input folder instead of the working one.
+// @property {bool} cache - Whether to skip the build if inputs and options didn't change since the last one.
+// @property {image.Point} size - The size frames are scaled to fit into and padded to, zero to keep the size of images.
+// @property {float64} scale - The factor frames are scaled by, e.g. 0.5 halves them, 0 keeps the size of images.
+// @property {int} maxDimension - The max width and height of frames, larger ones are scaled down to fit, 0 for no limit.
+// @property {color.Color} padColor - The color of padding around scaled frames, nil for transparent.
+// @property {*int} bgIndex - The background color index in the global palette of the gif, nil for 0 or the one of bgColor.
+// @property {color.Color} bgColor - The background color of the gif, the closest color in the global palette is used, nil for none.
+// @property {image.Point} canvas - The logical screen size of the gif frames are placed on keeping their size, zero for the size of frames.
+// @property {*image.Point} canvasAt - The position of frames on the canvas, nil to center them.
+// @property {color.Color} canvasColor - The color of the canvas around frames, nil for transparent.
+// @property {time.Duration} timeout - The max duration of the build, 0 for no limit.
+// @property {time.Duration} httpTimeout - The max duration of fetching an image by its URL, 0 for the default 30s.
+// @property {int} blend - The number of crossfaded frames inserted between consecutive frames, 0 for none.
+// @property {string} reportPath - The path of the csv report of output frames, empty for none.
+// @property {fs.FS} fsys - The file system images are read from, nil for the os one.
+// @property {func(string) (io.WriteCloser, error)} create - The factory of output files, nil to create them in the os file system.
+// @property {*slog.Logger} log - The logger of build events, e.g. decoded or merged frames, can be nil.
+// @property {int} jobs - The number of CPUs the build uses, 0 for the available ones up to maxJobs.
+// @property {int} maxJobs - The max number of CPUs used by default, 0 for 8.
+// @property {int} threadsIO - The max number of images decoded at once, 0 for the default.
+// @property {int} threadsEncode - The max number of frames encoded at once, 0 for the default.
+// @property {func(phaseMsg)} progress - The callback to report the progress of the pipeline phases, can be nil.
+// @property {func(string)} warn - The callback to collect non-fatal warnings about the result, can be nil.
+type Options struct {
+	fps                int
+	compare            string
+	keep               string
+	noDedup            bool
+	keepEndpoints      bool
+	deflicker          bool
+	exactPalette       bool
+	firstHold          int
+	lastHold           int
+	uniformDelay       bool
+	delayMs            int
+	speed              float64
+	maxDelay           int
+	fromVideo          bool
+	dedup              string
+	dedupWindow        int
+	thresholds         SimilarityOptions
+	sample             int
+	minFrames          int
+	onGap              string
+	sort               string
+	sortNumber         int
+	sortDesc           bool
+	exts               map[string]bool
+	since              time.Time
+	until              time.Time
+	template           string
+	templateStart      int
+	templateEnd        int
+	stream             bool
+	interlace          bool
+	commentFps         bool
+	stripMetadata      bool
+	optimizeStatic     bool
+	allowStatic        bool
+	trimStatic         bool
+	dropBlank          bool
+	preserveTiming     bool
+	sourceDelays       map[string]int
+	appendOutput       bool
+	loopCount          int
+	autoFps            bool
+	compression        int
+	ditherStrength     float64
+	linear             bool
+	quantizer          string
+	seed               int64
+	palette            color.Palette
+	paletteMode        string
+	overlayFrameNumber bool
+	overlayFilename    bool
+	annotations        map[string]annotation
+	numColors          int
+	targetSize         int
+	cache              bool
+	verify             bool
+	format             string
+	outInInput         bool
+	size               image.Point
+	scale              float64
+	maxDimension       int
+	padColor           color.Color
+	bgIndex            *int
+	bgColor            color.Color
+	canvas             image.Point
+	canvasAt           *image.Point
+	canvasColor        color.Color
+	timeout            time.Duration
+	httpTimeout        time.Duration
+	blend              int
+	reportPath         string
+	log                *slog.Logger
+	fsys               fs.FS
+	create             func(name string) (io.WriteCloser, error)
+	jobs               int
+	maxJobs            int
+	threadsIO          int
+	threadsEncode      int
+	progress           func(phaseMsg)
+	warn               func(msg string)
+}
+
+// similarity returns thresholds of the dedup preset overridden by explicit ones and the compare mode.
+func (o Options) similarity() SimilarityOptions {
+	th, ok := dedupPresets[o.dedup]
+	if !ok {
+		th = dedupPresets[dedupNormal]
+	}
+	if o.thresholds.Prop > 0 {
+		th.Prop = o.thresholds.Prop
+	}
+	if o.thresholds.Y > 0 {
+		th.Y = o.thresholds.Y
+	}
+	if o.thresholds.CbCr > 0 {
+		th.CbCr = o.thresholds.CbCr
+	}
+	th.Alpha = o.compare == compareAlpha
+	return th
+}
+
+// discardLogger is used when there is no logger in options.
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// logger returns the logger from options, or the one that discards records.
+func (o Options) logger() *slog.Logger {
+	if o.log != nil {
+		return o.log
+	}
+	return discardLogger
+}
+
+// warnf reports a non-fatal warning if the callback is set.
+func (o Options) warnf(format string, args ...any) {
+	if o.warn != nil {
+		o.warn(fmt.Sprintf(format, args...))
+	}
+}
+
+// colors returns the max number of colors in palettes of frames.
+func (o Options) colors() int {
+	if o.numColors <= 0 || o.numColors > 256 {
+		return 256
+	}
+	return o.numColors
+}
+
+// zlibLevel returns the zlib compression level for animated png.
+func (o Options) zlibLevel() int {
+	switch {
+	case o.compression < 0:
+		return zlib.NoCompression
+	case o.compression == 0:
+		return zlib.DefaultCompression
+	}
+	return o.compression
+}
+
+// defaultMaxJobs is the max number of CPUs used by default, so builds on shared machines leave room for others.
+const defaultMaxJobs = 8
+
+// defaultJobs returns the number of CPUs used by default: all of them up to the max, 0 max for the default one.
+func defaultJobs(cpus, maxJobs int) int {
+	if maxJobs <= 0 {
+		maxJobs = defaultMaxJobs
+	}
+	return max(1, min(cpus, maxJobs))
+}
+
+// cpus returns the number of CPUs the build uses, the jobs if they are set,
+// otherwise the ones available to the process by GOMAXPROCS up to the max jobs.
+func (o Options) cpus() int {
+	if o.jobs > 0 {
+		return o.jobs
+	}
+	return defaultJobs(runtime.GOMAXPROCS(0), o.maxJobs)
+}
+
+// ioThreads returns the max number of images decoded at once,
+// decoding is mostly waiting for disk, so it defaults to twice the number of CPUs.
+func (o Options) ioThreads() int {
+	if o.threadsIO > 0 {
+		return o.threadsIO
+	}
+	return 2 * o.cpus()
+}
+
+// encodeThreads returns the max number of frames encoded at once,
+// encoding is CPU bound, so it defaults to the number of CPUs.
+func (o Options) encodeThreads() int {
+	if o.threadsEncode > 0 {
+		return o.threadsEncode
+	}
+	return o.cpus()
+}
+
+// report sends the phase progress to the progress callback if it is set.
+func (o Options) report(phase string, done, total int) {
+	if o.progress != nil {
+		o.progress(phaseMsg{phase, done, total})
+	}
+}
+
+// compare modes to check if images are equal.
+const (
+	compareRGB   = "rgb"
+	compareAlpha = "alpha"
+)
+
+// keep modes to choose the frame kept from equal frames in a row.
+const (
+	keepFirst = "first"
+	keepLast  = "last"
+)
+
+// defaultOutput is the name of the output file if it's not passed.
+const defaultOutput = "out.gif"
+
 // input fields in the form
 const (
 	path = iota
@@ -73,11 +529,44 @@ const (
 )
 
 // thy and thCbCr are the threshold for the YCbCr color model to check if images are equal.
+// thProp is the threshold for the proportions of images to be equal.
 const (
 	thy    = float64(100)
 	thCbCr = float64(200)
+	thProp = float64(0.001)
 )
 
+// SimilarityOptions is a bundle of thresholds to check if frames are equal, the higher the looser.
+// @property {float64} Prop - The max difference of proportions of images.
+// @property {float64} Y - The max Euclidean distance of icons in the Y channel.
+// @property {float64} CbCr - The max Euclidean distance of icons in the Cb and Cr channels.
+// @property {bool} Alpha - Also compare transparency of frames pixel by pixel.
+type SimilarityOptions struct {
+	Prop  float64
+	Y     float64
+	CbCr  float64
+	Alpha bool
+}
+
+// dedup presets of thresholds.
+const (
+	dedupStrict = "strict"
+	dedupNormal = "normal"
+	dedupLoose  = "loose"
+)
+
+// dedupPresets maps preset names to thresholds:
+// strict merges only near identical frames, normal tolerates compression noise,
+// loose also merges frames with small changes like a blinking cursor.
+var dedupPresets = map[string]SimilarityOptions{
+	dedupStrict: {Prop: thProp / 2, Y: thy / 2, CbCr: thCbCr / 2},
+	dedupNormal: {Prop: thProp, Y: thy, CbCr: thCbCr},
+	dedupLoose:  {Prop: thProp * 2, Y: thy * 2, CbCr: thCbCr * 2},
+}
+
+// thAlpha is the max difference of a pixel alpha value for images to be equal in the alpha compare mode.
+const thAlpha = uint32(0x0fff)
+
 // hotPink and darkGray are the colors used in the UI.
 const (
 	hotPink  = lipgloss.Color("#FF06B7")
@@ -98,6 +587,20 @@ var (
 // @property {time.Duration} duration - The duration of the processing.
 // @property {bool} finished - Whether the current processing pipe has finished.
 // @property {error} err - This is the error that will be displayed if any errors happen.
+// @property {Options} opts - The options passed from the command line to build the gif with.
+// @property {phaseMsg} phase - The current phase of the processing.
+// @property {chan phaseMsg} phases - The channel to receive phases of the processing from.
+// @property {[]string} outPaths - The resolved paths to the output files of the finished processing.
+// @property {string} errEmoji - The emoji of the processing stage that failed.
+// @property {scanMsg} scan - The summary of images in the input folder, shown when the path input loses focus.
+// @property {[]string} warnings - The warnings of the last build.
+// @property {bool} once - Whether to quit after a successful build instead of showing the success screen.
+// @property {context.CancelFunc} cancel - Cancels the current processing.
+// @property {string} notice - The message shown above the form, e.g. when processing is canceled.
+// @property {bool} ascii - Whether ASCII tokens are shown instead of emojis.
+// @property {theme} theme - The spinner and the accent color of the UI.
+// @property {time.Time} etaStart - The time the first message of the current phase was received, the time left is estimated from it.
+// @property {int} etaFrom - The number of frames done in the first message of the current phase.
 type model struct {
 	inputs   []textinput.Model
 	focused  int
@@ -106,6 +609,20 @@ type model struct {
 	duration time.Duration
 	finished bool
 	err      error
+	opts     Options
+	phase    phaseMsg
+	phases   chan phaseMsg
+	outPaths []string
+	errEmoji string
+	scan     scanMsg
+	warnings []string
+	once     bool
+	cancel   context.CancelFunc
+	notice   string
+	ascii    bool
+	theme    theme
+	etaStart time.Time
+	etaFrom  int
 }
 
 // Validator functions to ensure valid input
@@ -117,8 +634,32 @@ func fpsValidator(s string) error {
 	return err
 }
 
+// parseFps converts the fps input value to a number, 0 means default.
+func parseFps(s string) int {
+	c := strings.ReplaceAll(s, " ", "")
+	fpsVal, _ := strconv.ParseInt(c, 10, 64)
+	return int(fpsVal)
+}
+
+// maxFpsInput is the max frame rate the fps input holds, it's limited to 2 digits.
+const maxFpsInput = 99
+
+// stepFps returns the fps input value one up or down, within 1 and the max of the input.
+// An empty or invalid value steps from the default frame rate.
+func stepFps(s string, up bool) string {
+	v := parseFps(s)
+	if v <= 0 || fpsValidator(s) != nil {
+		v = defaultFps
+	} else if up {
+		v++
+	} else {
+		v--
+	}
+	return strconv.Itoa(min(max(v, 1), maxFpsInput))
+}
+
 // initialize app model.
-func initialModel() model {
+func initialModel(opts Options, th theme) model {
 	var inputs []textinput.Model = make([]textinput.Model, 3)
 	inputs[path] = textinput.New()
 	inputs[path].Placeholder = "/path/to/folder/"
@@ -132,21 +673,23 @@ func initialModel() model {
 	inputs[output].Prompt = ""
 
 	inputs[fps] = textinput.New()
-	inputs[fps].Placeholder = "30"
+	inputs[fps].Placeholder = strconv.Itoa(defaultFps)
 	inputs[fps].CharLimit = 2
 	inputs[fps].Width = 5
 	inputs[fps].Prompt = ""
 	inputs[fps].Validate = fpsValidator
 
 	sp := spinner.New()
-	sp.Spinner = spinner.MiniDot
-	sp.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("206"))
+	sp.Spinner = th.spinner
+	sp.Style = lipgloss.NewStyle().Foreground(th.accent)
 
 	return model{
 		inputs:  inputs,
 		focused: 0,
 		spinner: sp,
 		err:     nil,
+		opts:    opts,
+		theme:   th,
 	}
 }
 
@@ -155,10 +698,11 @@ func (m model) Init() tea.Cmd {
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	var cmds []tea.Cmd = make([]tea.Cmd, len(m.inputs))
+	var cmds []tea.Cmd = make([]tea.Cmd, 0, len(m.inputs)+2)
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		prevFocused := m.focused
 		switch msg.Type {
 		case tea.KeyEnter:
 			// if the app is currently processing images, then we don't want to do anything.
@@ -170,7 +714,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if m.finished || m.err != nil {
 				w := m.inputs[path].Width
 				sp := m.spinner
-				m = initialModel()
+				once, ascii := m.once, m.ascii
+				m = initialModel(m.opts, m.theme)
+				m.once, m.ascii = once, ascii
 				m.inputs[path].Width = w
 				m.inputs[output].Width = w / 2
 				m.inputs[fps].Width = w / 2
@@ -184,14 +730,34 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				for i := range m.inputs {
 					m.inputs[i].Blur()
 				}
-				return m, gen(m.inputs[path].Value(), m.inputs[output].Value(), m.inputs[fps].Value())
+				m.phase = phaseMsg{}
+				m.phases = make(chan phaseMsg)
+				m.notice = ""
+				var ctx context.Context
+				ctx, m.cancel = context.WithCancel(context.Background())
+				opts := m.opts
+				opts.fps = parseFps(m.inputs[fps].Value())
+				return m, tea.Batch(
+					gen(ctx, m.inputs[path].Value(), m.inputs[output].Value(), opts, m.phases),
+					waitForPhase(m.phases),
+				)
 			}
 
 			// otherwise, we want to move to the next input.
 			m.nextInput()
 
+		// cancel processing and return to the form
+		case tea.KeyEsc:
+			if !m.loading {
+				return m, tea.Quit
+			}
+			m.cancel()
+			m.loading = false
+			m.phases = nil
+			m.notice = "processing canceled"
+
 		// quit app
-		case tea.KeyCtrlC, tea.KeyEsc:
+		case tea.KeyCtrlC:
 			return m, tea.Quit
 
 		// navigate between inputs
@@ -207,12 +773,33 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 			m.nextInput()
+
+		// step the frame rate with arrows
+		case tea.KeyUp, tea.KeyDown:
+			if m.focused == fps && !m.loading && !m.finished && m.err == nil {
+				m.inputs[fps].SetValue(stepFps(m.inputs[fps].Value(), msg.Type == tea.KeyUp))
+				return m, nil
+			}
 		}
 
 		for i := range m.inputs {
 			m.inputs[i].Blur()
 		}
-		m.inputs[m.focused].Focus()
+		if !m.loading {
+			m.inputs[m.focused].Focus()
+		}
+
+		// scan the folder when the path input loses focus
+		if prevFocused == path && m.focused != path {
+			cmds = append(cmds, scan(m.inputs[path].Value(), m.opts))
+		}
+
+	// Show the summary of the scanned folder if the path didn't change since
+	case scanMsg:
+		if msg.path == m.inputs[path].Value() {
+			m.scan = msg
+		}
+		return m, nil
 
 	// Check terminal size
 	case tea.WindowSizeMsg:
@@ -220,15 +807,36 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.inputs[output].Width = msg.Width / 2
 		m.inputs[fps].Width = msg.Width / 2
 
-	// Handle results
+	// Handle processing phases, ignore the ones of canceled processing
+	case phaseMsg:
+		if !m.loading {
+			return m, nil
+		}
+		if msg.phase != m.phase.phase {
+			m.etaStart, m.etaFrom = time.Now(), msg.done
+		}
+		m.phase = msg
+		return m, waitForPhase(m.phases)
+
+	// Handle results, ignore the ones of canceled processing
 	case resultMsg:
+		if !m.loading {
+			return m, nil
+		}
+		m.cancel()
 		m.loading = false
 		m.inputs[path].Focus()
 		if msg.err != nil {
 			m.err = msg.err
+			m.errEmoji = msg.emoji
 			return m, nil
 		}
 		m.finished = true
+		m.outPaths = msg.outputs
+		m.warnings = msg.warnings
+		if m.once {
+			return m, tea.Quit
+		}
 		return m, nil
 
 	// We handle errors just like any other message
@@ -239,7 +847,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	// Update inputs
 	for i := range m.inputs {
-		m.inputs[i], cmds[i] = m.inputs[i].Update(msg)
+		var cmd tea.Cmd
+		m.inputs[i], cmd = m.inputs[i].Update(msg)
+		cmds = append(cmds, cmd)
 	}
 	var cmdSpin tea.Cmd
 	m.spinner, cmdSpin = m.spinner.Update(msg)
@@ -252,11 +862,37 @@ func (m model) View() string {
 
 	// Render processing spinner
 	if m.loading {
-		return "\n\n" + pad + pad + m.spinner.View() + "  processing...\n"
+		label := "processing..."
+		if m.phase.phase != "" {
+			label = m.phase.String()
+			eta, ok := estimateRemaining(time.Since(m.etaStart), m.phase.done-m.etaFrom, m.phase.total-m.etaFrom)
+			if ok && eta >= time.Second {
+				label += fmt.Sprintf(" ~%s remaining", eta.Round(time.Second))
+			}
+		}
+		return "\n\n" + pad + pad + m.spinner.View() + "  " + label + "\n\n" +
+			pad + pad + continueStyle.Render("esc to cancel") + "\n"
 	}
 
 	// Render error message
 	if m.err != nil {
+		title := "error: " + m.err.Error()
+		filename := ""
+		// show the failing file on its own line
+		var fe *fileError
+		if errors.As(m.err, &fe) {
+			title = fmt.Sprintf("error: failed to %s: %v", fe.op, fe.err)
+			filename = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#ebcb8b")).
+				Copy().
+				Width(m.inputs[path].Width).
+				PaddingLeft(4).
+				PaddingTop(1).
+				Render(fe.path)
+		}
+		if m.errEmoji != "" {
+			title = indicator(m.errEmoji, m.ascii) + " " + title
+		}
 		return "" +
 			lipgloss.NewStyle().
 				Foreground(lipgloss.Color("#bf616a")).
@@ -264,7 +900,8 @@ func (m model) View() string {
 				Width(m.inputs[path].Width).
 				PaddingLeft(4).
 				PaddingTop(2).
-				Render("error: "+m.err.Error()) +
+				Render(title) +
+			filename +
 			continueStyle.
 				Copy().
 				PaddingTop(3).
@@ -275,11 +912,10 @@ func (m model) View() string {
 
 	// Render success message
 	if m.finished {
-		filename := "./out.gif"
-		if m.inputs[output].Value() != "" {
-			filename = m.inputs[output].Value()
+		outPaths := make([]string, len(m.outPaths))
+		for i, o := range m.outPaths {
+			outPaths[i], _ = filepath.Abs(o)
 		}
-		outPath, _ := filepath.Abs(filename)
 		return "" +
 			lipgloss.NewStyle().
 				Foreground(lipgloss.Color("#a3be8c")).
@@ -294,7 +930,8 @@ func (m model) View() string {
 				PaddingTop(2).
 				PaddingLeft(4).
 				Width(m.inputs[path].Width).
-				Render(outPath) +
+				Render(strings.Join(outPaths, "\n")) +
+			m.warningsView() +
 			continueStyle.
 				Copy().
 				PaddingTop(5).
@@ -304,7 +941,15 @@ func (m model) View() string {
 	}
 
 	// Render input fields
-	return fmt.Sprintf(
+	fpsLabel := "Frame rate (👉25-50👈):"
+	if m.ascii {
+		fpsLabel = "Frame rate (25-50):"
+	}
+	notice := ""
+	if m.notice != "" {
+		notice = "\n" + pad + lipgloss.NewStyle().Foreground(lipgloss.Color("#ebcb8b")).Render(m.notice) + "\n"
+	}
+	return notice + fmt.Sprintf(
 		` 
 Generate Gif from a bunch of png or jpg files:
 
@@ -319,16 +964,38 @@ Generate Gif from a bunch of png or jpg files:
 
   %s
 `,
-		inputStyle.Width(m.inputs[path].Width).Render("Path to folder with images:"),
-		m.inputs[path].View(),
-		inputStyle.Width(m.inputs[output].Width).Render("Output file:"),
+		inputStyle.Copy().Foreground(m.theme.accent).Width(m.inputs[path].Width).Render("Path to folder with images:"),
+		m.inputs[path].View()+m.scanView(),
+		inputStyle.Copy().Foreground(m.theme.accent).Width(m.inputs[output].Width).Render("Output file:"),
 		m.inputs[output].View(),
-		inputStyle.Width(m.inputs[fps].Width).Render("Frame rate (👉25-50👈):"),
+		inputStyle.Copy().Foreground(m.theme.accent).Width(m.inputs[fps].Width).Render(fpsLabel),
 		m.inputs[fps].View(),
 		continueStyle.Render("Continue ->"),
 	) + "\n"
 }
 
+// warningsView renders warnings of the build under the output paths.
+func (m model) warningsView() string {
+	if len(m.warnings) == 0 {
+		return ""
+	}
+	return lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#ebcb8b")).
+		Copy().
+		PaddingTop(2).
+		PaddingLeft(4).
+		Width(m.inputs[path].Width).
+		Render(indicator(emojiWarning, m.ascii) + " " + strings.Join(m.warnings, "\n"+indicator(emojiWarning, m.ascii)+" "))
+}
+
+// scanView renders the summary of the scanned folder under the path input.
+func (m model) scanView() string {
+	if m.scan.path == "" || m.scan.path != m.inputs[path].Value() {
+		return ""
+	}
+	return "\n    " + continueStyle.Render(m.scan.String())
+}
+
 // nextInput focuses the next input field
 func (m *model) nextInput() {
 	m.focused = (m.focused + 1) % len(m.inputs)
@@ -343,149 +1010,741 @@ func (m *model) prevInput() {
 	}
 }
 
-// gen is the func that generates the gif
-func gen(path, output, fps string) tea.Cmd {
-	if output == "" {
-		output = "out.gif"
+// scan is the func that summarizes images in the folder.
+func scan(path string, opts Options) tea.Cmd {
+	return func() tea.Msg {
+		return scanFolder(path, opts)
 	}
+}
+
+// waitForPhase waits for the next phase of the processing, returns nil when processing is done.
+func waitForPhase(phases <-chan phaseMsg) tea.Cmd {
 	return func() tea.Msg {
+		p, ok := <-phases
+		if !ok {
+			return nil
+		}
+		return p
+	}
+}
+
+// gen is the func that generates the gif, phases of the processing are sent to the phases channel if it's not nil.
+func gen(ctx context.Context, path, output string, opts Options, phases chan<- phaseMsg) tea.Cmd {
+	return func() tea.Msg {
+		if phases != nil {
+			defer close(phases)
+			// don't block the build when nobody waits for phases after it's canceled
+			opts.progress = func(p phaseMsg) {
+				select {
+				case phases <- p:
+				case <-ctx.Done():
+				}
+			}
+		}
 		start := time.Now()
+		warnings := []string{}
+		opts.warn = func(msg string) { warnings = append(warnings, msg) }
+		outDir := ""
+		if opts.outInInput {
+			outDir = inputFolder(path)
+		}
+		outs, err := resolveOutputs(output, outDir, opts.format)
+		if err != nil {
+			return resultMsg{err: err, emoji: "💾"}
+		}
+		// frames of a png sequence written into the folder of images would be read as images next time
+		for _, o := range outs {
+			if f, _ := outputFormat(o, opts.format); f == formatPngSequence && opts.fsys == nil && sameFolder(o, path) {
+				return resultMsg{err: fmt.Errorf("the png sequence can't be written into the input folder %s, pass another -out", path), emoji: "💾"}
+			}
+		}
+
+		// extract frames from a video into a temporary folder
+		src, listOpts := path, opts
+		if opts.fromVideo || isVideo(path) {
+			src, err = extractVideoFrames(path, opts.fps)
+			if err != nil {
+				return resultMsg{err: err, emoji: "🎞"}
+			}
+			defer os.RemoveAll(src)
+			// extracted frames are numbered in order, and always on the os file system
+			listOpts = extractedListOptions(listOpts)
+			opts.fsys = nil
+		}
+
+		// render pages of a pdf into a temporary folder
+		if isPdf(path) {
+			src, err = extractPdfPages(path)
+			if err != nil {
+				return resultMsg{err: err, emoji: "📄"}
+			}
+			defer os.RemoveAll(src)
+			listOpts = extractedListOptions(listOpts)
+			opts.fsys = nil
+		}
+
+		// decode frames of an animated webp into a temporary folder
+		if isWebp(path) {
+			src, err = extractWebpFrames(path, opts)
+			if err != nil {
+				return resultMsg{err: err, emoji: "🎞"}
+			}
+			defer os.RemoveAll(src)
+			listOpts = extractedListOptions(listOpts)
+			opts.fsys = nil
+		}
+
+		// decode frames of an animated gif into a temporary folder, keeping their delays if requested
+		if isGif(path) {
+			var delays map[string]int
+			src, delays, err = extractGifFrames(path, opts)
+			if err != nil {
+				return resultMsg{err: err, emoji: "🎞"}
+			}
+			defer os.RemoveAll(src)
+			listOpts = extractedListOptions(listOpts)
+			opts.fsys = nil
+			if opts.preserveTiming {
+				opts.sourceDelays = delays
+			}
+		} else if opts.preserveTiming {
+			opts.warnf("-preserve-timing needs an animated gif input, frames play at the frame rate")
+		}
+
 		// list files in path
-		paths, err := listFiles(path)
+		paths, err := listFiles(src, listOpts)
 		if err != nil {
 			return resultMsg{err: err, emoji: "📂"}
 		}
+		if len(*paths) == 0 {
+			return resultMsg{err: fmt.Errorf("no images found in %s", path), emoji: "📂"}
+		}
 
-		// parse fps
-		c := strings.ReplaceAll(fps, " ", "")
-		if c == "" {
-			c = "30"
+		// detect the frame rate from evenly saved images, fallback to the default one
+		if opts.fps == 0 && opts.autoFps {
+			var ok bool
+			if opts.fps, ok = detectFps(*paths, opts); !ok {
+				opts.warnf("can't detect the frame rate from modification times of images, using %d fps", defaultFps)
+			}
+		}
+
+		// skip the build if inputs and options didn't change since the last one
+		key := ""
+		if opts.cache {
+			key, err = cacheKey(*paths, opts)
+			if err != nil {
+				return resultMsg{err: err, emoji: "📂"}
+			}
+			if upToDate(outs, key) {
+				return resultMsg{emoji: "✅", duration: time.Since(start), outputs: outs, upToDate: true}
+			}
 		}
-		fpsVal, _ := strconv.ParseInt(c, 10, 64)
 
 		// build gif
 		err = BuildGif(
+			ctx,
 			paths,
-			output,
-			int(fpsVal),
+			strings.Join(outs, ","),
+			opts,
 		)
+		if errors.Is(err, context.Canceled) {
+			return resultMsg{err: err, emoji: "🛑"}
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return resultMsg{err: err, emoji: "⏱"}
+		}
 		if err != nil {
 			return resultMsg{err: err, emoji: "🔨"}
 		}
+		if opts.cache {
+			if err := saveCache(outs, key); err != nil {
+				warnings = append(warnings, fmt.Sprintf("can't save the build cache: %v", err))
+			}
+		}
 		duration := time.Since(start)
-		return resultMsg{err: nil, emoji: "🎉", duration: duration}
+		return resultMsg{err: nil, emoji: "🎉", duration: duration, outputs: outs, warnings: warnings}
+	}
+}
+
+// extractedListOptions returns the options to list frames extracted into a temporary folder with.
+// They are numbered pngs in order on the os file system, so filters of source files don't apply to them.
+func extractedListOptions(opts Options) Options {
+	opts.sort = sortName
+	opts.fsys = nil
+	opts.exts = nil
+	opts.since, opts.until = time.Time{}, time.Time{}
+	opts.template = ""
+	return opts
+}
+
+// resolveOutputs resolves comma separated paths to the output files and checks their formats.
+// Outputs without a folder are written into dir if it's not empty. format is the format of all outputs, if it's set.
+func resolveOutputs(output, dir, format string) ([]string, error) {
+	outs := splitOutputs(output)
+	if len(outs) == 0 {
+		outs = []string{""}
+	}
+	def := defaultOutput
+	if format == formatPngSequence {
+		def = defaultSequenceOutput
+	}
+	for i, o := range outs {
+		// a path like ./out.gif or . still points to the working folder
+		if dir != "" && (o == "" || filepath.Base(o) == o && o != "." && o != "..") {
+			if o == "" {
+				o = def
+			}
+			o = filepath.Join(dir, o)
+		}
+		f, err := outputFormat(o, format)
+		if err != nil {
+			return nil, err
+		}
+		// frames of a png sequence are written into the output folder
+		if f == formatPngSequence {
+			if o == "" {
+				o = def
+			}
+			outs[i] = o
+			continue
+		}
+		if o, err = resolveOutput(o); err != nil {
+			return nil, err
+		}
+		outs[i] = o
+	}
+	return outs, nil
+}
+
+// resolveOutput returns the path to the output file,
+// the default file name is used if the output is empty or points to a directory.
+func resolveOutput(output string) (string, error) {
+	if output == "" {
+		return defaultOutput, nil
+	}
+
+	isDir := strings.HasSuffix(output, string(os.PathSeparator))
+	if fi, err := os.Stat(output); err == nil && fi.IsDir() {
+		isDir = true
+	}
+	if !isDir {
+		return output, nil
+	}
+
+	// write the default file name into the output directory.
+	output = filepath.Join(output, defaultOutput)
+	if fi, err := os.Stat(output); err == nil && fi.IsDir() {
+		return "", fmt.Errorf("output path is a directory, pass a path to a file instead: %s", output)
+	}
+	return output, nil
+}
+
+// inputFolder returns the folder the input is in, the input itself if it's a folder,
+// or an empty string for URLs and the standard input, as they have no folder.
+func inputFolder(path string) string {
+	if isURL(path) || path == stdinPath {
+		return ""
+	}
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		return path
 	}
+	return filepath.Dir(path)
 }
 
 /* ------------------------------------------------------------ */
 /* --------------------- WORK WITH IMAGES --------------------- */
 /* ------------------------------------------------------------ */
 
+// readdirBatch is the number of names read from a folder at once.
+const readdirBatch = 1024
+
 // list files in path
-func listFiles(path string) (*[]string, error) {
-	var files []string
-	dir, err := os.Open(path)
-	if err != nil {
-		return nil, err
+func listFiles(path string, opts Options) (*[]string, error) {
+	// a single image by its URL, or a list of paths and URLs from the standard input
+	if isURL(path) {
+		return &[]string{path}, nil
+	}
+	if path == stdinPath {
+		return parseManifest(os.Stdin, "stdin", ".", opts)
+	}
+	// numbered frames of the template in the folder, in the order of their numbers
+	if opts.template != "" {
+		return templateFiles(path, opts)
+	}
+
+	// read the list of files from a manifest if path points to a file
+	if fi, err := opts.stat(path); err == nil && !fi.IsDir() {
+		return readManifest(path, opts)
 	}
-	defer dir.Close()
 
-	fileInfos, err := dir.Readdir(-1)
+	var files []string
+	f, err := opts.open(path)
 	if err != nil {
-		return nil, err
+		return nil, permissionError(path, err)
+	}
+	defer f.Close()
+	dir, ok := f.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fileError{"read folder", path, errors.New("not a folder")}
 	}
 
-	for _, fi := range fileInfos {
-		if !fi.IsDir() {
-			// add file to list if it is not a .png or .jpg
-			if filepath.Ext(fi.Name()) == ".png" || filepath.Ext(fi.Name()) == ".jpg" {
-				files = append(files, filepath.Join(path, fi.Name()))
+	// read entries in batches and keep only images, so huge folders don't take all entries in memory at once
+	images := []os.FileInfo{}
+	for {
+		entries, err := dir.ReadDir(readdirBatch)
+		for _, e := range entries {
+			// add file to list if it is an image of a registered format, or has one of the -ext extensions
+			if e.IsDir() || !opts.isImage(e.Name()) {
+				continue
+			}
+			fi, err := e.Info()
+			if err != nil {
+				return nil, permissionError(path, err)
 			}
+			if !opts.inTimeWindow(fi.ModTime()) {
+				continue
+			}
+			images = append(images, fi)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, permissionError(path, err)
 		}
 	}
-	sort.Strings(files)
+	switch opts.sort {
+	case sortExif:
+		sortByExif(images, path, opts)
+	case sortNatural:
+		sortByNumber(images, opts.sortNumber)
+	default:
+		sortFileInfos(images, opts.sort)
+	}
+	if opts.sortDesc {
+		reverseFileInfos(images)
+	}
+
+	for _, fi := range images {
+		files = append(files, filepath.Join(path, fi.Name()))
+	}
 
 	return &files, nil
 }
 
-func readImages(files *[]string) ([]imgWithDelay, error) {
+// scanFolder lists images in the path and reads the size of the first one without decoding it.
+func scanFolder(path string, opts Options) scanMsg {
+	if path == "" || opts.fromVideo || isVideo(path) || isWebp(path) || isGif(path) {
+		return scanMsg{}
+	}
+	res := scanMsg{path: path}
+
+	files, err := listFiles(path, opts)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	res.count = len(*files)
+	if res.count == 0 {
+		return res
+	}
+
+	f, err := opts.open((*files)[0])
+	if err != nil {
+		res.err = &fileError{"open file", (*files)[0], err}
+		return res
+	}
+	defer f.Close()
+
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		res.err = &fileError{"decode image", (*files)[0], err}
+		return res
+	}
+	res.size = image.Point{cfg.Width, cfg.Height}
+	return res
+}
+
+func readImages(ctx context.Context, files *[]string, opts Options) ([]imgWithDelay, error) {
 	// create slice of images
 	images := []imgWithDelay{}
 	// save previous image to compare with current and count delay (equal images in a row)
 	prevImg := image.Image(nil)
-	delay := 1
+	// keptImg is the image from equal images in a row that is added to the gif
+	keptImg := image.Image(nil)
+	delay := 0
+	sources := []string{}
+	// downscaled is the size of the first image that was too large
+	var downscaled *image.Point
+	// blank is the number of dropped single-color images
+	blank := 0
+
+	holds, err := gapHolds(*files, opts)
+	if err != nil {
+		return nil, err
+	}
 
-	// read images from files
-	for _, s := range *files {
-		f, err := os.Open(s)
-		if err != nil {
-			return nil, fmt.Errorf("failed to open file (%s): %w", s, err)
+	// keep every Nth file, each kept file stands for the skipped ones after it
+	paths, weights := sampleFiles(*files, opts.sample)
+	// with delays of sources, kept files stand for the delays of the files in 100ths of a second instead of their count
+	if opts.sourceDelays != nil {
+		for i := range weights {
+			weights[i] = 0
 		}
-		defer f.Close()
+		for i, f := range *files {
+			weights[sampledIndex(i, opts.sample)] += opts.sourceDelays[f]
+		}
+	}
+	// kept files also hold for missing frames after the ones they stand for
+	for i, h := range holds {
+		weights[sampledIndex(i, opts.sample)] += h
+	}
 
-		img, _, err := image.Decode(f)
-		if err != nil {
-			return nil, fmt.Errorf("failed to decode image (%s): %w", s, err)
+	err = decodeImages(ctx, paths, opts, func(n int, img image.Image) {
+		opts.report(phaseDecoding, n+1, len(paths))
+		opts.logger().Debug("frame decoded", "file", paths[n], "index", sourceIndex(n, opts.sample))
+		if opts.dropBlank && isBlank(img) {
+			opts.logger().Debug("blank frame dropped", "file", paths[n])
+			blank++
+			return
+		}
+		size := img.Bounds().Size()
+		img, scaled := transformImage(img, sourceIndex(n, opts.sample), paths[n], opts)
+		if scaled && downscaled == nil {
+			downscaled = &size
 		}
 
-		// if prevImg is not nil, compare it with current image, if they are equal, increase delay,
-		// else add previous image to slice of images, reset delay, and set current image as previous
-		if prevImg != nil {
-			if !imagesEqual(prevImg, img) {
-				images = append(images, imgWithDelay{prevImg, delay})
-				delay = 1
-				prevImg = img
-			} else {
-				delay++
+		// if current image is not equal to the previous one, add kept image to slice of images,
+		// and start a new run of equal images with the current image as previous
+		// the first and the last images are kept as separate frames with keepEndpoints
+		endpoint := opts.keepEndpoints && (n == 1 || n == len(paths)-1)
+		dedup := prevImg != nil && !opts.noDedup && !endpoint
+		similar := dedup && FramesSimilar(prevImg, img, opts.similarity())
+		// if the image returns to one of the previous frames in the window after a brief change,
+		// frames in between are dropped and the run of that frame goes on
+		if dedup && !similar {
+			if j := windowMatch(images, img, opts); j >= 0 {
+				run := images[j]
+				for _, im := range images[j+1:] {
+					run.delay += im.delay
+					run.sources = append(run.sources, im.sources...)
+				}
+				run.delay += delay
+				run.sources = append(run.sources, sources...)
+				opts.logger().Debug("frames dropped", "count", len(images)-j, "file", paths[n])
+				images = images[:j]
+				prevImg, keptImg, delay, sources = run.img, run.img, run.delay, run.sources
+				similar = true
+			}
+		}
+		if !similar {
+			if prevImg != nil {
+				images = append(images, imgWithDelay{keptImg, delay, sources})
 			}
-		} else {
 			prevImg = img
+			keptImg = img
+			delay = 0
+			sources = []string{}
+		} else {
+			opts.logger().Debug("frame merged", "file", paths[n])
+			if opts.keep == keepLast {
+				keptImg = img
+			}
 		}
+		delay += weights[n]
+		sources = append(sources, paths[n])
+	})
+	if err != nil {
+		return nil, err
 	}
+
 	// add last image to slice of images
-	images = append(images, imgWithDelay{prevImg, delay})
+	if prevImg != nil {
+		images = append(images, imgWithDelay{keptImg, delay, sources})
+	}
+	if blank > 0 {
+		if len(images) == 0 {
+			return nil, fmt.Errorf("all %d images are blank, there is nothing left to build: check the path, or build without -drop-blank", blank)
+		}
+		opts.warnf("%d blank images of a single color are dropped", blank)
+	}
+	if downscaled != nil {
+		opts.warnf("images of %d×%d are larger than %dpx, frames are scaled down to fit", downscaled.X, downscaled.Y, opts.maxDimension)
+	}
 	return images, nil
 }
 
-func imagesEqual(a, b image.Image) bool {
+// windowMatch returns the index of the latest of previous frames in the dedup window the image is similar to,
+// the window counts the current run of equal images too, it's compared separately. -1 if there is none.
+func windowMatch(frames []imgWithDelay, img image.Image, opts Options) int {
+	for j := len(frames) - 1; j >= 0 && j >= len(frames)-(opts.dedupWindow-1); j-- {
+		if FramesSimilar(frames[j].img, img, opts.similarity()) {
+			return j
+		}
+	}
+	return -1
+}
+
+// decodeImages decodes images concurrently in batches of the io threads size to keep memory bounded,
+// and passes them to the fn in the order of files.
+func decodeImages(ctx context.Context, files []string, opts Options, fn func(n int, img image.Image)) error {
+	batch := opts.ioThreads()
+	decoded := make([]image.Image, batch)
+
+	for start := 0; start < len(files); start += batch {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := start + batch
+		if end > len(files) {
+			end = len(files)
+		}
+
+		errGroup := errgroup.Group{}
+		for n := start; n < end; n++ {
+			n := n
+			errGroup.Go(func() error {
+				img, err := decodeImage(files[n], opts)
+				decoded[n-start] = img
+				return err
+			})
+		}
+		if err := errGroup.Wait(); err != nil {
+			return err
+		}
+
+		for n := start; n < end; n++ {
+			fn(n, decoded[n-start])
+			decoded[n-start] = nil
+		}
+	}
+	return nil
+}
+
+// sourceIndex returns the index of a sampled file in the source before sampling.
+func sourceIndex(n, sample int) int {
+	if sample < 1 {
+		return n
+	}
+	return n * sample
+}
+
+// sampleFiles keeps every nth file and returns it with the number of source files each kept file stands for.
+func sampleFiles(files []string, n int) ([]string, []int) {
+	if n < 1 {
+		n = 1
+	}
+	sampled := make([]string, 0, (len(files)+n-1)/n)
+	weights := make([]int, 0, cap(sampled))
+	for i := 0; i < len(files); i += n {
+		sampled = append(sampled, files[i])
+		if i+n > len(files) {
+			weights = append(weights, len(files)-i)
+		} else {
+			weights = append(weights, n)
+		}
+	}
+	return sampled, weights
+}
+
+// sampledIndex returns the index of the kept file that stands for the source file i.
+func sampledIndex(i, n int) int {
+	if n < 1 {
+		n = 1
+	}
+	return i / n
+}
+
+// fileError is an error that happened while working with a file.
+// @property {string} op - The operation that failed, e.g. "decode image".
+// @property {string} path - The path to the file.
+// @property {error} err - The cause of the error.
+type fileError struct {
+	op   string
+	path string
+	err  error
+}
+
+func (e *fileError) Error() string {
+	return fmt.Sprintf("failed to %s (%s): %v", e.op, e.path, e.err)
+}
+
+func (e *fileError) Unwrap() error {
+	return e.err
+}
+
+// permissionError replaces a permission error of the folder with a hint to check its permissions,
+// other errors are returned as they are.
+func permissionError(path string, err error) error {
+	if errors.Is(err, fs.ErrPermission) {
+		return &fileError{"read folder", path, fmt.Errorf("%w, check that the folder and its files are readable by the current user", fs.ErrPermission)}
+	}
+	return err
+}
+
+// decodeImage opens and decodes the image file.
+func decodeImage(s string, opts Options) (image.Image, error) {
+	f, err := opts.open(s)
+	if err != nil {
+		return nil, &fileError{"open file", s, err}
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, &fileError{"decode image", s, err}
+	}
+	return img, nil
+}
+
+// FramesSimilar checks if two frames are equal within the thresholds, so one of them can be dropped.
+// The result only depends on the images and thresholds.
+func FramesSimilar(a, b image.Image, opts SimilarityOptions) bool {
+	if !imagesEqual(a, b, opts) {
+		return false
+	}
+	if opts.Alpha {
+		return alphaEqual(a, b)
+	}
+	return true
+}
+
+// minIconSize is the min width and height of images compared by icons,
+// icons of smaller images are degenerate and look equal, so their pixels are compared exactly.
+const minIconSize = 16
+
+// imagesEqual compares icons of images by proportions and colors.
+func imagesEqual(a, b image.Image, th SimilarityOptions) bool {
+	if isTiny(a) || isTiny(b) {
+		return pixelsEqual(a, b)
+	}
 	// Icons are compact image representations (image "hashes").
 	// Name "hash" is not used intentionally.
 	iconA := images4.Icon(a)
 	iconB := images4.Icon(b)
 
 	// Compare icons by proportion similarity metric.
-	if images4.PropMetric(iconA, iconB) > 0.001 {
+	if images4.PropMetric(iconA, iconB) > th.Prop {
 		return false
 	}
 	// Compare icons by Euclidean distance in YCbCr color space.
 	m1, m2, m3 := images4.EucMetric(iconA, iconB)
-	if m1 > thy {
+	if m1 > th.Y {
 		return false
 	}
-	if m2 > thCbCr || m3 > thCbCr {
+	if m2 > th.CbCr || m3 > th.CbCr {
 		return false
 	}
 	return true
 }
 
+// isTiny checks if the image is too small to be compared by icons.
+func isTiny(img image.Image) bool {
+	size := img.Bounds().Size()
+	return size.X < minIconSize || size.Y < minIconSize
+}
+
+// pixelsEqual compares images pixel by pixel, including transparency.
+func pixelsEqual(a, b image.Image) bool {
+	ba, bb := a.Bounds(), b.Bounds()
+	if ba.Size() != bb.Size() {
+		return false
+	}
+	for y := 0; y < ba.Dy(); y++ {
+		for x := 0; x < ba.Dx(); x++ {
+			if color.RGBA64Model.Convert(a.At(ba.Min.X+x, ba.Min.Y+y)) != color.RGBA64Model.Convert(b.At(bb.Min.X+x, bb.Min.Y+y)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// alphaEqual compares the alpha channel of images pixel by pixel, as images4 icons ignore transparency.
+func alphaEqual(a, b image.Image) bool {
+	ba, bb := a.Bounds(), b.Bounds()
+	if ba.Dx() != bb.Dx() || ba.Dy() != bb.Dy() {
+		return false
+	}
+	for y := 0; y < ba.Dy(); y++ {
+		for x := 0; x < ba.Dx(); x++ {
+			_, _, _, aa := a.At(ba.Min.X+x, ba.Min.Y+y).RGBA()
+			_, _, _, ab := b.At(bb.Min.X+x, bb.Min.Y+y).RGBA()
+			if aa > ab && aa-ab > thAlpha || ab > aa && ab-aa > thAlpha {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 // encode and decode is necessary to convert jpeg and png to gif.
-func encodeImgPaletted(images *[]imgWithDelay) ([]*palettedWithDelay, error) {
+func encodeImgPaletted(ctx context.Context, images *[]imgWithDelay, opts Options) ([]*palettedWithDelay, error) {
 	// Gif options
-	opt := gif.Options{}
+	opt := gif.Options{Drawer: opts.drawer()}
+	// the plan9 palette can't be reduced, so fewer colors are found with k-means
+	if (opts.quantizer != "" && opts.quantizer != quantizerDefault) || opts.colors() < 256 {
+		opt.NumColors = opts.colors()
+		opt.Quantizer = opts.paletteQuantizer()
+	}
+	// map all frames onto one palette built from all or sampled frames
+	if opts.palette == nil && len(*images) > 0 && (opts.paletteMode == paletteModeGlobal || opts.paletteMode == paletteModeSampled) {
+		samples := globalSamples
+		if opts.paletteMode == paletteModeGlobal {
+			samples = 0
+		}
+		p, err := globalPalette(*images, samples, opts)
+		if err != nil {
+			return nil, err
+		}
+		opts.palette = p
+	}
+	// each go routine writes its own index, so the slice needs no lock
 	imgp := make([]*palettedWithDelay, len(*images))
 
 	// create a go routine for each image. and wait for all to finish.
-	errGroup, _ := errgroup.WithContext(context.Background())
-	lck := sync.Mutex{}
+	errGroup, ctx := errgroup.WithContext(ctx)
+	errGroup.SetLimit(opts.encodeThreads())
+	done := int32(0)
+	opts.report(phaseEncoding, 0, len(*images))
 
 	for ctr, im := range *images {
 		ctr := ctr
 		im := im
 		// create a go routine for each image. And wait for all to finish. Check if any errors.
 		errGroup.Go(func() error {
+			defer func() {
+				opts.report(phaseEncoding, int(atomic.AddInt32(&done, 1)), len(*images))
+			}()
+			// Skip the rest of images if the build is canceled.
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			// Map the image onto the fixed palette with dithering.
+			if opts.palette != nil {
+				b := im.img.Bounds()
+				i := image.NewPaletted(b, opts.palette)
+				opts.drawer().Draw(i, b, im.img, b.Min)
+				imgp[ctr] = &palettedWithDelay{i, im.delay}
+				return nil
+			}
+			// Use exact colors of the image if they fit into a gif palette.
+			if opts.exactPalette {
+				if i := exactPaletted(im.img); i != nil {
+					imgp[ctr] = &palettedWithDelay{i, im.delay}
+					return nil
+				}
+			}
+			o := opt
+			// the plan9 palette bands smooth gradients of 16-bit images, so they get their own dithered palette
+			if o.Quantizer == nil && highBitDepth(im.img) {
+				o.NumColors = opts.colors()
+				o.Quantizer = opts.paletteQuantizer()
+				o.Drawer = opts.drawer()
+			}
 			b := bytes.Buffer{}
 			// Write file to buffer.
-			err := gif.Encode(&b, im.img, &opt)
+			err := gif.Encode(&b, im.img, &o)
 			if err != nil {
 				return err
 			}
@@ -494,13 +1753,12 @@ func encodeImgPaletted(images *[]imgWithDelay) ([]*palettedWithDelay, error) {
 			if err != nil {
 				return err
 			}
-			// Cast img.
+			// Cast img, a missing frame would fail later when the gif is written.
 			i, ok := img.(*image.Paletted)
-			if ok {
-				lck.Lock()
-				defer lck.Unlock()
-				imgp[ctr] = &palettedWithDelay{i, im.delay}
+			if !ok {
+				return fmt.Errorf("encode frame %d: decoded %T instead of a paletted image", ctr+1, img)
 			}
+			imgp[ctr] = &palettedWithDelay{i, im.delay}
 			return nil
 		})
 	}
@@ -511,42 +1769,376 @@ func encodeImgPaletted(images *[]imgWithDelay) ([]*palettedWithDelay, error) {
 	return imgp, nil
 }
 
-// write a file from a paletted image slice, delay in 100ths of a second per frame.
-func writeGif(im *[]*palettedWithDelay, delay int, path string) error {
-	g := &gif.GIF{}
+// exactPaletted converts an image to a paletted one with its own colors,
+// returns nil if the image has more colors than a gif palette can hold.
+func exactPaletted(img image.Image) *image.Paletted {
+	b := img.Bounds()
+	pal := color.Palette{}
+	index := map[color.RGBA]uint8{}
+	p := image.NewPaletted(b, nil)
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			i, ok := index[c]
+			if !ok {
+				if len(pal) == 256 {
+					return nil
+				}
+				i = uint8(len(pal))
+				index[c] = i
+				pal = append(pal, c)
+			}
+			p.SetColorIndex(x, y, i)
+		}
+	}
+	p.Palette = pal
+	return p
+}
+
+// frameDelays returns delays of frames in 100ths of a second, delay is in 100ths of a second per source image.
+// reps are the numbers of image repetitions in the source for every frame.
+// With the delay in milliseconds in options, it's used instead and the rounding error is carried to next frames,
+// so frames end at the closest 100th of a second to their time and the animation doesn't drift.
+func frameDelays(reps []int, delay int, opts Options) []int {
+	delays := make([]int, len(reps))
+	elapsed, shown := 0, 0
+	for n, r := range reps {
+		// every frame is shown for the same time with uniformDelay, however many images it stands for
+		if opts.uniformDelay {
+			r = 1
+		}
+		if opts.delayMs > 0 {
+			elapsed += opts.delayMs * r
+			delays[n] = (elapsed+5)/10 - shown
+			shown += delays[n]
+			continue
+		}
+		delays[n] = delay * r
+	}
+	if len(delays) == 0 {
+		return delays
+	}
+	if opts.speed > 0 && opts.speed != 1 {
+		delays = scaleDelays(delays, opts.speed)
+	}
+
+	// hold the first and the last frames longer if requested, the last hold wins for a single frame.
+	if opts.firstHold > 0 {
+		delays[0] = opts.firstHold
+	}
+	if opts.lastHold > 0 {
+		delays[len(delays)-1] = opts.lastHold
+	}
+	return delays
+}
+
+// maxGifDelay is the max delay of a gif frame in 100ths of a second, it's a 16-bit number.
+const maxGifDelay = 0xffff
+
+// scaleDelays divides delays by the speed, e.g. 2 plays twice as fast. The rounding error is carried to next frames,
+// so the animation keeps its scaled total time, and delays stay from 1 to the max a gif can hold.
+func scaleDelays(delays []int, speed float64) []int {
+	scaled := make([]int, len(delays))
+	elapsed, shown := 0.0, 0
+	for n, d := range delays {
+		elapsed += float64(d) / speed
+		scaled[n] = int(math.Round(elapsed)) - shown
+		if d > 0 {
+			scaled[n] = min(max(scaled[n], 1), maxGifDelay)
+		}
+		shown += scaled[n]
+	}
+	return scaled
+}
+
+// splitDelays repeats frames with delays longer than the max one, so each copy is shown for at most the max delay,
+// some players don't render very long delays well. 0 max delay keeps frames as they are.
+func splitDelays[T any](frames []T, delays []int, maxDelay int) ([]T, []int) {
+	if maxDelay <= 0 {
+		return frames, delays
+	}
+	splitFrames, splitDelays := []T{}, []int{}
+	for n, d := range delays {
+		for d > maxDelay {
+			splitFrames, splitDelays = append(splitFrames, frames[n]), append(splitDelays, maxDelay)
+			d -= maxDelay
+		}
+		splitFrames, splitDelays = append(splitFrames, frames[n]), append(splitDelays, d)
+	}
+	return splitFrames, splitDelays
+}
+
+// encodeGif encodes a paletted image slice as a gif to the writer, delay in 100ths of a second per frame.
+func encodeGif(w io.Writer, im *[]*palettedWithDelay, delay int, opts Options) error {
+	g := &gif.GIF{LoopCount: opts.loopCount}
+	reps := []int{}
 
 	for _, i := range *im {
 		g.Image = append(g.Image, i.paletted)
-		// delay is in 100ths of a second per frame, i.delay represents image repetitions in the source.
-		g.Delay = append(g.Delay, delay*i.delay)
+		reps = append(reps, i.delay)
+	}
+	g.Image, g.Delay = splitDelays(g.Image, frameDelays(reps, delay, opts), opts.maxDelay)
+	if opts.commentFps {
+		fps, err := normalizeFps(opts.fps)
+		if err != nil {
+			return err
+		}
+		w = &gifCommentWriter{w: w, comment: fmt.Sprintf("png2gif fps=%d", fps)}
+	}
+	if opts.optimizeStatic {
+		g.Image, g.Disposal = optimizeStatic(g.Image)
+	}
+	alignTransparentIndex(g.Image)
+	if opts.canvas != (image.Point{}) {
+		if err := placeOnCanvas(g.Image, opts.canvas, opts.canvasAt, opts.canvasColor); err != nil {
+			return err
+		}
+		g.Config = image.Config{Width: opts.canvas.X, Height: opts.canvas.Y}
 	}
 
-	f, err := os.Create(path)
-	if err != nil {
-		return err
+	if (opts.stream || opts.interlace) && len(g.Image) > 0 {
+		return streamGif(w, g, opts.interlace)
 	}
-	defer f.Close()
+	// the palette is written once as the global color table, frames with the same palette skip their own,
+	// the background index refers to it, so the palette of the first frame becomes the global one for it
+	if (opts.paletteMode == paletteModeGlobal || opts.bgIndex != nil || opts.bgColor != nil) && len(g.Image) > 0 {
+		if g.Config.Width == 0 {
+			screen := g.Image[0].Bounds().Max
+			g.Config.Width, g.Config.Height = screen.X, screen.Y
+		}
+		pal := g.Image[0].Palette
+		g.Config.ColorModel = pal
+		bg, err := backgroundIndex(pal, opts)
+		if err != nil {
+			return err
+		}
+		g.BackgroundIndex = bg
+	}
+	return gif.EncodeAll(w, g)
+}
 
-	return gif.EncodeAll(f, g)
+// backgroundIndex returns the index of the background color in the global palette,
+// the requested index or the closest color to the requested one, 0 if neither is set.
+func backgroundIndex(pal color.Palette, opts Options) (byte, error) {
+	switch {
+	case opts.bgIndex != nil:
+		if *opts.bgIndex >= len(pal) {
+			return 0, fmt.Errorf("background index %d is out of the palette of %d colors", *opts.bgIndex, len(pal))
+		}
+		return byte(*opts.bgIndex), nil
+	case opts.bgColor != nil:
+		return byte(pal.Index(opts.bgColor)), nil
+	}
+	return 0, nil
 }
 
-// BuildGif takes an array of file paths pointing to images as input.
-// out: path to the output file.
-// fps: frames per second, default 30.
-func BuildGif(files *[]string, out string, fps int) error {
-	if fps == 0 {
-		fps = 30
+// streamGif writes frames of the gif one by one, the output is the same as of gif.EncodeAll if not interlaced.
+func streamGif(w io.Writer, g *gif.GIF, interlace bool) error {
+	bw := bufio.NewWriter(w)
+	screen := g.Image[0].Bounds().Max
+	if g.Config.Width > 0 && g.Config.Height > 0 {
+		screen = image.Pt(g.Config.Width, g.Config.Height)
+	}
+	s := newGifStreamWriter(bw, screen.X, screen.Y, len(g.Image), g.LoopCount)
+	s.interlace = interlace
+	for i, p := range g.Image {
+		disposal := byte(0)
+		if g.Disposal != nil {
+			disposal = g.Disposal[i]
+		}
+		if err := s.writeFrame(p, g.Delay[i], disposal); err != nil {
+			return err
+		}
 	}
+	if err := s.close(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
 
-	img, err := readImages(files)
+// Convert builds a gif from images in the folder with the default settings:
+// 30 fps, equal images in a row merged into one frame. The format of the output is chosen by its extension.
+func Convert(inputDir, outputPath string) error {
+	opts := Options{}
+	files, err := listFiles(inputDir, opts)
 	if err != nil {
 		return err
 	}
+	if len(*files) == 0 {
+		return fmt.Errorf("no images found in %s", inputDir)
+	}
+	return BuildGif(context.Background(), files, outputPath, opts)
+}
 
-	im_p, err := encodeImgPaletted(&img)
+// BuildGif takes an array of file paths pointing to images as input.
+// ctx: cancels the build.
+// out: path to the output file, or comma separated paths to write several formats from the same frames.
+// opts: options to tweak the build, the zero value builds with the default settings.
+func BuildGif(ctx context.Context, files *[]string, out string, opts Options) error {
+	fps, err := normalizeFps(opts.fps)
 	if err != nil {
 		return err
 	}
 
-	return writeGif(&im_p, 100/fps, out)
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+
+	outs := splitOutputs(out)
+	for _, o := range outs {
+		format, err := outputFormat(o, opts.format)
+		if err != nil {
+			return err
+		}
+		// -format wins over the extension, the content of the file is in the format anyway
+		if ext := filepath.Ext(o); ext != "" {
+			if byExt, _ := outputFormat(o, ""); byExt != format {
+				opts.warnf("%s is written as %s as -format is set, its %s extension doesn't match", o, format, ext)
+			}
+		}
+		// fail before decoding images if the video encoder is missing
+		if format == formatWebm {
+			if _, err := findFfmpeg(); err != nil {
+				return err
+			}
+		}
+	}
+	if opts.appendOutput {
+		if len(outs) != 1 {
+			return fmt.Errorf("frames can only be appended to a single .gif output")
+		}
+		if format, _ := outputFormat(outs[0], opts.format); format != formatGif {
+			return fmt.Errorf("frames can only be appended to a single .gif output")
+		}
+	}
+
+	// gif and apng delays are in 100ths of a second,
+	// frames with delays of their sources count them in 100ths of a second too
+	delay := 100 / fps
+	switch {
+	case opts.format == formatPngSequence:
+		// frames of png sequences have no delays, so there is nothing to warn about
+	case opts.sourceDelays != nil:
+		delay = 1
+	case opts.delayMs > 0:
+		// the closest delay is used where frames need a single one, e.g. the frame rate of videos
+		delay = max(1, (opts.delayMs+5)/10)
+		if opts.delayMs%10 != 0 {
+			opts.warnf("%dms can't be represented exactly in 100ths of a second, delays of frames alternate to keep the total time", opts.delayMs)
+		}
+		if opts.delayMs < 20 {
+			opts.warnf("browsers slow down frames shorter than 20ms, use -delay-ms 20 or more")
+		}
+	default:
+		// the default frame rate isn't exact either, but only the one asked for is worth a warning
+		if opts.fps != 0 && 100%fps != 0 {
+			opts.warnf("%d fps can't be represented exactly, frames play at %.4g fps", fps, 100/float64(100/fps))
+		}
+		if 100/fps < 2 {
+			opts.warnf("browsers slow down frames shorter than 2/100 of a second, use 50 fps or less")
+		}
+	}
+
+	img, err := readImages(ctx, files, opts)
+	if err != nil {
+		return timeoutError(err, opts)
+	}
+	if opts.deflicker {
+		img = deflickerImages(img, opts)
+	}
+	if opts.trimStatic {
+		img = trimStatic(img, opts)
+	}
+	if opts.appendOutput {
+		existing, loopCount, err := readGifFrames(outs[0], delay, opts)
+		if err != nil {
+			return err
+		}
+		if img, err = appendFrames(existing, img); err != nil {
+			return err
+		}
+		opts.loopCount = loopCount
+	}
+	// a gif of one frame is static, which is rarely wanted from several images:
+	// it's an error below -min-frames and a warning otherwise
+	static := len(*files) > 1 && len(img) == 1 && !opts.allowStatic
+	if len(img) == 0 {
+		return fmt.Errorf("no images to build from")
+	}
+	if len(img) < opts.minFrames && !(opts.allowStatic && len(img) == 1) {
+		if static {
+			return fmt.Errorf("all %d images are identical and merged into a single frame: use -no-dedup to keep them, or -allow-static or -min-frames 1 for a single-frame gif", len(*files))
+		}
+		return fmt.Errorf("only %d frame(s) left after merging equal images, at least %d are needed: check the path, or lower -min-frames, -allow-static builds a single-frame gif", len(img), opts.minFrames)
+	}
+	if static {
+		opts.warnf("all %d frames are identical, the output is a single-frame gif, use -no-dedup to keep them or -allow-static to hide this warning", len(*files))
+	}
+	img = blendImages(img, opts.blend, opts)
+
+	// frames of the first output are reported, -target-size drops and scales them to fit
+	written := img
+	for n, o := range outs {
+		frames := img
+		if err := writeOutput(ctx, &frames, delay, o, opts); err != nil {
+			return timeoutError(err, opts)
+		}
+		if n == 0 {
+			written = frames
+		}
+	}
+	if opts.reportPath != "" {
+		return writeReport(written, delay, opts.reportPath, opts)
+	}
+	return nil
+}
+
+// timeoutError replaces the deadline error of the context with a clear one.
+func timeoutError(err error, opts Options) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("build timed out after %s: %w", opts.timeout, err)
+	}
+	return err
+}
+
+// output formats.
+const (
+	formatGif         = "gif"
+	formatApng        = "apng"
+	formatWebm        = "webm"
+	formatPngSequence = "png-sequence"
+)
+
+// outputFormat returns the format of the output file by its extension, or the format if it's set for all outputs.
+func outputFormat(path, format string) (string, error) {
+	switch format {
+	case "":
+	case formatGif, formatApng, formatWebm, formatPngSequence:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported output format %q, use gif, apng, webm or png-sequence", format)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gif":
+		return formatGif, nil
+	case ".png", ".apng":
+		return formatApng, nil
+	case ".webm":
+		return formatWebm, nil
+	}
+	return "", fmt.Errorf("unsupported output format of %s, use .gif, .png, .apng or .webm", path)
+}
+
+// splitOutputs splits comma separated output paths.
+func splitOutputs(out string) []string {
+	outs := []string{}
+	for _, o := range strings.Split(out, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			outs = append(outs, o)
+		}
+	}
+	return outs
 }
@@ -0,0 +1,1057 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/vitali-fedulov/images4"
+)
+
+func TestCompareAlpha(t *testing.T) {
+	opaque := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	draw.Draw(opaque, opaque.Bounds(), &image.Uniform{color.NRGBA{0, 0, 0, 255}}, image.Point{}, draw.Src)
+	// the same colors, but the left half is transparent, black stays black when colors are premultiplied by alpha
+	holed := image.NewNRGBA(opaque.Bounds())
+	draw.Draw(holed, holed.Bounds(), opaque, image.Point{}, draw.Src)
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 16; x++ {
+			holed.SetNRGBA(x, y, color.NRGBA{0, 0, 0, 0})
+		}
+	}
+
+	frames := readTestImages(t, Options{compare: compareAlpha}, opaque, holed, holed)
+	if got := frameDelaysOf(frames); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("alpha: got delays %v, want [1 2]", got)
+	}
+	if got := frameDelaysOf(readTestImages(t, Options{}, opaque, holed, holed)); !slices.Equal(got, []int{3}) {
+		t.Errorf("rgb: got delays %v, want [3]", got)
+	}
+	if !FramesSimilar(opaque, holed, Options{}.similarity()) {
+		t.Error("frames that differ only in alpha aren't similar by rgb")
+	}
+	if FramesSimilar(opaque, holed, Options{compare: compareAlpha}.similarity()) {
+		t.Error("frames that differ in alpha are similar with -compare alpha")
+	}
+	if !FramesSimilar(holed, holed, Options{compare: compareAlpha}.similarity()) {
+		t.Error("equal frames aren't similar with -compare alpha")
+	}
+}
+
+func TestKeep(t *testing.T) {
+	first, last := color.RGBA{100, 100, 100, 255}, color.RGBA{102, 100, 100, 255}
+	images := []image.Image{testFrame(32, 32, first), testFrame(32, 32, first), testFrame(32, 32, last), testFrame(32, 32, testBlue)}
+	for _, tt := range []struct {
+		keep string
+		want color.Color
+	}{{"", first}, {keepFirst, first}, {keepLast, last}} {
+		frames := readTestImages(t, Options{keep: tt.keep}, images...)
+		if got := frameDelaysOf(frames); !slices.Equal(got, []int{3, 1}) {
+			t.Fatalf("keep %q: got delays %v, want [3 1]", tt.keep, got)
+		}
+		if got := frames[0].img.At(0, 0); !colorsEqual(got, tt.want) {
+			t.Errorf("keep %q: got the frame of %v, want %v", tt.keep, got, tt.want)
+		}
+		if got := frames[1].img.At(0, 0); !colorsEqual(got, testBlue) {
+			t.Errorf("keep %q: got the last frame of %v, want %v", tt.keep, got, testBlue)
+		}
+	}
+}
+
+func TestLossless(t *testing.T) {
+	cfg := parseTestFlags(t, "-lossless")
+	if !cfg.opts.noDedup || !cfg.opts.exactPalette {
+		t.Fatalf("-lossless sets no dedup %v and exact palettes %v", cfg.opts.noDedup, cfg.opts.exactPalette)
+	}
+	// nearly equal frames are merged by dedup, but not with -lossless
+	colors := []color.Color{color.RGBA{100, 100, 100, 255}, color.RGBA{101, 100, 100, 255}, color.RGBA{101, 100, 100, 255}, testBlue}
+	images := []image.Image{}
+	for _, c := range colors {
+		images = append(images, testGradient(32, 32, c))
+	}
+	g := buildTestGif(t, cfg.opts, images...)
+	if len(g.Image) != len(images) {
+		t.Fatalf("got %d frames, want %d", len(g.Image), len(images))
+	}
+	for n, frame := range g.Image {
+		for y := 0; y < 32; y++ {
+			for x := 0; x < 32; x++ {
+				if !colorsEqual(frame.At(x, y), images[n].At(x, y)) {
+					t.Fatalf("frame %d: got %v at %d,%d, want %v", n, frame.At(x, y), x, y, images[n].At(x, y))
+				}
+			}
+		}
+	}
+	if len(g.Image[0].Palette) == len(g.Image[3].Palette) && colorsEqual(g.Image[0].Palette[0], g.Image[3].Palette[0]) {
+		t.Error("frames share a palette")
+	}
+	if dedup := buildTestGif(t, parseTestFlags(t).opts, images...); len(dedup.Image) >= len(images) {
+		t.Errorf("got %d frames with dedup, want fewer than %d", len(dedup.Image), len(images))
+	}
+}
+
+func TestLosslessConflicts(t *testing.T) {
+	parseUsageError(t, "-lossless", "-global-palette")
+	parseUsageError(t, "-lossless", "-palette-mode", "global")
+	parseUsageError(t, "-lossless", "-palette-mode", "shared-sampled")
+	parseUsageError(t, "-lossless", "-palette", "gray4")
+	parseUsageError(t, "-lossless", "-colors", "16")
+	parseTestFlags(t, "-lossless", "-palette-mode", "per-frame")
+}
+
+func TestPhaseMessages(t *testing.T) {
+	phases := []phaseMsg{}
+	opts := Options{progress: func(p phaseMsg) { phases = append(phases, p) }}
+	buildTestGif(t, opts, testFrame(8, 8, testRed), testFrame(8, 8, testGreen), testFrame(8, 8, testBlue))
+
+	// phases go in order, and frames done grow in each of them
+	order := []string{phaseDecoding, phaseEncoding, phaseWriting}
+	at := 0
+	last := phaseMsg{}
+	for _, p := range phases {
+		if p.phase != last.phase {
+			for at < len(order) && order[at] != p.phase {
+				at++
+			}
+			if at == len(order) {
+				t.Fatalf("phase %s out of order in %v", p.phase, phases)
+			}
+		} else if p.done < last.done {
+			t.Errorf("%s: %d frames done after %d", p.phase, p.done, last.done)
+		}
+		last = p
+	}
+	if at != len(order)-1 {
+		t.Errorf("got phases %v, want all of %v", phases, order)
+	}
+	for _, p := range phases {
+		if p.phase == phaseDecoding && p.done == 3 && p.total == 3 {
+			return
+		}
+	}
+	t.Errorf("no message of all 3 frames decoded in %v", phases)
+}
+
+func TestPhaseMsgString(t *testing.T) {
+	for _, tt := range []struct {
+		msg  phaseMsg
+		want string
+	}{
+		{phaseMsg{phaseDecoding, 120, 500}, "decoding 120/500"},
+		{phaseMsg{phaseWriting, 0, 0}, "writing"},
+	} {
+		if got := tt.msg.String(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestEstimateRemaining(t *testing.T) {
+	for _, tt := range []struct {
+		elapsed     time.Duration
+		done, total int
+		want        time.Duration
+		ok          bool
+	}{
+		{10 * time.Second, 50, 100, 10 * time.Second, true},
+		{4 * time.Second, 20, 80, 12 * time.Second, true},
+		{time.Second, 3, 3, 0, true},
+		{time.Second, 0, 100, 0, false},
+		{time.Second, 5, 0, 0, false},
+		{time.Second, 6, 5, 0, false},
+	} {
+		got, ok := estimateRemaining(tt.elapsed, tt.done, tt.total)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%v for %d/%d: got %v %v, want %v %v", tt.elapsed, tt.done, tt.total, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	// the time left is estimated from the first message of the phase
+	m := initialModel(Options{}, parseTestFlags(t).theme)
+	m.loading = true
+	m.phases = make(chan phaseMsg)
+	next, _ := m.Update(phaseMsg{phaseDecoding, 10, 110})
+	m = next.(model)
+	if view := m.View(); strings.Contains(view, "remaining") {
+		t.Errorf("view shows the time left before any progress:\n%s", view)
+	}
+	m.etaStart = m.etaStart.Add(-10 * time.Second)
+	next, _ = m.Update(phaseMsg{phaseDecoding, 60, 110})
+	m = next.(model)
+	if view := m.View(); !strings.Contains(view, "~10s remaining") {
+		t.Errorf("view doesn't show 10s left:\n%s", view)
+	}
+	// a new phase starts the estimate over
+	next, _ = m.Update(phaseMsg{phaseEncoding, 1, 110})
+	if view := next.(model).View(); strings.Contains(view, "remaining") {
+		t.Errorf("view shows the time left of the previous phase:\n%s", view)
+	}
+}
+
+func TestModelShowsPhase(t *testing.T) {
+	m := initialModel(Options{}, parseTestFlags(t).theme)
+	m.loading = true
+	m.phases = make(chan phaseMsg)
+	for _, p := range []phaseMsg{{phaseDecoding, 1, 2}, {phaseEncoding, 2, 2}, {phaseWriting, 0, 0}} {
+		next, _ := m.Update(p)
+		m = next.(model)
+		if m.phase != p {
+			t.Errorf("got phase %v, want %v", m.phase, p)
+		}
+		if view := m.View(); !strings.Contains(view, p.String()) {
+			t.Errorf("view doesn't show %q:\n%s", p.String(), view)
+		}
+	}
+	// phases of canceled processing are ignored
+	m.loading = false
+	next, _ := m.Update(phaseMsg{phaseDecoding, 5, 5})
+	if next.(model).phase.phase != phaseWriting {
+		t.Error("phase of canceled processing is shown")
+	}
+}
+
+func TestFrameDelaysHolds(t *testing.T) {
+	reps := []int{1, 2, 1, 3}
+	for _, tt := range []struct {
+		name string
+		opts Options
+		reps []int
+		want []int
+	}{
+		{"none", Options{}, reps, []int{5, 10, 5, 15}},
+		{"first", Options{firstHold: 100}, reps, []int{100, 10, 5, 15}},
+		{"last", Options{lastHold: 200}, reps, []int{5, 10, 5, 200}},
+		{"both", Options{firstHold: 100, lastHold: 200}, reps, []int{100, 10, 5, 200}},
+		{"single frame", Options{firstHold: 100, lastHold: 200}, []int{4}, []int{200}},
+	} {
+		if got := frameDelays(tt.reps, 5, tt.opts); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got delays %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	cfg := parseTestFlags(t, "-first-hold", "150", "-last-hold", "250")
+	if cfg.opts.firstHold != 150 || cfg.opts.lastHold != 250 {
+		t.Errorf("got holds %d and %d, want 150 and 250", cfg.opts.firstHold, cfg.opts.lastHold)
+	}
+}
+
+func TestResolveOutput(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		output, want string
+	}{
+		{"", defaultOutput},
+		{filepath.Join(dir, "a.gif"), filepath.Join(dir, "a.gif")},
+		{dir, filepath.Join(dir, defaultOutput)},
+		{filepath.Join(dir, "new") + string(os.PathSeparator), filepath.Join(dir, "new", defaultOutput)},
+	} {
+		got, err := resolveOutput(tt.output)
+		if err != nil {
+			t.Errorf("%q: %v", tt.output, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.output, got, tt.want)
+		}
+	}
+
+	// the default file in the output directory is a directory too
+	if err := os.Mkdir(filepath.Join(dir, defaultOutput), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	_, err := resolveOutput(dir)
+	if err == nil || !strings.Contains(err.Error(), "output path is a directory") {
+		t.Errorf("got %v, want an error about the directory", err)
+	}
+}
+
+func TestOutInInput(t *testing.T) {
+	in := t.TempDir()
+	for _, tt := range []struct {
+		output, dir string
+		want        []string
+	}{
+		{"demo.gif", in, []string{filepath.Join(in, "demo.gif")}},
+		{"", in, []string{filepath.Join(in, defaultOutput)}},
+		{"a.gif,b.apng", in, []string{filepath.Join(in, "a.gif"), filepath.Join(in, "b.apng")}},
+		// paths with a folder, even the working one, are kept
+		{"./demo.gif", in, []string{"./demo.gif"}},
+		{filepath.Join("sub", "demo.gif"), in, []string{filepath.Join("sub", "demo.gif")}},
+		{"demo.gif", "", []string{"demo.gif"}},
+	} {
+		got, err := resolveOutputs(tt.output, tt.dir, "")
+		if err != nil {
+			t.Fatalf("%q in %q: %v", tt.output, tt.dir, err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%q in %q: got %v, want %v", tt.output, tt.dir, got, tt.want)
+		}
+	}
+
+	for path, want := range map[string]string{
+		in:                              in,
+		filepath.Join(in, "clip.mp4"):   in,
+		"https://example.com/frame.png": "",
+		stdinPath:                       "",
+	} {
+		if got := inputFolder(path); got != want {
+			t.Errorf("%s: got the folder %q, want %q", path, got, want)
+		}
+	}
+
+	// the output is written into the input folder and the view shows its resolved path
+	writeTestImages(t, in, testPattern(32, 32, 0), testPattern(32, 32, 60))
+	msg := gen(context.Background(), in, "demo.gif", parseTestFlags(t, "-out-in-input").opts, nil)().(resultMsg)
+	if msg.err != nil {
+		t.Fatal(msg.err)
+	}
+	want := filepath.Join(in, "demo.gif")
+	if !slices.Equal(msg.outputs, []string{want}) {
+		t.Errorf("got outputs %v, want %s", msg.outputs, want)
+	}
+	decodeTestGif(t, want)
+	m := initialModel(Options{}, parseTestFlags(t).theme)
+	m.loading = true
+	m.cancel = func() {}
+	next, _ := m.Update(msg)
+	if view := next.(model).View(); !strings.Contains(strings.Join(strings.Fields(view), ""), strings.Join(strings.Fields(want), "")) {
+		t.Errorf("view doesn't show %s:\n%s", want, view)
+	}
+
+	// batch gifs are written into their subfolders
+	parent := t.TempDir()
+	walk := filepath.Join(parent, "walk")
+	if err := os.Mkdir(walk, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestImages(t, walk, testPattern(32, 32, 0), testPattern(32, 32, 60))
+	if err := runBatch(parseTestFlags(t, "-path", parent, "-batch", "-out-in-input")); err != nil {
+		t.Fatal(err)
+	}
+	decodeTestGif(t, filepath.Join(walk, "walk.gif"))
+}
+
+// concurrency counts calls in progress and keeps the max of them.
+type concurrency struct {
+	mu        sync.Mutex
+	cur, peak int
+}
+
+func (c *concurrency) enter() {
+	c.mu.Lock()
+	c.cur++
+	c.peak = max(c.peak, c.cur)
+	c.mu.Unlock()
+	time.Sleep(100 * time.Microsecond)
+}
+
+func (c *concurrency) leave() {
+	c.mu.Lock()
+	c.cur--
+	c.mu.Unlock()
+}
+
+// countingFS counts files open at once.
+type countingFS struct {
+	fs.FS
+	c *concurrency
+}
+
+func (f countingFS) Open(name string) (fs.File, error) {
+	file, err := f.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	f.c.enter()
+	return countingFile{file, f.c}, nil
+}
+
+type countingFile struct {
+	fs.File
+	c *concurrency
+}
+
+func (f countingFile) Close() error {
+	f.c.leave()
+	return f.File.Close()
+}
+
+// countingImage counts frames read at once by their pixels, a frame is read by one go routine.
+type countingImage struct {
+	image.Image
+	c *concurrency
+}
+
+func (i countingImage) At(x, y int) color.Color {
+	i.c.enter()
+	defer i.c.leave()
+	return i.Image.At(x, y)
+}
+
+func TestThreadLimits(t *testing.T) {
+	dir := t.TempDir()
+	images := []image.Image{}
+	for n := 0; n < 12; n++ {
+		images = append(images, testFrame(4, 4, color.RGBA{uint8(n * 20), 0, 0, 255}))
+	}
+	writeTestImages(t, dir, images...)
+
+	for _, threads := range []int{1, 3} {
+		decode := &concurrency{}
+		files, err := listFiles(".", Options{fsys: os.DirFS(dir)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := Options{threadsIO: threads, threadsEncode: threads, fsys: countingFS{os.DirFS(dir), decode}}
+		frames, err := readImages(context.Background(), files, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(frames) != len(images) {
+			t.Fatalf("got %d frames, want %d", len(frames), len(images))
+		}
+		if decode.peak > threads || threads > 1 && decode.peak < 2 {
+			t.Errorf("-threads-io %d: %d images decoded at once", threads, decode.peak)
+		}
+
+		encode := &concurrency{}
+		for n := range frames {
+			frames[n].img = countingImage{frames[n].img, encode}
+		}
+		if _, err := encodeImgPaletted(context.Background(), &frames, opts); err != nil {
+			t.Fatal(err)
+		}
+		if encode.peak > threads || threads > 1 && encode.peak < 2 {
+			t.Errorf("-threads-encode %d: %d frames encoded at once", threads, encode.peak)
+		}
+	}
+}
+
+func TestDedupPresets(t *testing.T) {
+	for preset, want := range map[string]SimilarityOptions{
+		dedupStrict: {Prop: 0.0005, Y: 50, CbCr: 100},
+		dedupNormal: {Prop: 0.001, Y: 100, CbCr: 200},
+		dedupLoose:  {Prop: 0.002, Y: 200, CbCr: 400},
+		"":          {Prop: 0.001, Y: 100, CbCr: 200},
+	} {
+		if got := (Options{dedup: preset}).similarity(); got != want {
+			t.Errorf("%q: got %+v, want %+v", preset, got, want)
+		}
+	}
+	// explicit thresholds override the preset
+	got := Options{dedup: dedupLoose, thresholds: SimilarityOptions{Y: 7}}.similarity()
+	if want := (SimilarityOptions{Prop: 0.002, Y: 7, CbCr: 400}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	parseUsageError(t, "-dedup", "medium")
+
+	// red of a frame shifts a bit, with the Cb distance of about 140, or a bit more, with the distance of about 320
+	base, near, far := testPattern(32, 32, 0), testPattern(32, 32, 15), testPattern(32, 32, 17)
+	for _, tt := range []struct {
+		preset    string
+		near, far bool
+		delays    []int
+	}{
+		{dedupStrict, false, false, []int{1, 1}},
+		{dedupNormal, true, false, []int{1, 1}},
+		{dedupLoose, true, true, []int{2}},
+	} {
+		th := Options{dedup: tt.preset}.similarity()
+		if got := FramesSimilar(base, near, th); got != tt.near {
+			t.Errorf("%s: a slightly changed frame is similar %v, want %v", tt.preset, got, tt.near)
+		}
+		if got := FramesSimilar(base, far, th); got != tt.far {
+			t.Errorf("%s: a changed frame is similar %v, want %v", tt.preset, got, tt.far)
+		}
+		if got := frameDelaysOf(readTestImages(t, Options{dedup: tt.preset}, base, far)); !slices.Equal(got, tt.delays) {
+			t.Errorf("%s: got delays %v, want %v", tt.preset, got, tt.delays)
+		}
+	}
+}
+
+func TestSample(t *testing.T) {
+	colors := []color.Color{testRed, testGreen, testBlue, testRed, testGreen, testBlue, testRed}
+	images := []image.Image{}
+	for _, c := range colors {
+		images = append(images, testFrame(8, 8, c))
+	}
+	for _, tt := range []struct {
+		sample int
+		delays []int
+		colors []color.Color
+	}{
+		{1, []int{1, 1, 1, 1, 1, 1, 1}, colors},
+		// the last kept image stands for the only one left after it
+		{3, []int{3, 3, 1}, []color.Color{testRed, testRed, testRed}},
+		{2, []int{2, 2, 2, 1}, []color.Color{testRed, testBlue, testGreen, testRed}},
+	} {
+		frames := readTestImages(t, Options{sample: tt.sample, noDedup: true}, images...)
+		if got := frameDelaysOf(frames); !slices.Equal(got, tt.delays) {
+			t.Errorf("-sample %d: got delays %v, want %v", tt.sample, got, tt.delays)
+			continue
+		}
+		for n, f := range frames {
+			if !colorsEqual(f.img.At(0, 0), tt.colors[n]) {
+				t.Errorf("-sample %d: frame %d is %v, want %v", tt.sample, n, f.img.At(0, 0), tt.colors[n])
+			}
+		}
+	}
+
+	// total timing is kept
+	reps := frameDelaysOf(readTestImages(t, Options{sample: 3, noDedup: true}, images...))
+	total := 0
+	for _, d := range frameDelays(reps, 4, Options{}) {
+		total += d
+	}
+	if total != 4*len(images) {
+		t.Errorf("got the total delay %d, want %d", total, 4*len(images))
+	}
+}
+
+func TestSampleFiles(t *testing.T) {
+	files := []string{"a", "b", "c", "d", "e"}
+	for _, tt := range []struct {
+		n       int
+		sampled []string
+		weights []int
+	}{
+		{0, files, []int{1, 1, 1, 1, 1}},
+		{2, []string{"a", "c", "e"}, []int{2, 2, 1}},
+		{5, []string{"a"}, []int{5}},
+		{10, []string{"a"}, []int{5}},
+	} {
+		sampled, weights := sampleFiles(files, tt.n)
+		if !slices.Equal(sampled, tt.sampled) || !slices.Equal(weights, tt.weights) {
+			t.Errorf("%d: got %v %v, want %v %v", tt.n, sampled, weights, tt.sampled, tt.weights)
+		}
+	}
+}
+
+func TestMultipleOutputs(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPngs(t, dir, 8, 8, testRed, testGreen, testBlue)
+	files, err := listFiles(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gifOut, apngOut := filepath.Join(dir, "out.gif"), filepath.Join(dir, "out.apng")
+	if err := BuildGif(context.Background(), files, gifOut+", "+apngOut, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if g := decodeTestGif(t, gifOut); len(g.Image) != 3 {
+		t.Errorf("gif: got %d frames, want 3", len(g.Image))
+	}
+	data, err := os.ReadFile(apngOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if frames, _, _ := apngInfo(t, data); frames != 3 {
+		t.Errorf("apng: got %d frames, want 3", frames)
+	}
+}
+
+func TestOutputFormat(t *testing.T) {
+	for _, tt := range []struct {
+		path, format, want string
+	}{
+		{"a.gif", "", formatGif},
+		{"a.PNG", "", formatApng},
+		{"a.apng", "", formatApng},
+		{"a.webm", "", formatWebm},
+		{"a.png", formatGif, formatGif},
+		// -format wins over the extension and needs none
+		{"a.gif", formatApng, formatApng},
+		{"a.webm", formatGif, formatGif},
+		{"a", formatGif, formatGif},
+	} {
+		got, err := outputFormat(tt.path, tt.format)
+		if err != nil || got != tt.want {
+			t.Errorf("%s %q: got %q %v, want %q", tt.path, tt.format, got, err, tt.want)
+		}
+	}
+	for _, path := range []string{"a.jpg", "a"} {
+		if _, err := outputFormat(path, ""); err == nil {
+			t.Errorf("%s: no error", path)
+		}
+	}
+	if got := splitOutputs(" a.gif, ,b.png,"); !slices.Equal(got, []string{"a.gif", "b.png"}) {
+		t.Errorf("got outputs %v", got)
+	}
+}
+
+func TestErrorViewShowsFile(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPngs(t, dir, 8, 8, testRed, testBlue)
+	broken := filepath.Join(dir, "0003.png")
+	if err := os.WriteFile(broken, []byte("not a png"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	msg := gen(context.Background(), dir, filepath.Join(dir, "out.gif"), Options{}, nil)().(resultMsg)
+	var fe *fileError
+	if !errors.As(msg.err, &fe) || fe.path != broken || fe.op != "decode image" {
+		t.Fatalf("got %v, want a decode error of %s", msg.err, broken)
+	}
+
+	m := initialModel(Options{}, parseTestFlags(t).theme)
+	m.inputs[path].Width = 200
+	m.loading = true
+	m.cancel = func() {}
+	next, _ := m.Update(msg)
+	view := next.(model).View()
+	lines := strings.Split(view, "\n")
+	found := false
+	for _, l := range lines {
+		if strings.TrimSpace(l) == broken {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("no line with the file only in the view:\n%s", view)
+	}
+	if !strings.Contains(view, msg.emoji+" error: failed to decode image") {
+		t.Errorf("no emoji and stage in the view:\n%s", view)
+	}
+}
+
+func TestScanFolder(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPngs(t, dir, 40, 30, testRed, testGreen, testBlue)
+	if got, want := scanFolder(dir, Options{}).String(), "3 images found, 40×30"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := scanFolder(t.TempDir(), Options{}).String(), "no images found"; got != want {
+		t.Errorf("empty folder: got %q, want %q", got, want)
+	}
+	if got := scanFolder(filepath.Join(dir, "missing"), Options{}); got.err == nil || !strings.HasPrefix(got.String(), "error: ") {
+		t.Errorf("missing folder: got %q, want an error", got)
+	}
+	if got := scanFolder("", Options{}); got != (scanMsg{}) {
+		t.Errorf("empty path: got %+v, want no scan", got)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "0000.png"), []byte("not a png"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var fe *fileError
+	if got := scanFolder(dir, Options{}); !errors.As(got.err, &fe) || fe.op != "decode image" {
+		t.Errorf("broken first image: got %v, want a decode error", got.err)
+	}
+}
+
+func TestCancelProcessing(t *testing.T) {
+	m := initialModel(Options{}, parseTestFlags(t).theme)
+	m.loading = true
+	m.phases = make(chan phaseMsg)
+	canceled := false
+	m.cancel = func() { canceled = true }
+
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = next.(model)
+	if !canceled {
+		t.Error("esc doesn't cancel the processing")
+	}
+	if m.loading || m.phases != nil {
+		t.Error("model is still loading after esc")
+	}
+	if cmd != nil {
+		t.Error("esc while loading quits the app")
+	}
+	if view := m.View(); !strings.Contains(view, "processing canceled") {
+		t.Errorf("view doesn't show the notice:\n%s", view)
+	}
+
+	// the result of canceled processing is ignored
+	next, _ = m.Update(resultMsg{err: context.Canceled, emoji: "🛑"})
+	if next.(model).err != nil {
+		t.Error("result of canceled processing is shown")
+	}
+
+	// esc in the form still quits
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEsc}); cmd == nil {
+		t.Error("esc in the form doesn't quit")
+	}
+}
+
+func TestGenCanceled(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPngs(t, dir, 8, 8, color.Black, color.White)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	msg := gen(ctx, dir, filepath.Join(t.TempDir(), "out.gif"), Options{}, nil)().(resultMsg)
+	if !errors.Is(msg.err, context.Canceled) || msg.emoji != "🛑" {
+		t.Errorf("got %v %q, want a canceled result", msg.err, msg.emoji)
+	}
+}
+
+func TestFramesSimilarThresholds(t *testing.T) {
+	// frames of the same size differ in colors, frames of different sizes in proportions
+	a, b := testPattern(32, 32, 0), testPattern(32, 32, 15)
+	iconA, iconB := images4.Icon(a), images4.Icon(b)
+	y, cb, cr := images4.EucMetric(iconA, iconB)
+	wide := testPattern(64, 32, 0)
+	prop := images4.PropMetric(iconA, images4.Icon(wide))
+	if prop == 0 || y == 0 || max(cb, cr) == 0 {
+		t.Fatalf("fixture frames are too close: %v %v %v", prop, y, max(cb, cr))
+	}
+	loose := SimilarityOptions{Prop: 1e9, Y: 1e9, CbCr: 1e9}
+
+	for _, tt := range []struct {
+		name   string
+		b      image.Image
+		metric float64
+		set    func(th *SimilarityOptions, v float64)
+	}{
+		{"prop", wide, prop, func(th *SimilarityOptions, v float64) { th.Prop = v }},
+		{"y", b, y, func(th *SimilarityOptions, v float64) { th.Y = v }},
+		{"cbcr", b, max(cb, cr), func(th *SimilarityOptions, v float64) { th.CbCr = v }},
+	} {
+		name, metric, set, b := tt.name, tt.metric, tt.set, tt.b
+		if !FramesSimilar(a, b, loose) {
+			t.Fatalf("%s: frames differ with loose thresholds", name)
+		}
+		at, below := loose, loose
+		set(&at, metric)
+		set(&below, metric*0.99)
+		if !FramesSimilar(a, b, at) {
+			t.Errorf("%s: frames differ at the threshold %v", name, metric)
+		}
+		if FramesSimilar(a, b, below) {
+			t.Errorf("%s: frames are similar below the threshold %v", name, metric)
+		}
+	}
+}
+
+func TestGenWarnings(t *testing.T) {
+	dir := t.TempDir()
+	writeTestImages(t, dir, testPattern(32, 32, 0), testPattern(32, 32, 60), testPattern(32, 32, 120))
+	for _, tt := range []struct {
+		opts Options
+		want []string
+	}{
+		{Options{}, nil},
+		{Options{fps: 25}, nil},
+		{Options{fps: 30}, []string{"30 fps can't be represented exactly, frames play at 33.33 fps"}},
+		{Options{fps: 100}, []string{"browsers slow down frames shorter than 2/100 of a second, use 50 fps or less"}},
+		{Options{delayMs: 45}, []string{"45ms can't be represented exactly in 100ths of a second, delays of frames alternate to keep the total time"}},
+	} {
+		msg := gen(context.Background(), dir, filepath.Join(t.TempDir(), "out.gif"), tt.opts, nil)().(resultMsg)
+		if msg.err != nil {
+			t.Fatal(msg.err)
+		}
+		if !slices.Equal(msg.warnings, tt.want) {
+			t.Errorf("fps %d, delay %dms: got warnings %q, want %q", tt.opts.fps, tt.opts.delayMs, msg.warnings, tt.want)
+		}
+	}
+}
+
+func TestModelShowsWarnings(t *testing.T) {
+	m := initialModel(Options{}, parseTestFlags(t).theme)
+	m.loading = true
+	m.cancel = func() {}
+	next, _ := m.Update(resultMsg{outputs: []string{"out.gif"}, warnings: []string{"30 fps can't be represented exactly"}})
+	m = next.(model)
+	if view := m.View(); !strings.Contains(view, "30 fps can't be") {
+		t.Errorf("view doesn't show the warning:\n%s", view)
+	}
+}
+
+// slowFS delays opening each file, like a slow network drive.
+type slowFS struct {
+	fs.FS
+	delay time.Duration
+}
+
+func (f slowFS) Open(name string) (fs.File, error) {
+	time.Sleep(f.delay)
+	return f.FS.Open(name)
+}
+
+func TestTimeout(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPngs(t, dir, 8, 8, testRed, testGreen, testBlue, testRed, testGreen, testBlue)
+	files, err := listFiles(".", Options{fsys: os.DirFS(dir)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(t.TempDir(), "out.gif")
+	opts := Options{threadsIO: 1, timeout: 50 * time.Millisecond, fsys: slowFS{os.DirFS(dir), 30 * time.Millisecond}}
+	start := time.Now()
+	err = BuildGif(context.Background(), files, out, opts)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "build timed out after 50ms") {
+		t.Fatalf("got %v, want a timeout error", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("build took %s after the timeout", d)
+	}
+	if _, err := os.Stat(out); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("output of the timed out build exists")
+	}
+
+	// the build fits into a longer timeout
+	opts.timeout = time.Minute
+	if err := BuildGif(context.Background(), files, out, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	parseUsageError(t, "-timeout", "-1s")
+}
+
+func TestWriteOutputRemovesPartial(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	for _, name := range []string{"out.gif", "out.apng"} {
+		path := filepath.Join(t.TempDir(), name)
+		err := writeOutput(ctx, testFrames(8, 8, testRed, testBlue), 4, path, Options{})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("%s: got %v, want the deadline error", name, err)
+		}
+		if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s: partial output exists", name)
+		}
+	}
+}
+
+func TestTinyImagesDedup(t *testing.T) {
+	// 2×2 checkers of the same colors only differ in where the colors are
+	checker := func(a, b color.Color) image.Image {
+		img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+		img.Set(0, 0, a)
+		img.Set(1, 1, a)
+		img.Set(1, 0, b)
+		img.Set(0, 1, b)
+		return img
+	}
+	images := []image.Image{
+		checker(color.Black, color.White),
+		checker(color.White, color.Black),
+		checker(color.Black, testRed),
+		checker(color.Black, testRed),
+		testFrame(1, 1, testBlue),
+		testFrame(1, 1, testGreen),
+		testFrame(40, 2, testBlue),
+	}
+	frames := readTestImages(t, Options{}, images...)
+	if got, want := frameDelaysOf(frames), []int{1, 1, 2, 1, 1, 1}; !slices.Equal(got, want) {
+		t.Errorf("got delays %v, want %v", got, want)
+	}
+
+	// the loose preset doesn't merge distinct tiny images either
+	if FramesSimilar(images[0], images[1], Options{dedup: dedupLoose}.similarity()) {
+		t.Error("distinct 2×2 images are similar")
+	}
+	if !isTiny(images[6]) || isTiny(testFrame(minIconSize, minIconSize, testRed)) {
+		t.Error("wrong tiny size")
+	}
+}
+
+func TestOnceQuits(t *testing.T) {
+	// the quit message of bubbletea is unexported, so it's compared with the one of tea.Quit
+	quits := func(cmd tea.Cmd) bool {
+		return cmd != nil && cmd() == tea.Quit()
+	}
+	for _, tt := range []struct {
+		once bool
+		res  resultMsg
+		quit bool
+	}{
+		{true, resultMsg{outputs: []string{"out.gif"}}, true},
+		{true, resultMsg{err: errors.New("no images"), emoji: "📂"}, false},
+		{false, resultMsg{outputs: []string{"out.gif"}}, false},
+	} {
+		m := initialModel(Options{}, parseTestFlags(t).theme)
+		m.once = tt.once
+		m.loading = true
+		m.cancel = func() {}
+		next, cmd := m.Update(tt.res)
+		if got := quits(cmd); got != tt.quit {
+			t.Errorf("once %v, error %v: got quit %v, want %v", tt.once, tt.res.err, got, tt.quit)
+		}
+		if m := next.(model); tt.res.err == nil && (!m.finished || !slices.Equal(m.outPaths, tt.res.outputs)) {
+			t.Errorf("once %v: the finished build isn't kept for printing its outputs", tt.once)
+		}
+	}
+	if cfg := parseTestFlags(t, "-once"); !cfg.once {
+		t.Error("-once isn't set")
+	}
+}
+
+func TestKeepEndpoints(t *testing.T) {
+	images := []image.Image{}
+	for _, c := range []color.Color{testRed, testRed, testRed, testBlue, testBlue, testBlue} {
+		images = append(images, testFrame(16, 16, c))
+	}
+	for _, tt := range []struct {
+		opts   Options
+		delays []int
+	}{
+		{Options{}, []int{3, 3}},
+		{Options{keepEndpoints: true}, []int{1, 2, 2, 1}},
+		{Options{keepEndpoints: true, sample: 2}, []int{2, 2, 2}},
+	} {
+		frames := readTestImages(t, tt.opts, images...)
+		if got := frameDelaysOf(frames); !slices.Equal(got, tt.delays) {
+			t.Errorf("keep endpoints %v, sample %d: got delays %v, want %v", tt.opts.keepEndpoints, tt.opts.sample, got, tt.delays)
+		}
+	}
+	// the endpoints survive in the gif
+	g := buildTestGif(t, parseTestFlags(t, "-keep-endpoints").opts, images...)
+	if len(g.Image) != 4 {
+		t.Errorf("got %d frames, want 4", len(g.Image))
+	}
+}
+
+func TestUniformDelay(t *testing.T) {
+	images := []image.Image{}
+	for _, c := range []color.Color{testRed, testRed, testRed, testBlue, testGreen, testGreen} {
+		images = append(images, testFrame(16, 16, c))
+	}
+	g := buildTestGif(t, Options{fps: 20}, images...)
+	if !slices.Equal(g.Delay, []int{15, 5, 10}) {
+		t.Errorf("got delays %v, want [15 5 10]", g.Delay)
+	}
+	g = buildTestGif(t, parseTestFlags(t, "-uniform-delay", "-fps", "20").opts, images...)
+	if !slices.Equal(g.Delay, []int{5, 5, 5}) {
+		t.Errorf("-uniform-delay: got delays %v, want [5 5 5]", g.Delay)
+	}
+	// holds are kept
+	if got := frameDelays([]int{3, 1, 2}, 4, Options{uniformDelay: true, lastHold: 50}); !slices.Equal(got, []int{4, 4, 50}) {
+		t.Errorf("-uniform-delay -last-hold 50: got delays %v, want [4 4 50]", got)
+	}
+	if got := frameDelays([]int{3, 1, 2}, 4, Options{uniformDelay: true, delayMs: 45}); !slices.Equal(got, []int{5, 4, 5}) {
+		t.Errorf("-uniform-delay -delay-ms 45: got delays %v, want [5 4 5]", got)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPngs(t, dir, 16, 16, testRed, testRed, testBlue)
+	out := filepath.Join(t.TempDir(), "out.gif")
+	if err := Convert(dir, out); err != nil {
+		t.Fatal(err)
+	}
+	g := decodeTestGif(t, out)
+	// equal images are merged at 30 fps
+	if !slices.Equal(g.Delay, []int{6, 3}) {
+		t.Errorf("got delays %v, want [6 3]", g.Delay)
+	}
+
+	// the format follows the extension
+	apng := filepath.Join(t.TempDir(), "out.apng")
+	if err := Convert(dir, apng); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(apng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames, _, _ := apngInfo(t, data); frames != 2 {
+		t.Errorf("got %d apng frames, want 2", frames)
+	}
+
+	empty := t.TempDir()
+	if err := Convert(empty, out); err == nil || err.Error() != "no images found in "+empty {
+		t.Errorf("got %v, want no images found", err)
+	}
+}
+
+func TestAsciiIndicators(t *testing.T) {
+	for emoji, want := range asciiIndicators {
+		if got := indicator(emoji, true); got != want {
+			t.Errorf("%s: got %q, want %q", emoji, got, want)
+		}
+		if got := indicator(emoji, false); got != emoji {
+			t.Errorf("%s: got %q without ascii", emoji, got)
+		}
+	}
+
+	m := initialModel(Options{}, parseTestFlags(t).theme)
+	m.ascii = true
+	m.loading = true
+	m.cancel = func() {}
+	next, _ := m.Update(resultMsg{emoji: "🔨", err: errors.New("broken frame")})
+	m = next.(model)
+	view := m.View()
+	if !strings.Contains(view, "[build]") || strings.Contains(view, "🔨") || strings.Contains(view, "👉") {
+		t.Errorf("view doesn't use ascii tokens:\n%s", view)
+	}
+	m = initialModel(Options{}, parseTestFlags(t).theme)
+	m.ascii = true
+	m.loading = true
+	m.cancel = func() {}
+	next, _ = m.Update(resultMsg{outputs: []string{"out.gif"}, warnings: []string{"30 fps can't be represented exactly"}})
+	if view := next.(model).View(); !strings.Contains(view, "[warn] 30 fps") || strings.Contains(view, emojiWarning) {
+		t.Errorf("view doesn't use ascii tokens for warnings:\n%s", view)
+	}
+
+	dir := t.TempDir()
+	writeTestImages(t, dir, testPattern(32, 32, 0), testPattern(32, 32, 60), testPattern(32, 32, 120))
+	stderr, code := runMain(t, "-path", dir, "-out", filepath.Join(t.TempDir(), "out.gif"), "-fps", "30", "-ascii")
+	if code != 0 || !strings.HasPrefix(stderr, "[warn] 30 fps") {
+		t.Errorf("got exit code %d and stderr %q, want an ascii warning", code, stderr)
+	}
+}
+
+func TestStepFps(t *testing.T) {
+	for _, tt := range []struct {
+		value string
+		up    bool
+		want  string
+	}{
+		{"25", true, "26"},
+		{"25", false, "24"},
+		{"99", true, "99"},
+		{"1", false, "1"},
+		{"2", false, "1"},
+		{"", true, strconv.Itoa(defaultFps)},
+		{"abc", false, strconv.Itoa(defaultFps)},
+	} {
+		if got := stepFps(tt.value, tt.up); got != tt.want {
+			t.Errorf("%q up %v: got %q, want %q", tt.value, tt.up, got, tt.want)
+		}
+	}
+
+	m := initialModel(Options{}, parseTestFlags(t).theme)
+	press := func(k tea.KeyType) {
+		next, _ := m.Update(tea.KeyMsg{Type: k})
+		m = next.(model)
+	}
+	value := m.inputs[fps].Value()
+	// arrows don't step the frame rate while another input is focused
+	press(tea.KeyUp)
+	if got := m.inputs[fps].Value(); got != value {
+		t.Errorf("got fps %q with the path focused, want %q", got, value)
+	}
+	for m.focused != fps {
+		press(tea.KeyTab)
+	}
+	m.inputs[fps].SetValue("98")
+	press(tea.KeyUp)
+	press(tea.KeyUp)
+	if got := m.inputs[fps].Value(); got != "99" {
+		t.Errorf("got fps %q after stepping up from 98 twice, want 99", got)
+	}
+	m.inputs[fps].SetValue("3")
+	for i := 0; i < 4; i++ {
+		press(tea.KeyDown)
+	}
+	if got := m.inputs[fps].Value(); got != "1" {
+		t.Errorf("got fps %q after stepping down from 3, want 1", got)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// stdinPath is the path to read the list of images from the standard input, like a manifest.
+const stdinPath = "-"
+
+// readManifest reads the ordered list of images from a manifest file.
+// Each line is a path to an image or a glob pattern like scene1/*.png, relative to the manifest folder,
+// or an http URL of an image. Globs are expanded in place and sorted, the order of lines is preserved.
+// Empty lines and lines starting with # are skipped.
+func readManifest(path string, opts Options) (*[]string, error) {
+	f, err := opts.open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseManifest(f, path, filepath.Dir(path), opts)
+}
+
+// parseManifest reads the list of images from the manifest named path, relative paths are relative to the base folder.
+func parseManifest(r io.Reader, path, base string, opts Options) (*[]string, error) {
+	var files []string
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if isURL(line) {
+			files = append(files, line)
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(base, line)
+		}
+
+		// add a plain path as is, it fails on decode if the file is missing
+		if !isGlob(line) {
+			files = append(files, line)
+			continue
+		}
+
+		// globs return matches in lexical order
+		matches, err := opts.glob(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern on line %d of manifest (%s): %w", n, path, err)
+		}
+		found := 0
+		for _, m := range matches {
+			if fi, err := opts.stat(m); err == nil && !fi.IsDir() && opts.isImage(m) {
+				files = append(files, m)
+				found++
+			}
+		}
+		if found == 0 {
+			return nil, fmt.Errorf("no images match pattern on line %d of manifest (%s): %s", n, path, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &files, nil
+}
+
+// isGlob checks if the path contains any glob pattern characters.
+func isGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
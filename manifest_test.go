@@ -0,0 +1,40 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReadManifest(t *testing.T) {
+	fsys := fstest.MapFS{
+		"list.txt":         {Data: []byte("scene2/*.png\n# the first scene\n\nscene1/*.png\nextra.png\nhttps://example.com/a.png\n")},
+		"scene1/b.png":     {},
+		"scene1/a.png":     {},
+		"scene1/notes.txt": {},
+		"scene2/c.png":     {},
+		"scene2/dir.png/x": {},
+	}
+	files, err := readManifest("list.txt", Options{fsys: fsys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"scene2/c.png", "scene1/a.png", "scene1/b.png", "extra.png", "https://example.com/a.png"}
+	if !slices.Equal(*files, want) {
+		t.Errorf("got %v, want %v", *files, want)
+	}
+}
+
+func TestReadManifestErrors(t *testing.T) {
+	fsys := fstest.MapFS{
+		"empty.txt": {Data: []byte("scene1/*.png\n")},
+		"bad.txt":   {Data: []byte("a.png\n[.png\n")},
+	}
+	for name, want := range map[string]string{"empty.txt": "no images match pattern on line 1", "bad.txt": "invalid pattern on line 2"} {
+		_, err := readManifest(name, Options{fsys: fsys})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got %v, want an error with %q", name, err, want)
+		}
+	}
+}
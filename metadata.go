@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// webmStripArgs are the ffmpeg arguments that leave metadata and the encoder tag out of videos.
+var webmStripArgs = []string{"-map_metadata", "-1", "-fflags", "+bitexact"}
+
+// errTruncated is returned when an encoded output ends in the middle of a block.
+var errTruncated = errors.New("unexpected end of data")
+
+// gifKeptApplications are ids of gif application extensions needed to play the animation, others are metadata.
+var gifKeptApplications = map[string]bool{"NETSCAPE2.0": true, "ANIMEXTS1.0": true}
+
+// stripGifMetadata returns the gif without comment, plain text and application extensions, except the looping one.
+func stripGifMetadata(data []byte) ([]byte, error) {
+	if len(data) < 13 {
+		return nil, errTruncated
+	}
+	out := bytes.Buffer{}
+	// the header, the logical screen descriptor and the global color table
+	head := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		head += 3 << (flags&7 + 1)
+	}
+	if len(data) < head {
+		return nil, errTruncated
+	}
+	out.Write(data[:head])
+
+	for i := head; i < len(data); {
+		start := i
+		switch data[i] {
+		case 0x3b:
+			// the trailer ends the gif
+			out.WriteByte(0x3b)
+			return out.Bytes(), nil
+		case 0x2c:
+			// the image descriptor, the local color table, the LZW code size and the data sub-blocks
+			i += 10
+			if i > len(data) {
+				return nil, errTruncated
+			}
+			if flags := data[i-1]; flags&0x80 != 0 {
+				i += 3 << (flags&7 + 1)
+			}
+			end, err := skipSubBlocks(data, i+1)
+			if err != nil {
+				return nil, err
+			}
+			out.Write(data[start:end])
+			i = end
+		case 0x21:
+			if i+2 > len(data) {
+				return nil, errTruncated
+			}
+			label := data[i+1]
+			end, err := skipSubBlocks(data, i+2)
+			if err != nil {
+				return nil, err
+			}
+			keep := label == 0xf9
+			if label == 0xff && i+3 <= len(data) {
+				n := int(data[i+2])
+				keep = i+3+n <= len(data) && gifKeptApplications[string(data[i+3:i+3+n])]
+			}
+			if keep {
+				out.Write(data[start:end])
+			}
+			i = end
+		default:
+			return nil, fmt.Errorf("unknown gif block 0x%02x at %d", data[i], i)
+		}
+	}
+	return nil, errTruncated
+}
+
+// skipSubBlocks returns the position after the data sub-blocks starting at i, including their terminator.
+func skipSubBlocks(data []byte, i int) (int, error) {
+	for {
+		if i >= len(data) {
+			return 0, errTruncated
+		}
+		n := int(data[i])
+		i += n + 1
+		if n == 0 {
+			return i, nil
+		}
+	}
+}
+
+// pngKeptChunks are the ancillary png chunks needed to show the image or the animation, others are metadata.
+var pngKeptChunks = map[string]bool{"tRNS": true, "acTL": true, "fcTL": true, "fdAT": true}
+
+// stripPngMetadata returns the png without ancillary chunks, like text, time or color profile ones,
+// except the ones needed to show the image and the animation. Critical chunks are always kept.
+func stripPngMetadata(data []byte) ([]byte, error) {
+	if len(data) < len(pngHeader) || string(data[:len(pngHeader)]) != pngHeader {
+		return nil, errors.New("not a png")
+	}
+	out := bytes.Buffer{}
+	out.WriteString(pngHeader)
+	for i := len(pngHeader); i < len(data); {
+		if i+8 > len(data) {
+			return nil, errTruncated
+		}
+		end := i + 12 + int(binary.BigEndian.Uint32(data[i:i+4]))
+		if end > len(data) || end < i {
+			return nil, errTruncated
+		}
+		typ := string(data[i+4 : i+8])
+		// the first letter of critical chunks is uppercase
+		if typ[0] >= 'A' && typ[0] <= 'Z' || pngKeptChunks[typ] {
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+	return out.Bytes(), nil
+}
+
+// strippingWriter collects the encoded output and writes it without metadata to w when it's closed.
+// @property {io.Writer} w - The writer of the output.
+// @property {func([]byte) ([]byte, error)} strip - Removes metadata of the format.
+// @property {bytes.Buffer} buf - The encoded output.
+type strippingWriter struct {
+	w     io.Writer
+	strip func([]byte) ([]byte, error)
+	buf   bytes.Buffer
+}
+
+func (s *strippingWriter) Write(p []byte) (int, error) {
+	return s.buf.Write(p)
+}
+
+// Close strips metadata from the output and writes it.
+func (s *strippingWriter) Close() error {
+	data, err := s.strip(s.buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("strip metadata: %w", err)
+	}
+	_, err = s.w.Write(data)
+	return err
+}
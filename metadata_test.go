@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image/gif"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// commentExtension is an encoded comment extension of a gif.
+var commentExtension = []byte{0x21, 0xfe, 5, 'h', 'e', 'l', 'l', 'o', 0}
+
+func TestStripMetadataGif(t *testing.T) {
+	b := bytes.Buffer{}
+	if err := encodeTo(context.Background(), &b, formatGif, testFrames(4, 4, testRed, testBlue), 2, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	// put a comment before the trailer, like other tools write
+	data := append(append([]byte{}, b.Bytes()[:b.Len()-1]...), commentExtension...)
+	data = append(data, 0x3b)
+
+	stripped, err := stripGifMetadata(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stripped, b.Bytes()) {
+		t.Error("the stripped gif differs from the one without the comment")
+	}
+	if !bytes.Contains(stripped, []byte("NETSCAPE2.0")) {
+		t.Error("looping extension is removed")
+	}
+	if _, err := stripGifMetadata(data[:len(data)-5]); err == nil {
+		t.Error("no error for a truncated gif")
+	}
+
+	s := bytes.Buffer{}
+	if err := encodeTo(context.Background(), &s, formatGif, testFrames(8, 8, testRed, testGreen, testBlue), 3, Options{stripMetadata: true}); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(s.Bytes(), []byte{0x21, 0xfe}) {
+		t.Error("comment extension is found")
+	}
+	g, err := gif.DecodeAll(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Image) != 3 || g.Delay[0] != 3 {
+		t.Errorf("got %d frames with delay %d, want 3 frames with delay 3", len(g.Image), g.Delay[0])
+	}
+}
+
+// pngChunkTypes returns types of chunks of the png in order.
+func pngChunkTypes(t *testing.T, data []byte) []string {
+	t.Helper()
+	types := []string{}
+	for i := len(pngHeader); i < len(data); {
+		n := int(binary.BigEndian.Uint32(data[i:]))
+		types = append(types, string(data[i+4:i+8]))
+		i += 12 + n
+	}
+	return types
+}
+
+func TestStripMetadataApng(t *testing.T) {
+	b := bytes.Buffer{}
+	if err := encodeTo(context.Background(), &b, formatApng, testFrames(4, 4, testRed, testBlue), 2, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	// put a text chunk and a time chunk after IHDR, like other tools write
+	ihdrEnd := len(pngHeader) + 12 + 13
+	data := append([]byte{}, b.Bytes()[:ihdrEnd]...)
+	data = append(data, pngChunk("tEXt", []byte("Comment\x00hello"))...)
+	data = append(data, pngChunk("tIME", make([]byte, 7))...)
+	data = append(data, b.Bytes()[ihdrEnd:]...)
+
+	stripped, err := stripPngMetadata(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stripped, b.Bytes()) {
+		t.Errorf("got chunks %v, want %v", pngChunkTypes(t, stripped), pngChunkTypes(t, b.Bytes()))
+	}
+
+	s := bytes.Buffer{}
+	if err := encodeTo(context.Background(), &s, formatApng, testFrames(4, 4, testRed, testBlue), 2, Options{stripMetadata: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, typ := range pngChunkTypes(t, s.Bytes()) {
+		switch typ {
+		case "IHDR", "acTL", "fcTL", "IDAT", "fdAT", "IEND":
+		default:
+			t.Errorf("unexpected chunk %s", typ)
+		}
+	}
+}
+
+func TestStripMetadataWebm(t *testing.T) {
+	args := filepath.Join(t.TempDir(), "args")
+	fakeCommand(t, "ffmpeg", fmt.Sprintf("echo \"$@\" > %s\ncat > /dev/null\n", args))
+	for _, strip := range []bool{false, true} {
+		if err := encodeTo(context.Background(), io.Discard, formatWebm, testFrames(4, 4, testRed, testBlue), 2, Options{stripMetadata: strip}); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(string(data), strings.Join(webmStripArgs, " ")); got != strip {
+			t.Errorf("strip %v: ffmpeg got arguments %s", strip, data)
+		}
+	}
+}
+
+func TestStripMetadataCommentFpsConflict(t *testing.T) {
+	parseUsageError(t, "-strip-metadata", "-comment-fps")
+}
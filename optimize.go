@@ -0,0 +1,152 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+)
+
+// optimizeStatic crops gif frames after the first one to the area that changed on the screen,
+// unchanged pixels inside the area are transparent when the palette has room for it.
+// Frames are drawn over the previous ones with no disposal, so the result looks the same as the full frames.
+// Frames with a size different from the first one are kept as they are.
+func optimizeStatic(frames []*image.Paletted) ([]*image.Paletted, []byte) {
+	disposal := make([]byte, len(frames))
+	for i := range disposal {
+		disposal[i] = gif.DisposalNone
+	}
+	if len(frames) < 2 {
+		return frames, disposal
+	}
+	screen := frames[0].Bounds()
+	for _, p := range frames {
+		if p.Bounds() != screen {
+			return frames, disposal
+		}
+	}
+
+	// canvas is what the screen shows after each frame, transparent where nothing is drawn yet.
+	canvas := image.NewRGBA(screen)
+	drawShown(canvas, frames[0], transparentIndex(frames[0].Palette), screen)
+
+	optimized := []*image.Paletted{frames[0]}
+	for _, p := range frames[1:] {
+		tr := transparentIndex(p.Palette)
+		changed := image.Rectangle{}
+		for y := screen.Min.Y; y < screen.Max.Y; y++ {
+			for x := screen.Min.X; x < screen.Max.X; x++ {
+				if c, ok := shownColor(p, tr, x, y); ok && c != canvas.RGBAAt(x, y) {
+					changed = changed.Union(image.Rect(x, y, x+1, y+1))
+				}
+			}
+		}
+		// players need at least one pixel in a frame, an unchanged one keeps the screen as is.
+		if changed.Empty() {
+			changed = image.Rect(screen.Min.X, screen.Min.Y, screen.Min.X+1, screen.Min.Y+1)
+		}
+
+		pal, transparent := transparentPalette(p.Palette)
+		dst := image.NewPaletted(changed, pal)
+		for y := changed.Min.Y; y < changed.Max.Y; y++ {
+			for x := changed.Min.X; x < changed.Max.X; x++ {
+				idx := p.ColorIndexAt(x, y)
+				if c, ok := shownColor(p, tr, x, y); transparent >= 0 && (!ok || c == canvas.RGBAAt(x, y)) {
+					idx = uint8(transparent)
+				}
+				dst.SetColorIndex(x, y, idx)
+			}
+		}
+		drawShown(canvas, p, tr, changed)
+		optimized = append(optimized, dst)
+	}
+	return optimized, disposal
+}
+
+// shownColor returns the color a gif player shows for the pixel of the frame, false if it's transparent.
+// The encoder writes the first fully transparent color of the palette, tr, as the transparent one and the rest as opaque.
+func shownColor(p *image.Paletted, tr, x, y int) (color.RGBA, bool) {
+	idx := int(p.ColorIndexAt(x, y))
+	if idx == tr {
+		return color.RGBA{}, false
+	}
+	r, g, b, _ := p.Palette[idx].RGBA()
+	return color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 0xff}, true
+}
+
+// drawShown draws the opaque pixels of the frame in the area onto the canvas.
+func drawShown(canvas *image.RGBA, p *image.Paletted, tr int, area image.Rectangle) {
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			if c, ok := shownColor(p, tr, x, y); ok {
+				canvas.SetRGBA(x, y, c)
+			}
+		}
+	}
+}
+
+// transparentIndex returns the index of the first fully transparent color of the palette, -1 if there is none.
+func transparentIndex(pal color.Palette) int {
+	for i, c := range pal {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// transparentPalette returns the palette with a transparent color and its index,
+// a copy with a transparent color added if there is room for it, or the same palette and -1 if it's full.
+func transparentPalette(pal color.Palette) (color.Palette, int) {
+	if i := transparentIndex(pal); i >= 0 {
+		return pal, i
+	}
+	if len(pal) >= 256 {
+		return pal, -1
+	}
+	return append(append(color.Palette{}, pal...), color.RGBA{}), len(pal)
+}
+
+// alignTransparentIndex moves the transparent color of every frame to the same index of its palette,
+// the one most frames already use if all palettes with a transparent color have it, 0 otherwise.
+// Frames with the color elsewhere get a copy of the palette with entries swapped and their pixels remapped.
+func alignTransparentIndex(frames []*image.Paletted) {
+	counts := map[int]int{}
+	minLen := 256
+	for _, p := range frames {
+		if tr := transparentIndex(p.Palette); tr >= 0 {
+			counts[tr]++
+			if len(p.Palette) < minLen {
+				minLen = len(p.Palette)
+			}
+		}
+	}
+	fixed := 0
+	for tr, n := range counts {
+		if tr < minLen && (n > counts[fixed] || n == counts[fixed] && tr < fixed) {
+			fixed = tr
+		}
+	}
+
+	for _, p := range frames {
+		// palettes without a transparent color may be shorter than the fixed index
+		tr := transparentIndex(p.Palette)
+		if tr < 0 || tr == fixed {
+			continue
+		}
+		// a second transparent color at the fixed index would turn opaque pixels transparent
+		if _, _, _, a := p.Palette[fixed].RGBA(); a == 0 {
+			continue
+		}
+		pal := append(color.Palette{}, p.Palette...)
+		pal[tr], pal[fixed] = pal[fixed], pal[tr]
+		p.Palette = pal
+		for i, idx := range p.Pix {
+			switch int(idx) {
+			case tr:
+				p.Pix[i] = uint8(fixed)
+			case fixed:
+				p.Pix[i] = uint8(tr)
+			}
+		}
+	}
+}
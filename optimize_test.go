@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"testing"
+)
+
+func TestOptimizeStatic(t *testing.T) {
+	// a square moves over a static background of exact palette colors, so dithering doesn't change it
+	frames := []imgWithDelay{}
+	for n := 0; n < 5; n++ {
+		img := testFrame(48, 32, testGreen)
+		draw.Draw(img, image.Rect(0, 0, 48, 6), image.NewUniform(testRed), image.Point{}, draw.Src)
+		draw.Draw(img, image.Rect(4+n*8, 10, 12+n*8, 18), image.NewUniform(testBlue), image.Point{}, draw.Src)
+		frames = append(frames, imgWithDelay{img: img, delay: 1})
+	}
+	encode := func(opts Options) (*gif.GIF, int) {
+		images := append([]imgWithDelay{}, frames...)
+		b := bytes.Buffer{}
+		if err := encodeTo(context.Background(), &b, formatGif, &images, 4, opts); err != nil {
+			t.Fatal(err)
+		}
+		size := b.Len()
+		g, err := gif.DecodeAll(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return g, size
+	}
+	naive, naiveSize := encode(Options{})
+	optimized, optimizedSize := encode(parseTestFlags(t, "-optimize-static").opts)
+
+	if optimizedSize >= naiveSize {
+		t.Errorf("got %d bytes, want less than %d of full frames", optimizedSize, naiveSize)
+	}
+	if optimized.Image[0].Bounds() != naive.Image[0].Bounds() {
+		t.Errorf("got the first frame %v, want the full background", optimized.Image[0].Bounds())
+	}
+	for n, p := range optimized.Image[1:] {
+		// the square moved by 8 pixels
+		if want := image.Rect(4+n*8, 10, 20+n*8, 18); p.Bounds() != want {
+			t.Errorf("frame %d: got bounds %v, want the changed area %v", n+1, p.Bounds(), want)
+		}
+	}
+	for n, d := range optimized.Disposal {
+		if d != gif.DisposalNone {
+			t.Errorf("frame %d: got disposal %d, want none", n, d)
+		}
+	}
+
+	// the screen shows the same pixels after each frame
+	want, got := composeGifFrames(naive), composeGifFrames(optimized)
+	for n := range want {
+		if !bytes.Equal(got[n].(*image.RGBA).Pix, want[n].(*image.RGBA).Pix) {
+			t.Errorf("frame %d: shown pixels differ from full frames", n)
+		}
+	}
+}
+
+func TestAlignTransparentIndex(t *testing.T) {
+	clear := color.RGBA{}
+	red, green, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 255, 0, 255}, color.RGBA{0, 0, 255, 255}
+	frame := func(pal color.Palette, pix ...uint8) *image.Paletted {
+		p := image.NewPaletted(image.Rect(0, 0, len(pix), 1), pal)
+		copy(p.Pix, pix)
+		return p
+	}
+	frames := []*image.Paletted{
+		frame(color.Palette{red, clear, green}, 0, 1, 2),
+		frame(color.Palette{blue, clear, red}, 1, 0, 2),
+		frame(color.Palette{clear, red, green}, 0, 1, 2),
+		frame(color.Palette{red, green}, 0, 1),
+		frame(color.Palette{blue}, 0),
+	}
+	want := []*image.RGBA{}
+	for _, p := range frames {
+		want = append(want, toRGBA(p))
+	}
+	shared := frames[1].Palette
+	alignTransparentIndex(frames)
+
+	// index 1 is used by most frames, frames without transparency are left as they are
+	for n, p := range frames[:3] {
+		if tr := transparentIndex(p.Palette); tr != 1 {
+			t.Errorf("frame %d: got the transparent index %d, want 1", n, tr)
+		}
+	}
+	for n, p := range frames[3:] {
+		if tr := transparentIndex(p.Palette); tr != -1 {
+			t.Errorf("frame %d: got the transparent index %d, want none", n+3, tr)
+		}
+	}
+	for n, p := range frames {
+		if !bytes.Equal(toRGBA(p).Pix, want[n].Pix) {
+			t.Errorf("frame %d: pixels changed", n)
+		}
+	}
+	if shared[1] != clear {
+		t.Error("the palette is changed in place")
+	}
+}
+
+func TestGifTransparentIndex(t *testing.T) {
+	// the first frames have a transparent corner in their palettes, the rest get a transparent color
+	// added to the end of their palettes after optimizing the static background
+	frames := []imgWithDelay{}
+	squares := []color.Color{testRed, testBlue, color.RGBA{255, 255, 0, 255}, color.RGBA{0, 0, 0, 255}}
+	for n := range squares {
+		img := testFrame(48, 32, testGreen)
+		for i, c := range squares[:n+1] {
+			draw.Draw(img, image.Rect(i*10+n, 8, i*10+n+8, 24), image.NewUniform(c), image.Point{}, draw.Src)
+		}
+		if n < 2 {
+			draw.Draw(img, image.Rect(0, 0, 4, 4), image.Transparent, image.Point{}, draw.Src)
+		}
+		frames = append(frames, imgWithDelay{img: img, delay: 1})
+	}
+	b := bytes.Buffer{}
+	if err := encodeTo(context.Background(), &b, formatGif, &frames, 4, Options{optimizeStatic: true, numColors: 16}); err != nil {
+		t.Fatal(err)
+	}
+	g, err := gif.DecodeAll(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixed := -1
+	for n, p := range g.Image[1:] {
+		tr := transparentIndex(p.Palette)
+		if tr < 0 {
+			continue
+		}
+		if fixed >= 0 && tr != fixed {
+			t.Errorf("frame %d: got the transparent index %d, want %d", n+1, tr, fixed)
+		}
+		fixed = tr
+	}
+	if fixed < 0 {
+		t.Fatal("no frame has a transparent color")
+	}
+}
+
+// toRGBA draws the image on an RGBA image.
+func toRGBA(img image.Image) *image.RGBA {
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return rgba
+}
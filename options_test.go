@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/draw"
+	"runtime"
+	"testing"
+)
+
+func TestOptionsDefaults(t *testing.T) {
+	o := Options{}
+	if o.colors() != 256 {
+		t.Errorf("got %d colors, want 256", o.colors())
+	}
+	if got, want := o.similarity(), dedupPresets[dedupNormal]; got != want {
+		t.Errorf("got thresholds %v, want the normal preset %v", got, want)
+	}
+	if o.drawer() != draw.FloydSteinberg {
+		t.Errorf("got the drawer %v, want Floyd-Steinberg", o.drawer())
+	}
+	if o.ioThreads() < 1 || o.encodeThreads() < 1 {
+		t.Errorf("got %d io and %d encode threads", o.ioThreads(), o.encodeThreads())
+	}
+	// nil callbacks are fine
+	o.warnf("ignored")
+	o.report(phaseDecoding, 1, 1)
+
+	// a gif of the zero options loops forever at the default frame rate
+	g := buildTestGif(t, o, testPattern(32, 32, 0), testPattern(32, 32, 60))
+	if g.LoopCount != 0 || len(g.Image) != 2 {
+		t.Errorf("got loop count %d and %d frames", g.LoopCount, len(g.Image))
+	}
+	for _, d := range g.Delay {
+		if d != 100/defaultFps {
+			t.Errorf("got delays %v, want %d", g.Delay, 100/defaultFps)
+			break
+		}
+	}
+	for _, img := range g.Image {
+		if img.Rect != image.Rect(0, 0, 32, 32) {
+			t.Errorf("got frame bounds %v, want the full image", img.Rect)
+		}
+	}
+}
+
+func TestOptionsMatchFlagDefaults(t *testing.T) {
+	// the zero options build the same gif as the defaults of flags
+	frames := framesOf(testPattern(32, 32, 0), testGradient(32, 32, testBlue))
+	frames[1].delay = 2
+	encode := func(opts Options) []byte {
+		images := append([]imgWithDelay{}, frames...)
+		b := bytes.Buffer{}
+		if err := encodeTo(context.Background(), &b, formatGif, &images, 4, opts); err != nil {
+			t.Fatal(err)
+		}
+		return b.Bytes()
+	}
+	if !bytes.Equal(encode(Options{}), encode(parseTestFlags(t).opts)) {
+		t.Error("gifs of the zero options and of the flag defaults differ")
+	}
+}
+
+func TestDefaultJobs(t *testing.T) {
+	for _, tt := range []struct{ cpus, maxJobs, want int }{
+		{1, 0, 1},
+		{4, 0, 4},
+		{64, 0, defaultMaxJobs},
+		{64, 16, 16},
+		{2, 16, 2},
+		{0, 8, 1},
+	} {
+		if got := defaultJobs(tt.cpus, tt.maxJobs); got != tt.want {
+			t.Errorf("%d cpus up to %d: got %d, want %d", tt.cpus, tt.maxJobs, got, tt.want)
+		}
+	}
+
+	// jobs override the default, threads override jobs
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(12))
+	for _, tt := range []struct {
+		args            []string
+		io, encode, cpu int
+	}{
+		{nil, 16, 8, 8},
+		{[]string{"-max-jobs", "10"}, 20, 10, 10},
+		{[]string{"-max-jobs", "32"}, 24, 12, 12},
+		{[]string{"-jobs", "3"}, 6, 3, 3},
+		{[]string{"-jobs", "3", "-threads-io", "5", "-threads-encode", "1"}, 5, 1, 3},
+	} {
+		opts := parseTestFlags(t, tt.args...).opts
+		if opts.cpus() != tt.cpu || opts.ioThreads() != tt.io || opts.encodeThreads() != tt.encode {
+			t.Errorf("%v: got %d cpus, %d io and %d encode threads, want %d, %d and %d", tt.args, opts.cpus(), opts.ioThreads(), opts.encodeThreads(), tt.cpu, tt.io, tt.encode)
+		}
+	}
+	if got := (Options{}).cpus(); got != defaultMaxJobs {
+		t.Errorf("got %d cpus with zero options, want %d", got, defaultMaxJobs)
+	}
+	parseUsageError(t, "-jobs", "-1")
+	parseUsageError(t, "-max-jobs", "0")
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// grayRamp is the prefix of named palettes of evenly spaced grays, e.g. gray4.
+const grayRamp = "gray"
+
+// parsePalette parses comma separated hex colors, e.g. "#1d3557,#f1faee",
+// or a named ramp of N evenly spaced grays from black to white, e.g. "gray4".
+func parsePalette(s string) (color.Palette, error) {
+	if strings.HasPrefix(s, grayRamp) {
+		n, err := strconv.Atoi(strings.TrimPrefix(s, grayRamp))
+		if err != nil || n < 2 || n > 256 {
+			return nil, fmt.Errorf("invalid gray ramp %s, use gray2 to gray256", s)
+		}
+		p := make(color.Palette, n)
+		for i := range p {
+			p[i] = color.Gray{uint8(i * 255 / (n - 1))}
+		}
+		return p, nil
+	}
+
+	p := color.Palette{}
+	for _, h := range strings.Split(s, ",") {
+		c, err := parseHexColor(strings.TrimSpace(h))
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, c)
+	}
+	if len(p) > 256 {
+		return nil, fmt.Errorf("palette should have at most 256 colors, got %d", len(p))
+	}
+	return p, nil
+}
+
+// parseHexColor parses a color in #RRGGBB or #RRGGBBAA format, # is optional.
+func parseHexColor(s string) (color.NRGBA, error) {
+	h := strings.TrimPrefix(s, "#")
+	if len(h) != 6 && len(h) != 8 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q, use #RRGGBB or #RRGGBBAA", s)
+	}
+	v, err := strconv.ParseUint(h, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q, use #RRGGBB or #RRGGBBAA", s)
+	}
+	if len(h) == 6 {
+		v = v<<8 | 0xff
+	}
+	return color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+}
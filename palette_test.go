@@ -0,0 +1,51 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParsePalette(t *testing.T) {
+	p, err := parsePalette("#1d3557, f1faee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (color.Palette{color.NRGBA{0x1d, 0x35, 0x57, 255}, color.NRGBA{0xf1, 0xfa, 0xee, 255}}); len(p) != 2 || p[0] != want[0] || p[1] != want[1] {
+		t.Errorf("got %v, want %v", p, want)
+	}
+	p, err = parsePalette("gray3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (color.Palette{color.Gray{0}, color.Gray{127}, color.Gray{255}}); len(p) != 3 || p[0] != want[0] || p[1] != want[1] || p[2] != want[2] {
+		t.Errorf("got %v, want %v", p, want)
+	}
+	for _, s := range []string{"gray1", "gray257", "grayish", "#12345", "#zzzzzz"} {
+		if _, err := parsePalette(s); err == nil {
+			t.Errorf("%s: no error", s)
+		}
+	}
+	parseUsageError(t, "-palette", "#12")
+}
+
+func TestPaletteOutput(t *testing.T) {
+	cfg := parseTestFlags(t, "-palette", "#1d3557,#f1faee")
+	g := buildTestGif(t, cfg.opts, testPattern(32, 32, 0), testGradient(32, 32, testGreen))
+	allowed := map[color.NRGBA]bool{{0x1d, 0x35, 0x57, 255}: true, {0xf1, 0xfa, 0xee, 255}: true}
+	used := map[color.NRGBA]bool{}
+	for n, frame := range g.Image {
+		for y := 0; y < 32; y++ {
+			for x := 0; x < 32; x++ {
+				c := color.NRGBAModel.Convert(frame.At(x, y)).(color.NRGBA)
+				if !allowed[c] {
+					t.Fatalf("frame %d: color %v at %d,%d isn't in the palette", n, c, x, y)
+				}
+				used[c] = true
+			}
+		}
+	}
+	// dithering mixes both colors
+	if len(used) != 2 {
+		t.Errorf("got colors %v, want both of the palette", used)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// isPdf checks if the path points to a pdf document by its extension.
+func isPdf(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".pdf"
+}
+
+// findPdftoppm returns the path to the pdftoppm binary or a clear error if it's not installed.
+func findPdftoppm() (string, error) {
+	bin, err := exec.LookPath("pdftoppm")
+	if err != nil {
+		return "", fmt.Errorf("pdftoppm from poppler is required to render pdf pages, install it and make sure it's in PATH: %w", err)
+	}
+	return bin, nil
+}
+
+// extractPdfPages renders pages of a pdf with pdftoppm into a temporary folder of png images.
+// Page numbers are padded to the same width, so the images are in order by name.
+// The caller should remove the folder when it's done with the pages.
+func extractPdfPages(path string) (string, error) {
+	bin, err := findPdftoppm()
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := os.MkdirTemp("", "png2gif-frames-")
+	if err != nil {
+		return "", err
+	}
+
+	stderr := bytes.Buffer{}
+	cmd := exec.Command(bin, "-png", path, filepath.Join(dir, "page"))
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to render pages of pdf (%s): %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	return dir, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsPdf(t *testing.T) {
+	for path, want := range map[string]bool{"a.pdf": true, "b.PDF": true, "c.png": false, "pdf": false} {
+		if got := isPdf(path); got != want {
+			t.Errorf("%s: got %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestPdfWithoutPdftoppm(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	msg := gen(context.Background(), "doc.pdf", filepath.Join(t.TempDir(), "out.gif"), Options{}, nil)().(resultMsg)
+	if msg.err == nil || !strings.Contains(msg.err.Error(), "pdftoppm from poppler is required") {
+		t.Errorf("got %v, want an error about missing pdftoppm", msg.err)
+	}
+
+	cfg, err := parseFlags([]string{"-path", "doc.pdf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := runList(cfg, &strings.Builder{}); err == nil {
+		t.Error("pages of a pdf are listed")
+	}
+}
+
+// TestPdfPages builds a gif of pages rendered by a stand-in for pdftoppm,
+// which copies the images of a folder as pages with the prefix it's given.
+func TestPdfPages(t *testing.T) {
+	pages := t.TempDir()
+	writeTestImages(t, pages, testPattern(32, 32, 0), testPattern(32, 32, 60), testPattern(32, 32, 120))
+	fakeCommand(t, "pdftoppm", fmt.Sprintf("for f in %s/*; do cp \"$f\" \"$3-$(basename \"$f\")\"; done\n", pages))
+
+	out := filepath.Join(t.TempDir(), "out.gif")
+	msg := gen(context.Background(), filepath.Join(t.TempDir(), "doc.pdf"), out, Options{}, nil)().(resultMsg)
+	if msg.err != nil {
+		t.Fatal(msg.err)
+	}
+	if g := decodeTestGif(t, out); len(g.Image) != 3 {
+		t.Errorf("got %d frames, want 3 pages", len(g.Image))
+	}
+}
+
+// testPdf returns a pdf document with a page filled with each of the gray levels.
+func testPdf(grays ...float64) []byte {
+	objs := []string{"<< /Type /Catalog /Pages 2 0 R >>", ""}
+	kids := []string{}
+	for _, g := range grays {
+		content := fmt.Sprintf("%.2f g 0 0 32 32 re f", g)
+		n := len(objs) + 1
+		objs = append(objs,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 32 32] /Contents %d 0 R >>", n+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+		kids = append(kids, fmt.Sprintf("%d 0 R", n))
+	}
+	objs[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	b := bytes.Buffer{}
+	b.WriteString("%PDF-1.4\n")
+	offsets := []int{}
+	for n, o := range objs {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", n+1, o)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, xref)
+	return b.Bytes()
+}
+
+// TestPdfRendered renders pages of a pdf with pdftoppm, it needs poppler.
+func TestPdfRendered(t *testing.T) {
+	if _, err := exec.LookPath("pdftoppm"); err != nil {
+		t.Skip("pdftoppm isn't installed")
+	}
+	path := filepath.Join(t.TempDir(), "doc.pdf")
+	if err := os.WriteFile(path, testPdf(0, 0.5, 1), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(t.TempDir(), "out.gif")
+	msg := gen(context.Background(), path, out, Options{noDedup: true}, nil)().(resultMsg)
+	if msg.err != nil {
+		t.Fatal(msg.err)
+	}
+	if g := decodeTestGif(t, out); len(g.Image) != 3 {
+		t.Errorf("got %d frames, want 3 pages", len(g.Image))
+	}
+}
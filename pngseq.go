@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// defaultSequenceOutput is the folder frames of a png sequence are written into if the output isn't passed.
+// It isn't "frames", a common name of the folder of source images.
+const defaultSequenceOutput = "frames_out"
+
+// sequenceFrameName is the file name of the nth frame of a png sequence, counted from 1.
+func sequenceFrameName(n int) string {
+	return fmt.Sprintf("frame_%06d.png", n)
+}
+
+// sameFolder reports whether the paths point to the same folder, by their absolute paths or as the same existing one.
+func sameFolder(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA == nil && errB == nil && absA == absB {
+		return true
+	}
+	fa, errA := os.Stat(a)
+	fb, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(fa, fb)
+}
+
+// encodePngs encodes frames one after another as png images to the writer, delays are dropped.
+func encodePngs(ctx context.Context, w io.Writer, images *[]imgWithDelay, opts Options) error {
+	enc := png.Encoder{}
+	for n, im := range *images {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		opts.report(phaseWriting, n+1, len(*images))
+		if err := enc.Encode(w, im.img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePngSequence writes each frame as a numbered png into the folder, e.g. frame_000001.png, delays are dropped.
+// Frames written before an error are removed. Only files written by the build are removed,
+// frames left after the last one by a previous longer sequence are kept with a warning.
+func writePngSequence(ctx context.Context, images *[]imgWithDelay, dir string, opts Options) (err error) {
+	if opts.create == nil {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return &fileError{"create folder", dir, err}
+		}
+	}
+	written := []string{}
+	defer func() {
+		if err != nil {
+			for _, path := range written {
+				opts.removeFile(path)
+			}
+		}
+	}()
+
+	enc := png.Encoder{}
+	for n, im := range *images {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		opts.report(phaseWriting, n+1, len(*images))
+		path := filepath.Join(dir, sequenceFrameName(n+1))
+		f, err := opts.createFile(path)
+		if err != nil {
+			return err
+		}
+		written = append(written, path)
+		if err := enc.Encode(f, im.img); err != nil {
+			f.Close()
+			return &fileError{"write frame", path, err}
+		}
+		if err := f.Close(); err != nil {
+			return &fileError{"write frame", path, err}
+		}
+	}
+
+	// stale frames of a previous build would look like a part of the sequence
+	if opts.create == nil {
+		if _, err := os.Stat(filepath.Join(dir, sequenceFrameName(len(*images)+1))); err == nil {
+			opts.warnf("frames of a previous longer sequence are left in %s after %s", dir, sequenceFrameName(len(*images)))
+		}
+	}
+	opts.logger().Debug("output written", "path", dir, "format", formatPngSequence, "frames", len(*images))
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPngSequence(t *testing.T) {
+	dir := t.TempDir()
+	writeTestImages(t, dir, testPattern(32, 32, 0), testPattern(32, 32, 0), testPattern(32, 32, 60), testPattern(32, 32, 120), testPattern(32, 32, 180))
+	out := filepath.Join(t.TempDir(), "frames")
+	// frames of a previous longer sequence are kept with a warning
+	if err := os.MkdirAll(out, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{5, 6} {
+		if err := os.WriteFile(filepath.Join(out, sequenceFrameName(n)), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	msg := gen(context.Background(), dir, out, parseTestFlags(t, "-format", "png-sequence", "-size", "16x16").opts, nil)().(resultMsg)
+	if msg.err != nil {
+		t.Fatal(msg.err)
+	}
+	if want := []string{"frames of a previous longer sequence are left in " + out + " after frame_000004.png"}; !slices.Equal(msg.warnings, want) {
+		t.Errorf("got warnings %q, want %q", msg.warnings, want)
+	}
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	// equal images are merged, so 4 frames are written
+	if want := []string{"frame_000001.png", "frame_000002.png", "frame_000003.png", "frame_000004.png", "frame_000005.png", "frame_000006.png"}; !slices.Equal(names, want) {
+		t.Fatalf("got %v, want %v", names, want)
+	}
+	for _, name := range names[:4] {
+		f, err := os.Open(filepath.Join(out, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := png.DecodeConfig(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if cfg.Width != 16 || cfg.Height != 16 {
+			t.Errorf("%s: got %dx%d, want 16x16", name, cfg.Width, cfg.Height)
+		}
+	}
+
+	// the default folder isn't named as a common folder of source images
+	if cfg := parseTestFlags(t, "-format", "png-sequence"); cfg.out != "frames_out" {
+		t.Errorf("got the default output %q, want frames_out", cfg.out)
+	}
+	// the sequence isn't written into the folder of images
+	for _, o := range []string{dir, filepath.Join(dir, ".")} {
+		msg := gen(context.Background(), dir, o, Options{format: formatPngSequence}, nil)().(resultMsg)
+		if msg.err == nil || !strings.Contains(msg.err.Error(), "can't be written into the input folder") {
+			t.Errorf("%s: got %v, want an error about the input folder", o, msg.err)
+		}
+	}
+	parseUsageError(t, "-format", "png-sequence", "-batch")
+	parseUsageError(t, "-format", "bmp")
+}
+
+func TestPngSequenceRemovesPartial(t *testing.T) {
+	out := t.TempDir()
+	// the third frame can't be created, as a folder has its name
+	if err := os.Mkdir(filepath.Join(out, sequenceFrameName(3)), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	frames := testFrames(8, 8, testRed, testGreen, testBlue)
+	if err := writePngSequence(context.Background(), frames, out, Options{}); err == nil {
+		t.Fatal("got no error")
+	}
+	for _, n := range []int{1, 2} {
+		if _, err := os.Stat(filepath.Join(out, sequenceFrameName(n))); !os.IsNotExist(err) {
+			t.Errorf("frame %d is left: %v", n, err)
+		}
+	}
+	// files the build didn't write are kept
+	if _, err := os.Stat(filepath.Join(out, sequenceFrameName(3))); err != nil {
+		t.Errorf("the folder of frame 3 is removed: %v", err)
+	}
+}
+
+func TestEncodePngs(t *testing.T) {
+	b := bytes.Buffer{}
+	if err := EncodeTo(&b, formatPngSequence, []imgWithDelay{{img: testFrame(8, 8, testRed), delay: 1}, {img: testFrame(4, 4, testBlue), delay: 2}}, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	// pngs follow one another in the stream
+	for n, want := range []image.Point{{8, 8}, {4, 4}} {
+		img, err := png.Decode(&b)
+		if err != nil {
+			t.Fatalf("image %d: %v", n, err)
+		}
+		if img.Bounds().Size() != want {
+			t.Errorf("image %d: got %v, want %v", n, img.Bounds().Size(), want)
+		}
+	}
+}
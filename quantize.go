@@ -0,0 +1,422 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// quantizers to build palettes of frames.
+const (
+	quantizerDefault   = "default"
+	quantizerKmeans    = "kmeans"
+	quantizerMedianCut = "median-cut"
+	quantizerOctree    = "octree"
+)
+
+// kmeansIterations is the number of clustering iterations of the k-means quantizer.
+const kmeansIterations = 8
+
+// paletteQuantizer returns the quantizer of the options that builds palettes of frames,
+// k-means for the default one, as it's used where the plan9 palette doesn't fit, e.g. with fewer colors.
+// Quantizers implement draw.Quantizer, so they are interchangeable in gif options.
+func (o Options) paletteQuantizer() draw.Quantizer {
+	switch o.quantizer {
+	case quantizerMedianCut:
+		return medianCutQuantizer{linear: o.linear}
+	case quantizerOctree:
+		return octreeQuantizer{linear: o.linear}
+	}
+	return kmeansQuantizer{seed: o.seed, iterations: kmeansIterations, linear: o.linear}
+}
+
+// highBitDepth checks if the image has 16 bits per channel, like 16-bit png files.
+func highBitDepth(img image.Image) bool {
+	switch img.(type) {
+	case *image.NRGBA64, *image.RGBA64, *image.Gray16:
+		return true
+	}
+	return false
+}
+
+// kmeansSamples is the max number of pixels sampled from an image to build a palette.
+const kmeansSamples = 8192
+
+// kmeansQuantizer builds a palette with k-means clustering of sampled pixels.
+// The random sampling and initial centers are seeded, so the same seed gives the same palette.
+// @property {int64} seed - The seed of the random generator.
+// @property {int} iterations - The number of clustering iterations.
+// @property {bool} linear - Whether colors are clustered in linear light.
+type kmeansQuantizer struct {
+	seed       int64
+	iterations int
+	linear     bool
+}
+
+// Quantize appends up to cap(p)-len(p) colors to the palette, it implements draw.Quantizer.
+func (q kmeansQuantizer) Quantize(p color.Palette, m image.Image) color.Palette {
+	k := cap(p) - len(p)
+	if k <= 0 {
+		return p
+	}
+	rng := rand.New(rand.NewSource(q.seed))
+
+	// sample pixels at random positions
+	b := m.Bounds()
+	n := b.Dx() * b.Dy()
+	if n == 0 {
+		return p
+	}
+	if n > kmeansSamples {
+		n = kmeansSamples
+	}
+	samples := make([][4]float64, n)
+	for i := range samples {
+		x := b.Min.X + rng.Intn(b.Dx())
+		y := b.Min.Y + rng.Intn(b.Dy())
+		c := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+		samples[i] = [4]float64{float64(c.R), float64(c.G), float64(c.B), float64(c.A)}
+		if q.linear {
+			samples[i] = linearSample(samples[i])
+		}
+	}
+
+	// start with random samples as centers
+	if k > n {
+		k = n
+	}
+	centers := make([][4]float64, k)
+	for i := range centers {
+		centers[i] = samples[rng.Intn(n)]
+	}
+
+	assigned := make([]int, n)
+	for it := 0; it < q.iterations; it++ {
+		for i, s := range samples {
+			assigned[i] = nearestCenter(centers, s)
+		}
+
+		sums := make([][4]float64, k)
+		counts := make([]int, k)
+		for i, s := range samples {
+			c := assigned[i]
+			for ch := range s {
+				sums[c][ch] += s[ch]
+			}
+			counts[c]++
+		}
+		for c := range centers {
+			// move an empty cluster to a random sample, so all colors of the palette are used
+			if counts[c] == 0 {
+				centers[c] = samples[rng.Intn(n)]
+				continue
+			}
+			for ch := range sums[c] {
+				centers[c][ch] = sums[c][ch] / float64(counts[c])
+			}
+		}
+	}
+
+	for _, c := range centers {
+		p = append(p, sampleColor(c, q.linear))
+	}
+	return p
+}
+
+// nearestCenter returns the index of the closest center to the sample by squared Euclidean distance.
+func nearestCenter(centers [][4]float64, s [4]float64) int {
+	best, bestDist := 0, -1.0
+	for i, c := range centers {
+		dist := 0.0
+		for ch := range s {
+			d := s[ch] - c[ch]
+			dist += d * d
+		}
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return best
+}
+
+// paletteSamples is the max number of pixels of an image the median cut and octree quantizers build a palette from.
+const paletteSamples = 1 << 16
+
+// gridSamples returns colors of pixels of the image on an even grid, at most about n of them, in linear light if linear is set.
+func gridSamples(m image.Image, n int, linear bool) [][4]float64 {
+	b := m.Bounds()
+	if b.Empty() {
+		return nil
+	}
+	step := max(1, int(math.Ceil(math.Sqrt(float64(b.Dx()*b.Dy())/float64(n)))))
+	samples := make([][4]float64, 0, (b.Dx()/step+1)*(b.Dy()/step+1))
+	for y := b.Min.Y; y < b.Max.Y; y += step {
+		for x := b.Min.X; x < b.Max.X; x += step {
+			c := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+			s := [4]float64{float64(c.R), float64(c.G), float64(c.B), float64(c.A)}
+			if linear {
+				s = linearSample(s)
+			}
+			samples = append(samples, s)
+		}
+	}
+	return samples
+}
+
+// meanColor returns the average color of the samples, converted back from linear light if they are linear.
+func meanColor(samples [][4]float64, linear bool) color.NRGBA {
+	sum := [4]float64{}
+	for _, s := range samples {
+		for ch := range s {
+			sum[ch] += s[ch]
+		}
+	}
+	n := float64(len(samples))
+	return sampleColor([4]float64{sum[0] / n, sum[1] / n, sum[2] / n, sum[3] / n}, linear)
+}
+
+// medianCutQuantizer builds a palette by splitting boxes of sampled pixels at the median of their widest channel,
+// until there is a box for each color. Much faster than k-means with many colors, a bit less accurate.
+// @property {bool} linear - Whether colors are split in linear light.
+type medianCutQuantizer struct {
+	linear bool
+}
+
+// Quantize appends up to cap(p)-len(p) colors to the palette, it implements draw.Quantizer.
+func (q medianCutQuantizer) Quantize(p color.Palette, m image.Image) color.Palette {
+	k := cap(p) - len(p)
+	samples := gridSamples(m, paletteSamples, q.linear)
+	if k <= 0 || len(samples) == 0 {
+		return p
+	}
+
+	boxes := []colorBox{newColorBox(samples)}
+	for len(boxes) < k {
+		// split the box with the widest range of a channel, boxes of a single color can't be split
+		split := 0
+		for i, b := range boxes {
+			if b.width > boxes[split].width {
+				split = i
+			}
+		}
+		if boxes[split].width == 0 {
+			break
+		}
+		s, ch := boxes[split].samples, boxes[split].channel
+		sort.Slice(s, func(i, j int) bool { return s[i][ch] < s[j][ch] })
+		boxes[split] = newColorBox(s[:len(s)/2])
+		boxes = append(boxes, newColorBox(s[len(s)/2:]))
+	}
+
+	for _, b := range boxes {
+		p = append(p, meanColor(b.samples, q.linear))
+	}
+	return p
+}
+
+// colorBox is a box of sampled pixels of the median cut quantizer.
+// @property {[][4]float64} samples - The colors of pixels in the box.
+// @property {int} channel - The channel with the widest range of values.
+// @property {float64} width - The range of values of the channel.
+type colorBox struct {
+	samples [][4]float64
+	channel int
+	width   float64
+}
+
+// newColorBox returns a box of the samples with their widest channel.
+func newColorBox(samples [][4]float64) colorBox {
+	b := colorBox{samples: samples}
+	for ch := 0; ch < 4; ch++ {
+		lo, hi := samples[0][ch], samples[0][ch]
+		for _, s := range samples {
+			lo, hi = math.Min(lo, s[ch]), math.Max(hi, s[ch])
+		}
+		if hi-lo > b.width {
+			b.channel, b.width = ch, hi-lo
+		}
+	}
+	return b
+}
+
+// octreeDepth is the number of levels of the octree, a level per bit of a channel.
+const octreeDepth = 8
+
+// octreeNode is a node of the color octree, leaves sum the colors of pixels that fall into them.
+// @property {[8]*octreeNode} children - The child nodes by the next bits of red, green and blue.
+// @property {[4]float64} sum - The sum of colors of pixels in the leaf.
+// @property {int} count - The number of pixels in the leaf.
+// @property {bool} leaf - Whether the node is a leaf.
+type octreeNode struct {
+	children [8]*octreeNode
+	sum      [4]float64
+	count    int
+	leaf     bool
+}
+
+// octreeQuantizer builds a palette by putting sampled pixels into an octree of colors
+// and merging the deepest nodes until there are as many leaves as colors at most. Its speed barely depends on the number
+// of colors, but merges can leave a few colors less than asked for.
+// @property {bool} linear - Whether colors are merged in linear light.
+type octreeQuantizer struct {
+	linear bool
+}
+
+// Quantize appends up to cap(p)-len(p) colors to the palette, it implements draw.Quantizer.
+func (q octreeQuantizer) Quantize(p color.Palette, m image.Image) color.Palette {
+	k := cap(p) - len(p)
+	samples := gridSamples(m, paletteSamples, q.linear)
+	if k <= 0 || len(samples) == 0 {
+		return p
+	}
+
+	root := &octreeNode{}
+	// inner nodes by their level, the deepest ones are merged first
+	levels := make([][]*octreeNode, octreeDepth)
+	leaves := 0
+	for _, s := range samples {
+		node := root
+		for level := 0; level < octreeDepth && !node.leaf; level++ {
+			shift := 7 - level
+			i := int(s[0])>>shift&1<<2 | int(s[1])>>shift&1<<1 | int(s[2])>>shift&1
+			if node.children[i] == nil {
+				node.children[i] = &octreeNode{leaf: level == octreeDepth-1}
+				if node.children[i].leaf {
+					leaves++
+				} else {
+					levels[level+1] = append(levels[level+1], node.children[i])
+				}
+			}
+			node = node.children[i]
+		}
+		for ch := range s {
+			node.sum[ch] += s[ch]
+		}
+		node.count++
+	}
+
+	// merge children of the deepest nodes into them until the leaves fit into the palette
+	for level := octreeDepth - 1; level >= 0 && leaves > k; level-- {
+		nodes := levels[level]
+		if level == 0 {
+			nodes = []*octreeNode{root}
+		}
+		// nodes with fewer pixels are merged first, so colors of large areas stay accurate
+		sort.SliceStable(nodes, func(i, j int) bool { return octreeCount(nodes[i]) < octreeCount(nodes[j]) })
+		for _, node := range nodes {
+			if leaves <= k {
+				break
+			}
+			for i, c := range node.children {
+				if c == nil {
+					continue
+				}
+				for ch := range c.sum {
+					node.sum[ch] += c.sum[ch]
+				}
+				node.count += c.count
+				node.children[i] = nil
+				leaves--
+			}
+			node.leaf = true
+			leaves++
+		}
+	}
+
+	var collect func(node *octreeNode)
+	collect = func(node *octreeNode) {
+		if node.leaf {
+			if node.count > 0 {
+				n := float64(node.count)
+				p = append(p, sampleColor([4]float64{node.sum[0] / n, node.sum[1] / n, node.sum[2] / n, node.sum[3] / n}, q.linear))
+			}
+			return
+		}
+		for _, c := range node.children {
+			if c != nil {
+				collect(c)
+			}
+		}
+	}
+	collect(root)
+	return p
+}
+
+// octreeCount returns the number of pixels under the node.
+func octreeCount(node *octreeNode) int {
+	n := node.count
+	for _, c := range node.children {
+		if c != nil {
+			n += octreeCount(c)
+		}
+	}
+	return n
+}
+
+// palette modes of gif frames.
+const (
+	paletteModePerFrame = "per-frame"
+	paletteModeGlobal   = "global"
+	paletteModeSampled  = "shared-sampled"
+)
+
+// globalSamples is the max number of frames put into the composite image to build a global palette.
+const globalSamples = 16
+
+// globalSampleWidth is the max width of a frame in the composite image, palettes don't need all pixels.
+const globalSampleWidth = 256
+
+// globalPalette builds one palette for all frames from a composite of evenly sampled frames,
+// with the same round-trip through the gif encoder as frames, so colors don't flicker between frames.
+// All frames are put into the composite if samples is 0, each smaller to keep its size as of the sampled ones.
+func globalPalette(images []imgWithDelay, samples int, opts Options) (color.Palette, error) {
+	sampleWidth := globalSampleWidth
+	if samples <= 0 {
+		samples = len(images)
+		if samples > globalSamples {
+			sampleWidth = int(float64(globalSampleWidth) * math.Sqrt(float64(globalSamples)/float64(samples)))
+		}
+	}
+	step := (len(images) + samples - 1) / samples
+	sampled := []image.Image{}
+	height, width := 0, 0
+	for i := 0; i < len(images); i += step {
+		img := images[i].img
+		if w := img.Bounds().Dx(); w > sampleWidth {
+			img = scaleImage(img, float64(sampleWidth)/float64(w), opts.linear)
+		}
+		sampled = append(sampled, img)
+		height += img.Bounds().Dy()
+		if w := img.Bounds().Dx(); w > width {
+			width = w
+		}
+	}
+
+	// stack sampled frames vertically
+	composite := image.NewNRGBA(image.Rect(0, 0, width, height))
+	y := 0
+	for _, img := range sampled {
+		b := img.Bounds()
+		draw.Draw(composite, image.Rect(0, y, b.Dx(), y+b.Dy()), img, b.Min, draw.Src)
+		y += b.Dy()
+	}
+
+	b := bytes.Buffer{}
+	err := gif.Encode(&b, composite, &gif.Options{
+		NumColors: opts.colors(),
+		Quantizer: opts.paletteQuantizer(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	img, err := gif.Decode(&b)
+	if err != nil {
+		return nil, err
+	}
+	return img.(*image.Paletted).Palette, nil
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// noisyImage returns an image of random colors of the seed, so palettes of it depend on sampled pixels.
+func noisyImage(w, h int, seed int64) *image.RGBA {
+	rng := rand.New(rand.NewSource(seed))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(rng.Intn(256))
+		if i%4 == 3 {
+			img.Pix[i] = 255
+		}
+	}
+	return img
+}
+
+// quantizeTest builds a palette of 16 colors of the image with the quantizer.
+func quantizeTest(q draw.Quantizer, img image.Image) color.Palette {
+	return q.Quantize(make(color.Palette, 0, 16), img)
+}
+
+func TestKmeansSeed(t *testing.T) {
+	img := noisyImage(128, 128, 1)
+	quantizer := func(seed int64) kmeansQuantizer {
+		return kmeansQuantizer{seed: seed, iterations: 8}
+	}
+	a := quantizeTest(quantizer(7), img)
+	if len(a) != 16 {
+		t.Fatalf("got %d colors, want 16", len(a))
+	}
+	if b := quantizeTest(quantizer(7), img); !slices.EqualFunc(a, b, colorsEqual) {
+		t.Error("palettes of the same seed differ")
+	}
+	differ := false
+	for seed := int64(8); seed < 12 && !differ; seed++ {
+		differ = !slices.EqualFunc(a, quantizeTest(quantizer(seed), img), colorsEqual)
+	}
+	if !differ {
+		t.Error("palettes of other seeds are all the same")
+	}
+}
+
+func TestSeedBuildReproducible(t *testing.T) {
+	images := []image.Image{noisyImage(32, 32, 1), testPattern(32, 32, 0)}
+	opts := parseTestFlags(t, "-quantizer", "kmeans", "-seed", "3").opts
+	a := buildTestGif(t, opts, images...)
+	b := buildTestGif(t, opts, images...)
+	gifsEqual(t, a, b)
+	for n := range a.Image {
+		if !slices.EqualFunc(a.Image[n].Palette, b.Image[n].Palette, colorsEqual) {
+			t.Errorf("frame %d: palettes differ", n)
+		}
+	}
+}
+
+// gradient16 returns a smooth 16-bit gradient, which the plan9 palette bands.
+func gradient16(w, h int) *image.NRGBA64 {
+	img := image.NewNRGBA64(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA64(x, y, color.NRGBA64{
+				R: uint16(0x3000 + x*0x4000/w),
+				G: uint16(0x5000 + y*0x3000/h),
+				B: 0x8000,
+				A: 0xffff,
+			})
+		}
+	}
+	return img
+}
+
+// bandingError is the mean difference of the 4x4 block averages of the paletted image to the source one,
+// bands shift whole blocks off the gradient while dithering keeps their averages.
+func bandingError(src image.Image, p *image.Paletted) float64 {
+	const block = 4
+	sum, b := 0.0, src.Bounds()
+	for by := b.Min.Y; by < b.Max.Y; by += block {
+		for bx := b.Min.X; bx < b.Max.X; bx += block {
+			var d [3]float64
+			for y := by; y < by+block; y++ {
+				for x := bx; x < bx+block; x++ {
+					r1, g1, b1, _ := src.At(x, y).RGBA()
+					r2, g2, b2, _ := p.At(x, y).RGBA()
+					d[0] += float64(r1) - float64(r2)
+					d[1] += float64(g1) - float64(g2)
+					d[2] += float64(b1) - float64(b2)
+				}
+			}
+			for _, c := range d {
+				sum += math.Abs(c) / (block * block * 0x101)
+			}
+		}
+	}
+	return sum / float64(3*b.Dx()*b.Dy()/(block*block))
+}
+
+func TestHighBitDepth(t *testing.T) {
+	deep := gradient16(64, 64)
+	if !highBitDepth(deep) || !highBitDepth(image.NewGray16(deep.Rect)) || highBitDepth(image.NewRGBA(deep.Rect)) {
+		t.Fatal("wrong bit depth detection")
+	}
+	// the same gradient truncated to 8 bits takes the plain plan9 round trip
+	flat := image.NewRGBA(deep.Rect)
+	draw.Draw(flat, flat.Rect, deep, image.Point{}, draw.Src)
+
+	images := framesOf(deep, flat)
+	imgp, err := encodeImgPaletted(context.Background(), &images, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dithered, naive := bandingError(deep, imgp[0].paletted), bandingError(deep, imgp[1].paletted)
+	if dithered >= naive {
+		t.Errorf("got the error %.2f of the 16-bit image, want less than %.2f of the plain round trip", dithered, naive)
+	}
+	if n := len(imgp[0].paletted.Palette); n > 256 {
+		t.Errorf("got %d colors", n)
+	}
+}
+
+// distinctPalettes returns the number of different palettes of the frames.
+func distinctPalettes(frames []*palettedWithDelay) int {
+	palettes := []color.Palette{}
+	for _, f := range frames {
+		if !slices.ContainsFunc(palettes, func(p color.Palette) bool { return slices.EqualFunc(p, f.paletted.Palette, colorsEqual) }) {
+			palettes = append(palettes, f.paletted.Palette)
+		}
+	}
+	return len(palettes)
+}
+
+func TestGlobalPalette(t *testing.T) {
+	// frames fade from red to blue, so palettes of each frame differ
+	gradients := []image.Image{}
+	for n := 0; n < 6; n++ {
+		gradients = append(gradients, testGradient(32, 32, color.RGBA{uint8(255 - n*50), 40, uint8(n * 50), 255}))
+	}
+	images := framesOf(gradients...)
+	for _, mode := range []string{paletteModePerFrame, paletteModeSampled, paletteModeGlobal} {
+		frames, err := encodeImgPaletted(context.Background(), &images, Options{numColors: 32, paletteMode: mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := 1
+		if mode == paletteModePerFrame {
+			want = len(images)
+		}
+		if n := distinctPalettes(frames); n != want {
+			t.Errorf("%s: got %d palettes, want %d", mode, n, want)
+		}
+		for n, f := range frames {
+			if len(f.paletted.Palette) > 32 {
+				t.Errorf("%s: frame %d has %d colors", mode, n, len(f.paletted.Palette))
+			}
+			// the shared palette still has colors of each frame
+			if d := bandingError(images[n].img, f.paletted); d > 8 {
+				t.Errorf("%s: frame %d is off by %.1f", mode, n, d)
+			}
+		}
+	}
+
+	if cfg := parseTestFlags(t, "-global-palette"); cfg.opts.paletteMode != paletteModeSampled {
+		t.Errorf("got the palette mode %q, want %q", cfg.opts.paletteMode, paletteModeSampled)
+	}
+}
+
+func TestPaletteModes(t *testing.T) {
+	// a green frame between sampled ones, only the global palette is built from it
+	sources := []image.Image{}
+	for n := 0; n < 2*globalSamples; n++ {
+		img := testGradient(32, 32, color.RGBA{200, 40, 40, 255})
+		if n == 1 {
+			img = testFrame(32, 32, testGreen)
+		}
+		sources = append(sources, img)
+	}
+	images := framesOf(sources...)
+	hasGreen := map[string]bool{}
+	for _, mode := range []string{paletteModeSampled, paletteModeGlobal} {
+		frames, err := encodeImgPaletted(context.Background(), &images, Options{numColors: 16, paletteMode: mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pal := frames[0].paletted.Palette
+		hasGreen[mode] = colorsEqual(pal[pal.Index(testGreen)], testGreen)
+	}
+	if !hasGreen[paletteModeGlobal] || hasGreen[paletteModeSampled] {
+		t.Errorf("got green in palettes %v, want it only in the global one", hasGreen)
+	}
+
+	// the global palette is written once, frames don't have their own
+	sizes := map[string]int{}
+	for _, mode := range []string{paletteModePerFrame, paletteModeGlobal} {
+		frames := append([]imgWithDelay{}, images...)
+		b := bytes.Buffer{}
+		if err := encodeTo(context.Background(), &b, formatGif, &frames, 4, Options{numColors: 16, paletteMode: mode}); err != nil {
+			t.Fatal(err)
+		}
+		sizes[mode] = b.Len()
+		g, err := gif.DecodeAll(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		global := g.Config.ColorModel != nil
+		for n, p := range g.Image {
+			if local := !global || !slices.Equal(p.Palette, g.Config.ColorModel.(color.Palette)); local == (mode == paletteModeGlobal) {
+				t.Errorf("%s: frame %d has a local palette %v", mode, n, local)
+			}
+		}
+	}
+	if sizes[paletteModeGlobal] >= sizes[paletteModePerFrame] {
+		t.Errorf("got %d bytes with the global palette, want less than %d with palettes per frame", sizes[paletteModeGlobal], sizes[paletteModePerFrame])
+	}
+
+	if cfg := parseTestFlags(t, "-palette-mode", "global"); cfg.opts.paletteMode != paletteModeGlobal {
+		t.Errorf("got the palette mode %q, want %q", cfg.opts.paletteMode, paletteModeGlobal)
+	}
+	parseUsageError(t, "-palette-mode", "adaptive")
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{0: "0 B", 1023: "1023 B", 1024: "1.0 KiB", 1536: "1.5 KiB", 5 << 20: "5.0 MiB", 3 << 30: "3.0 GiB"} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("%d: got %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestQuantizers(t *testing.T) {
+	quantizers := map[string]draw.Quantizer{
+		quantizerKmeans:    Options{quantizer: quantizerKmeans}.paletteQuantizer(),
+		quantizerMedianCut: Options{quantizer: quantizerMedianCut}.paletteQuantizer(),
+		quantizerOctree:    Options{quantizer: quantizerOctree}.paletteQuantizer(),
+	}
+	if _, ok := quantizers[quantizerMedianCut].(medianCutQuantizer); !ok {
+		t.Errorf("got %T for median-cut", quantizers[quantizerMedianCut])
+	}
+	if _, ok := quantizers[quantizerOctree].(octreeQuantizer); !ok {
+		t.Errorf("got %T for octree", quantizers[quantizerOctree])
+	}
+	if _, ok := (Options{}).paletteQuantizer().(kmeansQuantizer); !ok {
+		t.Error("the default quantizer isn't k-means where palettes are built")
+	}
+
+	img := noisyImage(128, 128, 1)
+	grad := gradient16(128, 32)
+	for name, q := range quantizers {
+		prevErr := 0.0
+		for _, size := range []int{2, 16, 64, 256} {
+			pal := q.Quantize(make(color.Palette, 0, size), img)
+			// merges of the octree can leave a few colors less
+			if len(pal) > size || len(pal) < size*3/4 {
+				t.Errorf("%s: got %d colors, want %d", name, len(pal), size)
+			}
+			for _, c := range pal {
+				if _, _, _, a := c.RGBA(); a != 0xffff {
+					t.Errorf("%s: color %v isn't opaque", name, c)
+					break
+				}
+			}
+			// more colors follow the gradient closer
+			p := image.NewPaletted(grad.Bounds(), q.Quantize(make(color.Palette, 0, size), grad))
+			draw.Draw(p, p.Rect, grad, image.Point{}, draw.Src)
+			e := bandingError(grad, p)
+			if size > 2 && e >= prevErr {
+				t.Errorf("%s: got error %.2f with %d colors, want less than %.2f", name, e, size, prevErr)
+			}
+			prevErr = e
+		}
+
+		// colors are appended to the ones already in the palette, a few colors are kept exactly
+		few := testFrame(32, 32, testRed)
+		draw.Draw(few, image.Rect(0, 0, 16, 32), image.NewUniform(testBlue), image.Point{}, draw.Src)
+		pal := q.Quantize(append(make(color.Palette, 0, 16), color.Black), few)
+		if len(pal) < 3 || pal[0] != color.Black {
+			t.Fatalf("%s: got %v, want black first and new colors after it", name, pal)
+		}
+		for _, c := range []color.Color{testRed, testBlue} {
+			if !colorsEqual(pal[pal.Index(c)], c) {
+				t.Errorf("%s: %v isn't in the palette %v", name, c, pal)
+			}
+		}
+	}
+
+	for _, name := range []string{quantizerMedianCut, quantizerOctree} {
+		g := encodeTestGif(t, []image.Image{noisyImage(32, 32, 1), testPattern(32, 32, 0)}, parseTestFlags(t, "-quantizer", name, "-colors", "16").opts)
+		for n, p := range g.Image {
+			if len(p.Palette) > 16 {
+				t.Errorf("%s: frame %d has %d colors", name, n, len(p.Palette))
+			}
+		}
+	}
+	parseUsageError(t, "-quantizer", "neuquant")
+}
+
+func TestDefaultQuantizer(t *testing.T) {
+	// the zero options and the default quantizer map frames onto the plan9 palette
+	for _, opts := range []Options{{}, {quantizer: quantizerDefault}} {
+		imgp, err := encodeImgPaletted(context.Background(), testFrames(8, 8, color.RGBA{10, 200, 30, 255}), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p := imgp[0].paletted.Palette; !slices.Equal(p, color.Palette(palette.Plan9)) {
+			t.Errorf("%q: got a palette of %d colors, want the plan9 one", opts.quantizer, len(p))
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/csv"
+	"strconv"
+	"strings"
+
+	"github.com/vitali-fedulov/images4"
+)
+
+// reportHeader is the header row of the csv report of output frames.
+var reportHeader = []string{"frame", "sources", "delay", "width", "height", "prop", "y", "cb", "cr"}
+
+// writeReport writes a csv report of output frames: the source files merged into each frame,
+// its delay in 100ths of a second, its size and the similarity metrics to the previous frame.
+// Frames split by the max delay get a row each, so rows match frames of the output. Metrics of the first frame are empty.
+func writeReport(images []imgWithDelay, delay int, path string, opts Options) error {
+	f, err := opts.createFile(path)
+	if err != nil {
+		return &fileError{"create report", path, err}
+	}
+
+	reps := make([]int, len(images))
+	for n, im := range images {
+		reps[n] = im.delay
+	}
+	images, delays := splitDelays(images, frameDelays(reps, delay, opts), opts.maxDelay)
+
+	w := csv.NewWriter(f)
+	w.Write(reportHeader)
+	prev := images4.IconT{}
+	for n, im := range images {
+		size := im.img.Bounds().Size()
+		icon := images4.Icon(im.img)
+		row := []string{
+			strconv.Itoa(n + 1),
+			strings.Join(im.sources, ";"),
+			strconv.Itoa(delays[n]),
+			strconv.Itoa(size.X),
+			strconv.Itoa(size.Y),
+		}
+		if n == 0 {
+			row = append(row, "", "", "", "")
+		} else {
+			row = append(row, reportMetrics(prev, icon)...)
+		}
+		w.Write(row)
+		prev = icon
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return &fileError{"write report", path, err}
+	}
+	if err := f.Close(); err != nil {
+		return &fileError{"write report", path, err}
+	}
+	return nil
+}
+
+// reportMetrics returns the proportion and color metrics between icons of frames formatted for the report,
+// color distances are means per pixel of icons rather than their sums, so they don't depend on the icon size.
+func reportMetrics(a, b images4.IconT) []string {
+	y, cb, cr := images4.EucMetric(a, b)
+	pixels := float64(images4.IconSize * images4.IconSize)
+	metrics := []string{}
+	for _, m := range []float64{images4.PropMetric(a, b), y / pixels, cb / pixels, cr / pixels} {
+		metrics = append(metrics, strconv.FormatFloat(m, 'f', 4, 64))
+	}
+	return metrics
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/vitali-fedulov/images4"
+)
+
+func TestWriteReport(t *testing.T) {
+	images := *testFrames(32, 32, testRed, testBlue, testBlue)
+	images[1].delay = 5
+	images[1].sources = []string{"b", "c"}
+	f := &memFile{}
+	opts := Options{maxDelay: 20, create: func(string) (io.WriteCloser, error) { return f, nil }}
+	if err := writeReport(images[:2], 10, "report.csv", opts); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the frame of 50 is split into frames of 20, 20 and 10
+	want := [][]string{
+		reportHeader,
+		{"1", "a", "10"},
+		{"2", "b;c", "20"},
+		{"3", "b;c", "20"},
+		{"4", "b;c", "10"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(rows), len(want))
+	}
+	for n, w := range want {
+		if !slices.Equal(rows[n][:len(w)], w) {
+			t.Errorf("row %d: got %v, want %v", n, rows[n][:len(w)], w)
+		}
+	}
+	if rows[1][5] != "" {
+		t.Errorf("metrics of the first frame: %v", rows[1][5:])
+	}
+
+	// color distances are means per pixel of icons
+	a, b := images4.Icon(images[0].img), images4.Icon(images[1].img)
+	y, _, _ := images4.EucMetric(a, b)
+	if want := y / (images4.IconSize * images4.IconSize); y == 0 || strconv.FormatFloat(want, 'f', 4, 64) != rows[2][6] {
+		t.Errorf("y of red and blue: got %s, want the mean %.4f", rows[2][6], want)
+	}
+	if rows[3][6] != "0.0000" {
+		t.Errorf("y of a split frame: got %s, want 0", rows[3][6])
+	}
+}
+
+func TestDropFramesKeepsSources(t *testing.T) {
+	images := *testFrames(4, 4, testRed, testGreen, testBlue, testRed, testGreen)
+	frames := dropFrames(images, 2)
+	if len(frames) != 3 {
+		t.Fatalf("got %d frames, want 3", len(frames))
+	}
+	for n, want := range [][]string{{"a", "b"}, {"c", "d"}, {"e"}} {
+		if !slices.Equal(frames[n].sources, want) {
+			t.Errorf("frame %d: got sources %v, want %v", n, frames[n].sources, want)
+		}
+	}
+	if !slices.Equal(images[0].sources, []string{"a"}) {
+		t.Errorf("sources of images are changed: %v", images[0].sources)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sort modes of files in a folder.
+const (
+	sortName    = "name"
+	sortCreated = "created"
+	sortExif    = "exif"
+	sortNatural = "natural"
+)
+
+// numbers of natural sort used to order files.
+const (
+	sortNumberFirst = "first"
+	sortNumberLast  = "last"
+)
+
+// sortFileInfos sorts files by name, or by modification time with name as a tie breaker.
+func sortFileInfos(infos []os.FileInfo, mode string) {
+	sort.SliceStable(infos, func(i, j int) bool {
+		if mode == sortCreated && !infos[i].ModTime().Equal(infos[j].ModTime()) {
+			return infos[i].ModTime().Before(infos[j].ModTime())
+		}
+		return infos[i].Name() < infos[j].Name()
+	})
+}
+
+// reverseFileInfos reverses the order of files in place, e.g. for a countdown in descending order.
+func reverseFileInfos(infos []os.FileInfo) {
+	for i, j := 0, len(infos)-1; i < j; i, j = i+1, j-1 {
+		infos[i], infos[j] = infos[j], infos[i]
+	}
+}
+
+// numberRun matches a run of digits in a file name.
+var numberRun = regexp.MustCompile(`\d+`)
+
+// nameNumber returns the nth run of digits in the file name without its extension with leading zeros trimmed,
+// n is 1 for the first run and -1 for the last one. False if there is no such run.
+func nameNumber(name string, n int) (string, bool) {
+	runs := numberRun.FindAllString(strings.TrimSuffix(name, filepath.Ext(name)), -1)
+	i := n - 1
+	if n < 0 {
+		i = len(runs) + n
+	}
+	if i < 0 || i >= len(runs) {
+		return "", false
+	}
+	return strings.TrimLeft(runs[i], "0"), true
+}
+
+// sortByNumber sorts files by the value of the nth number in their names, e.g. 42 in render_0042_final.png,
+// so frame_2 comes before frame_10. Files without the number come last, name is a tie breaker.
+// 0 uses the last number.
+func sortByNumber(infos []os.FileInfo, n int) {
+	if n == 0 {
+		n = -1
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		a, okA := nameNumber(infos[i].Name(), n)
+		b, okB := nameNumber(infos[j].Name(), n)
+		if okA != okB {
+			return okA
+		}
+		// numbers without leading zeros compare by length first, so they never overflow
+		if a != b {
+			if len(a) != len(b) {
+				return len(a) < len(b)
+			}
+			return a < b
+		}
+		return infos[i].Name() < infos[j].Name()
+	})
+}
+
+// parseSortNumber parses which number in file names natural sort uses:
+// first, last or a position from 1, and returns it as 1 for the first and -1 for the last.
+func parseSortNumber(s string) (int, error) {
+	switch s {
+	case sortNumberFirst:
+		return 1, nil
+	case sortNumberLast:
+		return -1, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid sort number %q, use first, last or a position from 1", s)
+	}
+	return n, nil
+}
+
+// sortByExif sorts images in the folder by capture time from exif data,
+// images without it use the modification time, name is a tie breaker.
+func sortByExif(infos []os.FileInfo, dir string, opts Options) {
+	times := make(map[string]time.Time, len(infos))
+	for _, fi := range infos {
+		times[fi.Name()] = fi.ModTime()
+		f, err := opts.open(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			continue
+		}
+		if t, err := readExifTime(f); err == nil {
+			times[fi.Name()] = t
+		}
+		f.Close()
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		ti, tj := times[infos[i].Name()], times[infos[j].Name()]
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return infos[i].Name() < infos[j].Name()
+	})
+}
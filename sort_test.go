@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// listTestFiles lists images of the file system in the root folder with the options.
+func listTestFiles(t *testing.T, fsys fstest.MapFS, opts Options) []string {
+	t.Helper()
+	opts.fsys = fsys
+	files, err := listFiles(".", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *files
+}
+
+func TestSortCreated(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"a.png": {ModTime: now.Add(2 * time.Minute)},
+		"b.png": {ModTime: now},
+		"c.png": {ModTime: now.Add(time.Minute)},
+		// the name breaks ties of equal times
+		"d.png": {ModTime: now},
+	}
+	if got, want := listTestFiles(t, fsys, Options{sort: sortCreated}), []string{"b.png", "d.png", "c.png", "a.png"}; !slices.Equal(got, want) {
+		t.Errorf("created: got %v, want %v", got, want)
+	}
+	if got, want := listTestFiles(t, fsys, Options{sort: sortName}), []string{"a.png", "b.png", "c.png", "d.png"}; !slices.Equal(got, want) {
+		t.Errorf("name: got %v, want %v", got, want)
+	}
+}
+
+func TestSortCreatedOnDisk(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPngs(t, dir, 2, 2, testRed, testGreen, testBlue)
+	// files are modified in the reverse order of their names
+	now := time.Now()
+	for n, name := range []string{"0003.png", "0002.png", "0001.png"} {
+		mtime := now.Add(time.Duration(n) * time.Second)
+		if err := os.Chtimes(filepath.Join(dir, name), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tt := range []struct {
+		sort string
+		want []string
+	}{
+		{sortCreated, []string{"0003.png", "0002.png", "0001.png"}},
+		{sortName, []string{"0001.png", "0002.png", "0003.png"}},
+	} {
+		files, err := listFiles(dir, Options{sort: tt.sort})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{}
+		for _, name := range tt.want {
+			want = append(want, filepath.Join(dir, name))
+		}
+		if !slices.Equal(*files, want) {
+			t.Errorf("%s: got %v, want %v", tt.sort, *files, want)
+		}
+	}
+}
+
+func TestSortNatural(t *testing.T) {
+	// the scene number comes first, the frame number is the last one
+	fsys := fstest.MapFS{
+		"render_scene2_0010_final.png": {},
+		"render_scene1_0002_final.png": {},
+		"render_scene3_0001_final.png": {},
+		"render_scene1_0100_final.png": {},
+		"cover.png":                    {},
+	}
+	for _, tt := range []struct {
+		number string
+		want   []string
+	}{
+		{"last", []string{"render_scene3_0001_final.png", "render_scene1_0002_final.png", "render_scene2_0010_final.png", "render_scene1_0100_final.png", "cover.png"}},
+		{"2", []string{"render_scene3_0001_final.png", "render_scene1_0002_final.png", "render_scene2_0010_final.png", "render_scene1_0100_final.png", "cover.png"}},
+		// the name breaks ties of the same scene
+		{"first", []string{"render_scene1_0002_final.png", "render_scene1_0100_final.png", "render_scene2_0010_final.png", "render_scene3_0001_final.png", "cover.png"}},
+		{"3", []string{"cover.png", "render_scene1_0002_final.png", "render_scene1_0100_final.png", "render_scene2_0010_final.png", "render_scene3_0001_final.png"}},
+	} {
+		opts := parseTestFlags(t, "-sort", "natural", "-sort-number", tt.number).opts
+		if got := listTestFiles(t, fsys, opts); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.number, got, tt.want)
+		}
+	}
+
+	// numbers longer than any integer still compare by value
+	big := fstest.MapFS{"f99999999999999999999999.png": {}, "f100000000000000000000000.png": {}, "f0000000000000000000000000001.png": {}}
+	if got, want := listTestFiles(t, big, Options{sort: sortNatural}), []string{"f0000000000000000000000000001.png", "f99999999999999999999999.png", "f100000000000000000000000.png"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, s := range []string{"0", "middle", "-1"} {
+		parseUsageError(t, "-sort-number", s)
+	}
+}
+
+func TestSortDesc(t *testing.T) {
+	now := time.Now()
+	fsys := fstest.MapFS{
+		"frame_10.png": {ModTime: now.Add(-3 * time.Minute)},
+		"frame_2.png":  {ModTime: now.Add(-1 * time.Minute)},
+		"frame_1.png":  {ModTime: now.Add(-2 * time.Minute)},
+		"list.txt":     {Data: []byte("frame_1.png\nframe_10.png\nframe_2.png\n")},
+	}
+	for _, tt := range []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"-sort", "natural", "-sort-desc"}, []string{"frame_10.png", "frame_2.png", "frame_1.png"}},
+		{[]string{"-sort", "name", "-sort-desc"}, []string{"frame_2.png", "frame_10.png", "frame_1.png"}},
+		{[]string{"-sort", "created", "-sort-desc"}, []string{"frame_2.png", "frame_1.png", "frame_10.png"}},
+		{[]string{"-sort", "natural"}, []string{"frame_1.png", "frame_2.png", "frame_10.png"}},
+	} {
+		if got := listTestFiles(t, fsys, parseTestFlags(t, tt.args...).opts); !slices.Equal(got, tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.args, got, tt.want)
+		}
+	}
+
+	// manifests keep the order of their lines
+	files, err := listFiles("list.txt", Options{fsys: fsys, sortDesc: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"frame_1.png", "frame_10.png", "frame_2.png"}; !slices.Equal(*files, want) {
+		t.Errorf("manifest: got %v, want %v", *files, want)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMinFrames(t *testing.T) {
+	// 4 images collapse into 2 frames
+	dir := t.TempDir()
+	writeTestPngs(t, dir, 16, 16, testRed, testRed, testBlue, testBlue)
+	files, err := listFiles(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out.gif")
+
+	err = BuildGif(context.Background(), files, out, Options{minFrames: 3})
+	if err == nil || !strings.Contains(err.Error(), "only 2 frame(s) left after merging equal images, at least 3 are needed") {
+		t.Fatalf("got %v, want a min-frames error", err)
+	}
+	if _, err := os.Stat(out); err == nil {
+		t.Error("output is written below -min-frames")
+	}
+	for _, n := range []int{0, 2} {
+		if err := BuildGif(context.Background(), files, out, Options{minFrames: n}); err != nil {
+			t.Errorf("-min-frames %d: %v", n, err)
+		}
+	}
+	// -no-dedup keeps enough frames
+	if err := BuildGif(context.Background(), files, out, Options{minFrames: 3, noDedup: true}); err != nil {
+		t.Errorf("-no-dedup: %v", err)
+	}
+
+	// no images fail early, whatever -min-frames is
+	empty := t.TempDir()
+	for _, n := range []int{0, 2} {
+		msg := gen(context.Background(), empty, filepath.Join(t.TempDir(), "out.gif"), Options{minFrames: n}, nil)().(resultMsg)
+		if msg.err == nil || msg.err.Error() != "no images found in "+empty {
+			t.Errorf("-min-frames %d: got %v, want an error about no images", n, msg.err)
+		}
+		if err := BuildGif(context.Background(), &[]string{}, out, Options{minFrames: n}); err == nil || !strings.Contains(err.Error(), "no images") {
+			t.Errorf("-min-frames %d: got %v, want an error about no images", n, err)
+		}
+	}
+
+	if cfg := parseTestFlags(t); cfg.opts.minFrames != 2 {
+		t.Errorf("got the default min frames %d, want 2", cfg.opts.minFrames)
+	}
+	parseUsageError(t, "-min-frames", "-1")
+}
+
+func TestStaticOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		err      []string
+		warnings int
+	}{
+		{"default min frames", Options{minFrames: 2}, []string{"identical", "-allow-static", "-min-frames 1"}, 0},
+		{"min frames 1", Options{minFrames: 1}, nil, 1},
+		{"allow static", Options{minFrames: 2, allowStatic: true}, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestPngs(t, dir, 16, 16, testRed, testRed, testRed)
+			files := []string{}
+			for _, n := range []string{"0001.png", "0002.png", "0003.png"} {
+				files = append(files, filepath.Join(dir, n))
+			}
+			out := filepath.Join(dir, "out.gif")
+			warnings := []string{}
+			opts := tt.opts
+			opts.warn = func(msg string) { warnings = append(warnings, msg) }
+
+			err := BuildGif(context.Background(), &files, out, opts)
+			if tt.err != nil {
+				if err == nil {
+					t.Fatal("no error")
+				}
+				for _, s := range tt.err {
+					if !strings.Contains(err.Error(), s) {
+						t.Errorf("error %q doesn't mention %s", err, s)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(warnings) != tt.warnings {
+				t.Errorf("got warnings %q, want %d", warnings, tt.warnings)
+			}
+			if g := decodeTestGif(t, out); len(g.Image) != 1 {
+				t.Errorf("got %d frames, want 1", len(g.Image))
+			}
+		})
+	}
+}
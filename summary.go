@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runSummary prints each frame the images of the path are merged into, with the number of images and their names,
+// without building anything. Frames are grouped as in a build with the same flags.
+func runSummary(cfg config, w io.Writer) error {
+	if cfg.path == "" {
+		return &usageError{fmt.Errorf("summary: -path is required")}
+	}
+	if cfg.opts.fromVideo || isVideo(cfg.path) || isWebp(cfg.path) || isGif(cfg.path) || isPdf(cfg.path) {
+		return &usageError{fmt.Errorf("summary: frames of videos, webp, gif and pdf files can't be summarized")}
+	}
+	files, err := listFiles(cfg.path, cfg.opts)
+	if err != nil {
+		return err
+	}
+	if len(*files) == 0 {
+		return fmt.Errorf("no images found in %s", cfg.path)
+	}
+	opts := cfg.opts
+	opts.warn = func(msg string) { fmt.Fprintf(os.Stderr, "%s %s\n", indicator(emojiWarning, cfg.ascii), msg) }
+	frames, err := readImages(context.Background(), files, opts)
+	if err != nil {
+		return err
+	}
+
+	// names are relative to the folder of the input, so the summary stays compact,
+	// images skipped by sampling are not merged into frames, so they are not counted
+	dir := inputFolder(cfg.path)
+	images := 0
+	for n, f := range frames {
+		images += len(f.sources)
+		names := make([]string, len(f.sources))
+		for i, s := range f.sources {
+			names[i] = s
+			if rel, err := filepath.Rel(dir, s); err == nil && !strings.HasPrefix(rel, "..") {
+				names[i] = rel
+			}
+		}
+		if _, err := fmt.Fprintf(w, "frame %d: %d image(s): %s\n", n+1, len(f.sources), strings.Join(names, ", ")); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "%d image(s) in %d unique frame(s)\n", images, len(frames))
+	return err
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// validateTemplate checks that the frame template has a single number verb, like frame%04d.png.
+func validateTemplate(template string) error {
+	if strings.Count(strings.ReplaceAll(template, "%%", ""), "%") != 1 || strings.Contains(fmt.Sprintf(template, 1), "%!") {
+		return fmt.Errorf("invalid template %q, use a file name with a single number verb, e.g. frame%%04d.png", template)
+	}
+	if filepath.Base(template) != template {
+		return fmt.Errorf("invalid template %q, it should be a file name in -path", template)
+	}
+	return nil
+}
+
+// templateFiles expands the frame template in the folder into paths of frames from the start to the end index.
+// A missing frame is an error, unless the on-gap mode warns about gaps or holds frames for them, then it's skipped.
+// 0 end takes frames until the first missing one.
+func templateFiles(dir string, opts Options) (*[]string, error) {
+	files := []string{}
+	for i := opts.templateStart; opts.templateEnd == 0 || i <= opts.templateEnd; i++ {
+		file := filepath.Join(dir, fmt.Sprintf(opts.template, i))
+		_, err := opts.stat(file)
+		if err == nil {
+			files = append(files, file)
+			continue
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, permissionError(dir, err)
+		}
+		if opts.templateEnd == 0 {
+			break
+		}
+		if opts.onGap != gapWarn && opts.onGap != gapHold {
+			return nil, &fileError{"read frame", file, fmt.Errorf("frame %d of the template is missing, use -on-gap warn or hold to skip missing frames", i)}
+		}
+	}
+	return &files, nil
+}
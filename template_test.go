@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestValidateTemplate(t *testing.T) {
+	for _, s := range []string{"frame%04d.png", "%d.jpg", "100%%_%03d.png"} {
+		if err := validateTemplate(s); err != nil {
+			t.Errorf("%s: %v", s, err)
+		}
+	}
+	for _, s := range []string{"frame.png", "f%d_%d.png", "f%s.png", "scene/f%04d.png", "100%%.png"} {
+		if err := validateTemplate(s); err == nil {
+			t.Errorf("%s: got no error", s)
+		}
+	}
+}
+
+func TestTemplateFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"frame0001.png": {},
+		"frame0002.png": {},
+		"frame0004.png": {},
+		"frame0005.png": {},
+		"frame0010.png": {},
+		"cover.png":     {},
+	}
+	for _, tt := range []struct {
+		args []string
+		want []string
+	}{
+		// frames until the first missing one
+		{nil, []string{"frame0001.png", "frame0002.png"}},
+		{[]string{"-start", "4"}, []string{"frame0004.png", "frame0005.png"}},
+		{[]string{"-start", "4", "-end", "5"}, []string{"frame0004.png", "frame0005.png"}},
+		{[]string{"-end", "5", "-on-gap", "warn"}, []string{"frame0001.png", "frame0002.png", "frame0004.png", "frame0005.png"}},
+		{[]string{"-start", "2", "-end", "10", "-on-gap", "hold"}, []string{"frame0002.png", "frame0004.png", "frame0005.png", "frame0010.png"}},
+	} {
+		opts := parseTestFlags(t, append([]string{"-template", "frame%04d.png"}, tt.args...)...).opts
+		opts.fsys = fsys
+		files, err := listFiles(".", opts)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		if !slices.Equal(*files, tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.args, *files, tt.want)
+		}
+	}
+
+	// a missing frame in the range is an error by default
+	_, err := listFiles(".", Options{fsys: fsys, template: "frame%04d.png", templateStart: 1, templateEnd: 5})
+	fe := &fileError{}
+	if !errors.As(err, &fe) || fe.path != "frame0003.png" || !strings.Contains(err.Error(), "frame 3 of the template is missing") {
+		t.Errorf("got %v, want an error about the missing frame 3", err)
+	}
+
+	parseUsageError(t, "-template", "frame.png")
+	parseUsageError(t, "-template", "frame%04d.png", "-start", "-1")
+	parseUsageError(t, "-template", "frame%04d.png", "-start", "5", "-end", "2")
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// environment variables with the defaults of the spinner and color flags.
+const (
+	envSpinner = "PNG2GIF_SPINNER"
+	envColor   = "PNG2GIF_COLOR"
+)
+
+// defaultSpinner is the name of the spinner used when it's not set.
+const defaultSpinner = "minidot"
+
+// spinners are the spinner styles of bubbles by their names in the spinner flag.
+var spinners = map[string]spinner.Spinner{
+	"line":      spinner.Line,
+	"dot":       spinner.Dot,
+	"minidot":   spinner.MiniDot,
+	"jump":      spinner.Jump,
+	"pulse":     spinner.Pulse,
+	"points":    spinner.Points,
+	"globe":     spinner.Globe,
+	"moon":      spinner.Moon,
+	"monkey":    spinner.Monkey,
+	"meter":     spinner.Meter,
+	"hamburger": spinner.Hamburger,
+}
+
+// theme is the look of the UI.
+// @property {spinner.Spinner} spinner - The spinner shown while images are processed.
+// @property {lipgloss.Color} accent - The color of input labels and the spinner.
+type theme struct {
+	spinner spinner.Spinner
+	accent  lipgloss.Color
+}
+
+// parseTheme parses the spinner name and the accent color,
+// the color is either #RRGGBB or an ANSI color number from 0 to 255.
+func parseTheme(spinnerName, accent string) (theme, error) {
+	sp, ok := spinners[strings.ToLower(spinnerName)]
+	if !ok {
+		names := make([]string, 0, len(spinners))
+		for name := range spinners {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return theme{}, fmt.Errorf("invalid spinner %q, use one of %s", spinnerName, strings.Join(names, ", "))
+	}
+	if n, err := strconv.Atoi(accent); err == nil && n >= 0 && n <= 255 {
+		return theme{sp, lipgloss.Color(accent)}, nil
+	}
+	if _, err := parseHexColor(accent); err != nil || !strings.HasPrefix(accent, "#") || len(accent) != 7 {
+		return theme{}, fmt.Errorf("invalid color %q, use #RRGGBB or an ANSI color from 0 to 255", accent)
+	}
+	return theme{sp, lipgloss.Color(accent)}, nil
+}
+
+// envOr returns the value of the environment variable, or the fallback if it's not set.
+func envOr(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return fallback
+}
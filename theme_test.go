@@ -0,0 +1,76 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// spinnersEqual compares spinners by their frames and frame rates.
+func spinnersEqual(a, b spinner.Spinner) bool {
+	return slices.Equal(a.Frames, b.Frames) && a.FPS == b.FPS
+}
+
+func TestParseTheme(t *testing.T) {
+	for _, tt := range []struct {
+		spinner, color string
+		want           spinner.Spinner
+	}{
+		{"line", "206", spinner.Line},
+		{"Dot", "#ff8800", spinner.Dot},
+		{"minidot", "0", spinner.MiniDot},
+		{"GLOBE", "255", spinner.Globe},
+		{"hamburger", "#00AAFF", spinner.Hamburger},
+	} {
+		th, err := parseTheme(tt.spinner, tt.color)
+		if err != nil {
+			t.Fatalf("%s %s: %v", tt.spinner, tt.color, err)
+		}
+		if !spinnersEqual(th.spinner, tt.want) || th.accent != lipgloss.Color(tt.color) {
+			t.Errorf("%s %s: got %v with color %q", tt.spinner, tt.color, th.spinner.Frames, th.accent)
+		}
+	}
+
+	for _, tt := range []struct{ spinner, color, want string }{
+		{"dots", "206", "invalid spinner \"dots\", use one of dot, globe"},
+		{"dot", "256", "invalid color \"256\""},
+		{"dot", "-1", "invalid color"},
+		{"dot", "ff8800", "invalid color"},
+		{"dot", "#f80", "invalid color"},
+		{"dot", "#gg8800", "invalid color"},
+		{"dot", "pink", "invalid color"},
+	} {
+		if _, err := parseTheme(tt.spinner, tt.color); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s %s: got %v, want an error with %q", tt.spinner, tt.color, err, tt.want)
+		}
+	}
+}
+
+func TestThemeFlags(t *testing.T) {
+	cfg := parseTestFlags(t)
+	if !spinnersEqual(cfg.theme.spinner, spinner.MiniDot) || cfg.theme.accent != hotPink {
+		t.Errorf("got the default spinner %v with color %q, want minidot and %q", cfg.theme.spinner.Frames, cfg.theme.accent, hotPink)
+	}
+
+	// environment variables set the defaults, flags override them
+	t.Setenv(envSpinner, "moon")
+	t.Setenv(envColor, "#123456")
+	cfg = parseTestFlags(t)
+	if !spinnersEqual(cfg.theme.spinner, spinner.Moon) || cfg.theme.accent != "#123456" {
+		t.Errorf("got spinner %v with color %q from the environment", cfg.theme.spinner.Frames, cfg.theme.accent)
+	}
+	cfg = parseTestFlags(t, "-spinner", "pulse", "-color", "99")
+	if !spinnersEqual(cfg.theme.spinner, spinner.Pulse) || cfg.theme.accent != "99" {
+		t.Errorf("got spinner %v with color %q from flags", cfg.theme.spinner.Frames, cfg.theme.accent)
+	}
+	parseUsageError(t, "-spinner", "wheel")
+	parseUsageError(t, "-color", "#12345")
+
+	m := initialModel(Options{}, cfg.theme)
+	if !spinnersEqual(m.spinner.Spinner, spinner.Pulse) || m.spinner.Style.GetForeground() != lipgloss.Color("99") {
+		t.Errorf("the model has spinner %v with color %v", m.spinner.Spinner.Frames, m.spinner.Style.GetForeground())
+	}
+}
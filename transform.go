@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// autoDownscaleSize is the max width and height of frames with the auto downscale flag.
+const autoDownscaleSize = 1000
+
+// transformImage applies the transform stage to a decoded source image before it's compared with others.
+// n is the index of the image in the source, file is its path.
+// Returns whether the frame was downscaled to fit the max dimension too.
+func transformImage(img image.Image, n int, file string, opts Options) (image.Image, bool) {
+	if opts.size != (image.Point{}) {
+		img = containImage(img, opts.size.X, opts.size.Y, opts.padColor, opts.linear)
+	}
+	if opts.scale > 0 && opts.scale != 1 {
+		img = scaleImage(img, opts.scale, opts.linear)
+	}
+	// some players choke on huge canvases, so oversized frames are scaled down to fit.
+	downscaled := false
+	if s := img.Bounds().Size(); opts.maxDimension > 0 && (s.X > opts.maxDimension || s.Y > opts.maxDimension) {
+		img = scaleImage(img, math.Min(float64(opts.maxDimension)/float64(s.X), float64(opts.maxDimension)/float64(s.Y)), opts.linear)
+		downscaled = true
+	}
+	if opts.overlayFrameNumber || opts.overlayFilename {
+		label := ""
+		if opts.overlayFrameNumber {
+			label = fmt.Sprintf("#%d", n)
+		}
+		if opts.overlayFilename {
+			if label != "" {
+				label += " "
+			}
+			label += filepath.Base(file)
+		}
+		img = drawLabel(img, label)
+	}
+	if a, ok := findAnnotation(opts.annotations, n, file); ok {
+		img = drawText(img, a.text, a.position, a.color, a.background)
+	}
+	return img, downscaled
+}
+
+// drawLabel draws white text on a black box in the top left corner of a copy of the image.
+func drawLabel(img image.Image, label string) image.Image {
+	return drawText(img, label, posTopLeft, color.White, color.Black)
+}
+
+// scaleImage resizes the image by the factor keeping its proportions, frames are at least 1×1.
+func scaleImage(img image.Image, factor float64, linear bool) image.Image {
+	b := img.Bounds()
+	w, h := int(float64(b.Dx())*factor+0.5), int(float64(b.Dy())*factor+0.5)
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return resizeImage(img, w, h, linear)
+}
+
+// containImage scales the image to fit into the width and height keeping its aspect ratio,
+// and centers it on a canvas filled with the pad color, nil pads with transparent pixels.
+func containImage(img image.Image, w, h int, pad color.Color, linear bool) image.Image {
+	b := img.Bounds()
+	factor := math.Min(float64(w)/float64(b.Dx()), float64(h)/float64(b.Dy()))
+	scaled := scaleImage(img, factor, linear)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	if pad != nil {
+		draw.Draw(dst, dst.Rect, image.NewUniform(pad), image.Point{}, draw.Src)
+	}
+	sb := scaled.Bounds()
+	offset := image.Pt((w-sb.Dx())/2, (h-sb.Dy())/2)
+	draw.Draw(dst, sb.Add(offset).Intersect(dst.Rect), scaled, sb.Min, draw.Over)
+	return dst
+}
+
+// parseSize parses a size in WxH format, e.g. 640x480.
+func parseSize(s string) (image.Point, error) {
+	var w, h int
+	if _, err := fmt.Sscanf(strings.ToLower(s), "%dx%d", &w, &h); err != nil || w < 1 || h < 1 {
+		return image.Point{}, fmt.Errorf("invalid size %q, use WxH, e.g. 640x480", s)
+	}
+	return image.Pt(w, h), nil
+}
+
+// parseScale parses a percentage of the source size, e.g. 50%, the % sign is optional.
+func parseScale(s string) (float64, error) {
+	p, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil || p <= 0 {
+		return 0, fmt.Errorf("invalid scale %q, use a percentage, e.g. 50%%", s)
+	}
+	return p / 100, nil
+}
+
+// resizeImage resizes the image to the width and height, in linear light if linear is set,
+// so fine details like checkerboards don't get darker.
+func resizeImage(img image.Image, w, h int, linear bool) image.Image {
+	if linear {
+		src := toLinear(img)
+		dst := image.NewRGBA64(image.Rect(0, 0, w, h))
+		xdraw.CatmullRom.Scale(dst, dst.Rect, src, src.Bounds(), xdraw.Src, nil)
+		return fromLinear(dst)
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Rect, img, img.Bounds(), xdraw.Src, nil)
+	return dst
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"image"
+	"slices"
+	"testing"
+)
+
+// changedArea returns the bounds of pixels that differ between the images of the same size.
+func changedArea(a, b image.Image) image.Rectangle {
+	area := image.Rectangle{}
+	r := a.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if !colorsEqual(a.At(x, y), b.At(x, y)) {
+				area = area.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return area
+}
+
+func TestOverlay(t *testing.T) {
+	src := testFrame(120, 60, testBlue)
+	number, _ := transformImage(src, 7, "dir/frame_0007.png", Options{overlayFrameNumber: true})
+	area := changedArea(src, number)
+	if area.Empty() {
+		t.Fatal("the frame number overlay doesn't change pixels")
+	}
+	// the label is in the top left corner
+	if area.Min.X > 2 || area.Min.Y > 2 || area.Max.X > 60 || area.Max.Y > 30 {
+		t.Errorf("the overlay is at %v, want it in the top left corner", area)
+	}
+	if !colorsEqual(number.At(119, 59), testBlue) {
+		t.Error("pixels outside the overlay are changed")
+	}
+
+	// the file name makes the label longer
+	both, _ := transformImage(src, 7, "dir/frame_0007.png", Options{overlayFrameNumber: true, overlayFilename: true})
+	if wide := changedArea(src, both); wide.Dx() <= area.Dx() {
+		t.Errorf("the label with the file name is %v, the one of the number is %v", wide, area)
+	}
+	// other frames get other labels
+	other, _ := transformImage(src, 8, "dir/frame_0008.png", Options{overlayFrameNumber: true})
+	if changedArea(number, other).Empty() {
+		t.Error("labels of frames 7 and 8 are equal")
+	}
+	// the source image isn't changed
+	if !colorsEqual(src.At(3, 3), testBlue) {
+		t.Error("the source image is changed")
+	}
+	if same, _ := transformImage(src, 7, "a.png", Options{}); same != image.Image(src) {
+		t.Error("the image is copied without transforms")
+	}
+}
+
+func TestContainPadding(t *testing.T) {
+	// a wide image is letterboxed at the top and the bottom, a tall one at the sides
+	for _, tt := range []struct {
+		src         image.Image
+		pad, inside image.Point
+	}{
+		{testFrame(40, 20, testRed), image.Pt(10, 2), image.Pt(10, 10)},
+		{testFrame(10, 40, testRed), image.Pt(2, 10), image.Pt(10, 10)},
+	} {
+		img := containImage(tt.src, 20, 20, testBlue, false)
+		if img.Bounds() != image.Rect(0, 0, 20, 20) {
+			t.Fatalf("got bounds %v, want 20x20", img.Bounds())
+		}
+		if !colorsEqual(img.At(tt.pad.X, tt.pad.Y), testBlue) {
+			t.Errorf("got padding %v, want %v", img.At(tt.pad.X, tt.pad.Y), testBlue)
+		}
+		if !colorsEqual(img.At(tt.inside.X, tt.inside.Y), testRed) {
+			t.Errorf("got %v inside, want %v", img.At(tt.inside.X, tt.inside.Y), testRed)
+		}
+		// without a pad color the padding is transparent
+		if _, _, _, a := containImage(tt.src, 20, 20, nil, false).At(tt.pad.X, tt.pad.Y).RGBA(); a != 0 {
+			t.Errorf("got padding alpha %d, want transparent", a)
+		}
+	}
+
+	cfg := parseTestFlags(t, "-size", "20x20", "-pad-color", "#00ff00", "-no-dedup")
+	if cfg.opts.size != image.Pt(20, 20) || !colorsEqual(cfg.opts.padColor, testGreen) {
+		t.Errorf("got size %v and pad color %v", cfg.opts.size, cfg.opts.padColor)
+	}
+	g := buildTestGif(t, cfg.opts, testFrame(40, 20, testRed), testFrame(40, 20, testBlue))
+	if !colorsEqual(g.Image[0].At(0, 0), testGreen) || !colorsEqual(g.Image[0].At(10, 10), testRed) {
+		t.Errorf("got %v padding and %v inside the gif", g.Image[0].At(0, 0), g.Image[0].At(10, 10))
+	}
+	parseUsageError(t, "-size", "20")
+	parseUsageError(t, "-pad-color", "green")
+}
+
+func TestScale(t *testing.T) {
+	for _, tt := range []struct {
+		scale string
+		want  image.Rectangle
+	}{
+		{"50%", image.Rect(0, 0, 20, 12)},
+		{"200%", image.Rect(0, 0, 80, 48)},
+		{"100", image.Rect(0, 0, 40, 24)},
+	} {
+		cfg := parseTestFlags(t, "-scale", tt.scale, "-no-dedup")
+		g := buildTestGif(t, cfg.opts, testPattern(40, 24, 0), testPattern(40, 24, 60))
+		for n, img := range g.Image {
+			if img.Bounds() != tt.want {
+				t.Errorf("%s: frame %d has bounds %v, want %v", tt.scale, n, img.Bounds(), tt.want)
+			}
+		}
+		if g.Config.Width != tt.want.Dx() || g.Config.Height != tt.want.Dy() {
+			t.Errorf("%s: got the gif size %dx%d, want %v", tt.scale, g.Config.Width, g.Config.Height, tt.want.Size())
+		}
+	}
+	// images of different sizes are scaled each
+	frames := readTestImages(t, Options{scale: 0.5}, testPattern(40, 24, 0), testPattern(20, 60, 0))
+	if frames[0].img.Bounds().Size() != image.Pt(20, 12) || frames[1].img.Bounds().Size() != image.Pt(10, 30) {
+		t.Errorf("got sizes %v and %v, want 20x12 and 10x30", frames[0].img.Bounds().Size(), frames[1].img.Bounds().Size())
+	}
+	for _, s := range []string{"0%", "-50%", "half"} {
+		parseUsageError(t, "-scale", s)
+	}
+	parseUsageError(t, "-scale", "50%", "-size", "20x20")
+}
+
+func TestAutoDownscale(t *testing.T) {
+	warnings := []string{}
+	opts := parseTestFlags(t, "-auto-downscale", "-no-dedup").opts
+	opts.warn = func(msg string) { warnings = append(warnings, msg) }
+	frames := readTestImages(t, opts, testPattern(1200, 600, 0), testPattern(800, 1600, 0), testPattern(300, 200, 0))
+	for n, want := range []image.Point{{1000, 500}, {500, 1000}, {300, 200}} {
+		if got := frames[n].img.Bounds().Size(); got != want {
+			t.Errorf("frame %d: got %v, want %v", n, got, want)
+		}
+	}
+	if want := []string{"images of 1200×600 are larger than 1000px, frames are scaled down to fit"}; !slices.Equal(warnings, want) {
+		t.Errorf("got warnings %q, want %q", warnings, want)
+	}
+
+	// frames that fit aren't touched or warned about
+	warnings = nil
+	frames = readTestImages(t, opts, testPattern(1000, 40, 0))
+	if frames[0].img.Bounds().Size() != image.Pt(1000, 40) || len(warnings) != 0 {
+		t.Errorf("got %v and warnings %q for a frame that fits", frames[0].img.Bounds().Size(), warnings)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+// trimStatic drops leading and trailing runs of similar frames, like the still start and end of a screen recording,
+// so the animation starts and ends on motion. One frame of each run is kept for the time of a single source image.
+// Frames in between are kept as they are, all similar frames are kept too, as there is no motion to trim to.
+func trimStatic(images []imgWithDelay, opts Options) []imgWithDelay {
+	// no frames or a single one have nothing to trim
+	if len(images) < 2 {
+		return images
+	}
+	th := opts.similarity()
+	head := 0
+	for head+1 < len(images) && FramesSimilar(images[0].img, images[head+1].img, th) {
+		head++
+	}
+	if head == len(images)-1 {
+		return images
+	}
+	tail := len(images) - 1
+	for tail-1 > head && FramesSimilar(images[len(images)-1].img, images[tail-1].img, th) {
+		tail--
+	}
+
+	// merged frames stand for several images, so trimmed images are counted by their sources
+	trimmedHead, trimmedTail := -1, -1
+	for _, im := range images[:head+1] {
+		trimmedHead += len(im.sources)
+	}
+	for _, im := range images[tail:] {
+		trimmedTail += len(im.sources)
+	}
+
+	first, last := images[0], images[tail]
+	first.delay, first.sources = sourceDelay(first), firstSource(first.sources)
+	last.delay, last.sources = sourceDelay(last), firstSource(last.sources)
+	trimmed := append([]imgWithDelay{first}, images[head+1:tail]...)
+	trimmed = append(trimmed, last)
+	if trimmedHead > 0 || trimmedTail > 0 {
+		opts.logger().Info("static frames trimmed", "head", trimmedHead, "tail", trimmedTail)
+	}
+	return trimmed
+}
+
+// firstSource returns the first of the sources of a frame, the rest are trimmed.
+func firstSource(sources []string) []string {
+	if len(sources) == 0 {
+		return sources
+	}
+	return sources[:1]
+}
+
+// sourceDelay returns the delay of a single source image the frame stands for, at least 1.
+func sourceDelay(frame imgWithDelay) int {
+	if len(frame.sources) == 0 || frame.delay < len(frame.sources) {
+		return 1
+	}
+	return frame.delay / len(frame.sources)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"image"
+	"slices"
+	"testing"
+)
+
+func TestTrimStatic(t *testing.T) {
+	// frames of the patterns, each standing for a single source image
+	patterns := func(ds ...int) []imgWithDelay {
+		images := []image.Image{}
+		for _, d := range ds {
+			images = append(images, testPattern(32, 32, d))
+		}
+		return framesOf(images...)
+	}
+	for _, tt := range []struct {
+		name       string
+		frames     []imgWithDelay
+		want       []string
+		wantDelays []int
+	}{
+		{"head and tail", patterns(0, 0, 0, 60, 120, 180, 180), []string{"a", "d", "e", "f"}, []int{1, 1, 1, 1}},
+		{"head only", patterns(0, 0, 60, 120), []string{"a", "c", "d"}, []int{1, 1, 1}},
+		{"motion", patterns(0, 60, 120), []string{"a", "b", "c"}, []int{1, 1, 1}},
+		{"still", patterns(0, 0, 0), []string{"a", "b", "c"}, []int{1, 1, 1}},
+		{"single", patterns(0), []string{"a"}, []int{1}},
+		{"empty", nil, []string{}, []int{}},
+		// merged frames keep the time of one source image
+		{"merged", []imgWithDelay{
+			{img: testPattern(32, 32, 0), delay: 6, sources: []string{"a", "b", "c"}},
+			{img: testPattern(32, 32, 60), delay: 2, sources: []string{"d"}},
+			{img: testPattern(32, 32, 120), delay: 4, sources: []string{"e", "f"}},
+		}, []string{"a", "d", "e"}, []int{2, 2, 2}},
+	} {
+		got := trimStatic(tt.frames, Options{})
+		sources := []string{}
+		for _, f := range got {
+			sources = append(sources, f.sources...)
+		}
+		if !slices.Equal(sources, tt.want) || !slices.Equal(frameDelaysOf(got), tt.wantDelays) {
+			t.Errorf("%s: got sources %v with delays %v, want %v with %v", tt.name, sources, frameDelaysOf(got), tt.want, tt.wantDelays)
+		}
+	}
+}
+
+func TestTrimStaticGif(t *testing.T) {
+	images := []image.Image{}
+	for _, d := range []int{0, 0, 0, 60, 120, 180, 180} {
+		images = append(images, testPattern(32, 32, d))
+	}
+	for _, tt := range []struct {
+		args []string
+		want []int
+	}{
+		{[]string{"-fps", "25"}, []int{12, 4, 4, 8}},
+		{[]string{"-fps", "25", "-trim-static"}, []int{4, 4, 4, 4}},
+	} {
+		g := buildTestGif(t, parseTestFlags(t, tt.args...).opts, images...)
+		if !slices.Equal(g.Delay, tt.want) {
+			t.Errorf("%v: got delays %v, want %v", tt.args, g.Delay, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultURLTimeout is the max duration of fetching an image by its URL, if it's not set in options.
+const defaultURLTimeout = 30 * time.Second
+
+// isURL checks if the path is an http or https URL of an image.
+func isURL(p string) bool {
+	return strings.HasPrefix(p, "http://") || strings.HasPrefix(p, "https://")
+}
+
+// fetchTimeout returns the max duration of fetching an image by its URL.
+func (o Options) fetchTimeout() time.Duration {
+	if o.httpTimeout > 0 {
+		return o.httpTimeout
+	}
+	return defaultURLTimeout
+}
+
+// openURL fetches the URL and returns the body of the response as a file, it's read while it's decoded.
+func openURL(url string, opts Options) (fs.File, error) {
+	client := http.Client{Timeout: opts.fetchTimeout()}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	return &urlFile{resp.Body, url, resp.ContentLength}, nil
+}
+
+// urlFile is the body of a response to a request of an image by its URL.
+// @property body - The body of the response.
+// @property {string} url - The URL of the image.
+// @property {int64} size - The length of the body, -1 if it's unknown.
+type urlFile struct {
+	body io.ReadCloser
+	url  string
+	size int64
+}
+
+func (f *urlFile) Read(p []byte) (int, error) {
+	return f.body.Read(p)
+}
+
+func (f *urlFile) Close() error {
+	return f.body.Close()
+}
+
+func (f *urlFile) Stat() (fs.FileInfo, error) {
+	return urlFileInfo{f}, nil
+}
+
+// urlFileInfo describes an image fetched by its URL, it's named after the last element of the URL path.
+type urlFileInfo struct {
+	f *urlFile
+}
+
+func (i urlFileInfo) Name() string       { return i.f.url[strings.LastIndex(i.f.url, "/")+1:] }
+func (i urlFileInfo) Size() int64        { return i.f.size }
+func (i urlFileInfo) Mode() fs.FileMode  { return 0o444 }
+func (i urlFileInfo) ModTime() time.Time { return time.Time{} }
+func (i urlFileInfo) IsDir() bool        { return false }
+func (i urlFileInfo) Sys() any           { return nil }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveTestPngs serves red, green and blue pngs named 0001.png to 0003.png.
+func serveTestPngs(t *testing.T) *httptest.Server {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestPngs(t, dir, 8, 8, testRed, testGreen, testBlue)
+	srv := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// checkFrameColors checks the colors of the first pixels of frames of the gif.
+func checkFrameColors(t *testing.T, path string, want ...color.Color) {
+	t.Helper()
+	g := decodeTestGif(t, path)
+	if len(g.Image) != len(want) {
+		t.Fatalf("got %d frames, want %d", len(g.Image), len(want))
+	}
+	for n, img := range g.Image {
+		if c := img.At(0, 0); !colorsEqual(c, want[n]) {
+			t.Errorf("frame %d: got %v, want %v", n, c, want[n])
+		}
+	}
+}
+
+func TestURLManifest(t *testing.T) {
+	srv := serveTestPngs(t)
+	dir := t.TempDir()
+	writeTestPngs(t, dir, 8, 8, color.RGBA{255, 255, 255, 255})
+	manifest := filepath.Join(dir, "list.txt")
+	lines := srv.URL + "/0003.png\n0001.png\n" + srv.URL + "/0001.png\n" + srv.URL + "/0002.png\n"
+	if err := os.WriteFile(manifest, []byte(lines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// urls and local paths are read in the order of lines
+	out := filepath.Join(t.TempDir(), "out.gif")
+	msg := gen(context.Background(), manifest, out, Options{noDedup: true}, nil)().(resultMsg)
+	if msg.err != nil {
+		t.Fatal(msg.err)
+	}
+	checkFrameColors(t, out, testBlue, color.RGBA{255, 255, 255, 255}, testRed, testGreen)
+}
+
+func TestURLStdin(t *testing.T) {
+	srv := serveTestPngs(t)
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteString(srv.URL + "/0002.png\n" + srv.URL + "/0001.png\n"); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() { os.Stdin = stdin })
+
+	cfg := parseTestFlags(t, "-stdin")
+	if cfg.path != stdinPath {
+		t.Fatalf("got path %q, want the standard input", cfg.path)
+	}
+	out := filepath.Join(t.TempDir(), "out.gif")
+	msg := gen(context.Background(), cfg.path, out, Options{noDedup: true}, nil)().(resultMsg)
+	if msg.err != nil {
+		t.Fatal(msg.err)
+	}
+	checkFrameColors(t, out, testGreen, testRed)
+}
+
+func TestURLErrors(t *testing.T) {
+	srv := serveTestPngs(t)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	t.Cleanup(slow.Close)
+
+	for _, tt := range []struct {
+		url  string
+		opts Options
+		want string
+	}{
+		{srv.URL + "/missing.png", Options{}, "unexpected response status: 404"},
+		{slow.URL + "/a.png", Options{httpTimeout: 50 * time.Millisecond}, "Timeout"},
+	} {
+		files := []string{srv.URL + "/0001.png", tt.url}
+		err := BuildGif(context.Background(), &files, filepath.Join(t.TempDir(), "out.gif"), tt.opts)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error with %q", tt.url, err, tt.want)
+		}
+	}
+
+	parseUsageError(t, "-stdin", "-path", "frames")
+	parseUsageError(t, "-http-timeout", "-1s")
+	if d := (Options{}).fetchTimeout(); d != defaultURLTimeout {
+		t.Errorf("got the default timeout %v, want %v", d, defaultURLTimeout)
+	}
+}
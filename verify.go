@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"image/gif"
+	"io"
+)
+
+// verifyGif decodes the gif written to path fully and checks the number of its frames, 0 frames skips the check.
+func verifyGif(r io.Reader, path string, frames int) error {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return &fileError{"verify gif", path, err}
+	}
+	if frames > 0 && len(g.Image) != frames {
+		return &fileError{"verify gif", path, fmt.Errorf("%d frames decoded, %d expected", len(g.Image), frames)}
+	}
+	return nil
+}
+
+// expectedFrames returns the number of frames of the gif encoded from the images,
+// frames longer than the max delay are split. 0 if it's not known before encoding, as frames are dropped to fit the target size.
+func expectedFrames(images []imgWithDelay, delay int, opts Options) int {
+	if opts.targetSize > 0 {
+		return 0
+	}
+	frames, _ := splitDelays(images, frameDelays(repetitions(&images), delay, opts), opts.maxDelay)
+	return len(frames)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestVerifyWithCreateFactory(t *testing.T) {
+	files := memFiles{}
+	opts := Options{verify: true, create: files.create}
+	if err := writeOutput(context.Background(), testFrames(8, 8, testRed, testGreen, testBlue), 2, "out.gif", opts); err != nil {
+		t.Fatal(err)
+	}
+	f := files["out.gif"]
+	if f == nil || f.Len() == 0 || !f.closed {
+		t.Fatal("gif isn't written with the create factory")
+	}
+	if err := verifyGif(bytes.NewReader(f.Bytes()), "out.gif", 3); err != nil {
+		t.Errorf("written gif: %v", err)
+	}
+}
+
+func TestVerifyGif(t *testing.T) {
+	b := bytes.Buffer{}
+	if err := encodeTo(context.Background(), &b, formatGif, testFrames(8, 8, testRed, testBlue), 2, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyGif(bytes.NewReader(b.Bytes()), "a.gif", 2); err != nil {
+		t.Errorf("valid gif: %v", err)
+	}
+	if err := verifyGif(bytes.NewReader(b.Bytes()), "a.gif", 0); err != nil {
+		t.Errorf("valid gif without a frame count: %v", err)
+	}
+	if err := verifyGif(bytes.NewReader(b.Bytes()), "a.gif", 3); err == nil {
+		t.Error("no error for a missing frame")
+	}
+	if err := verifyGif(bytes.NewReader(b.Bytes()[:b.Len()/2]), "a.gif", 2); err == nil {
+		t.Error("no error for a truncated gif")
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// videoExts are the extensions of files that are treated as videos.
+var videoExts = map[string]bool{
+	".mp4":  true,
+	".mov":  true,
+	".m4v":  true,
+	".mkv":  true,
+	".webm": true,
+	".avi":  true,
+}
+
+// isVideo checks if the path points to a video file by its extension.
+func isVideo(path string) bool {
+	return videoExts[strings.ToLower(filepath.Ext(path))]
+}
+
+// findFfmpeg returns the path to the ffmpeg binary or a clear error if it's not installed.
+func findFfmpeg() (string, error) {
+	bin, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg is required to work with videos, install it and make sure it's in PATH: %w", err)
+	}
+	return bin, nil
+}
+
+// webmArgs are the ffmpeg arguments to encode a webm with VP9 keeping transparency.
+var webmArgs = []string{"-c:v", "libvpx-vp9", "-pix_fmt", "yuva420p", "-crf", "32", "-b:v", "0", "-auto-alt-ref", "0", "-f", "webm"}
+
+// encodeVideo pipes frames as raw rgba to ffmpeg, which encodes them with the codec args and writes the video to w.
+// Videos have a constant frame rate, so frames are repeated to keep their delays, delay is in 100ths of a second per source image.
+func encodeVideo(ctx context.Context, w io.Writer, images *[]imgWithDelay, delay int, args []string, opts Options) error {
+	bin, err := findFfmpeg()
+	if err != nil {
+		return err
+	}
+	if len(*images) == 0 {
+		return fmt.Errorf("no frames to encode")
+	}
+
+	reps := make([]int, len(*images))
+	for n, im := range *images {
+		reps[n] = im.delay
+	}
+	delays := frameDelays(reps, delay, opts)
+
+	size := (*images)[0].img.Bounds().Size()
+	stderr := bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, bin, append([]string{
+		"-hide_banner", "-loglevel", "error",
+		"-f", "rawvideo", "-pix_fmt", "rgba",
+		"-s", fmt.Sprintf("%dx%d", size.X, size.Y),
+		"-framerate", fmt.Sprintf("100/%d", delay),
+		"-i", "pipe:0",
+	}, append(append([]string{}, args...), "pipe:1")...)...)
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// frames of other sizes are drawn at the top left corner of the first one
+	frame := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+	var writeErr error
+	for n, im := range *images {
+		draw.Draw(frame, frame.Rect, image.Transparent, image.Point{}, draw.Src)
+		draw.Draw(frame, frame.Rect, im.img, im.img.Bounds().Min, draw.Src)
+		repeat := int(math.Max(1, math.Round(float64(delays[n])/float64(delay))))
+		for i := 0; i < repeat && writeErr == nil; i++ {
+			_, writeErr = stdin.Write(frame.Pix)
+		}
+		if writeErr != nil {
+			break
+		}
+	}
+	stdin.Close()
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("failed to encode video: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return writeErr
+}
+
+// extractVideoFrames extracts frames from a video with ffmpeg at the fps rate into a temporary folder.
+// The caller should remove the folder when it's done with the frames.
+func extractVideoFrames(path string, fps int) (string, error) {
+	bin, err := findFfmpeg()
+	if err != nil {
+		return "", err
+	}
+	if fps <= 0 {
+		fps = 30
+	}
+
+	dir, err := os.MkdirTemp("", "png2gif-frames-")
+	if err != nil {
+		return "", err
+	}
+
+	stderr := bytes.Buffer{}
+	cmd := exec.Command(bin,
+		"-hide_banner", "-loglevel", "error",
+		"-i", path,
+		"-vf", fmt.Sprintf("fps=%d", fps),
+		filepath.Join(dir, "frame_%06d.png"),
+	)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to extract frames from video (%s): %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	return dir, nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsVideo(t *testing.T) {
+	for path, want := range map[string]bool{"a.mp4": true, "b.MOV": true, "c.webm": true, "d.gif": false, "frames": false} {
+		if got := isVideo(path); got != want {
+			t.Errorf("%s: got %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestExtractVideoFramesWithoutFfmpeg(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	_, err := extractVideoFrames("a.mp4", 10)
+	if err == nil || !strings.Contains(err.Error(), "ffmpeg is required") {
+		t.Errorf("got %v, want an error about missing ffmpeg", err)
+	}
+}
+
+func TestExtractedListOptions(t *testing.T) {
+	opts := Options{
+		sort:     sortCreated,
+		exts:     map[string]bool{".jpg": true},
+		since:    time.Now(),
+		until:    time.Now(),
+		template: "img_%03d.png",
+		fsys:     os.DirFS("."),
+	}
+	got := extractedListOptions(opts)
+	if got.sort != sortName || got.exts != nil || !got.since.IsZero() || !got.until.IsZero() || got.template != "" || got.fsys != nil {
+		t.Errorf("filters of source files are kept: %+v", got)
+	}
+}
+
+// TestFramesFromVideo builds a gif of frames extracted by a stand-in for ffmpeg,
+// which saves its arguments and copies the images of a folder to the output pattern.
+func TestFramesFromVideo(t *testing.T) {
+	frames := t.TempDir()
+	writeTestImages(t, frames, testFrame(8, 8, testRed), testFrame(8, 8, testGreen), testFrame(8, 8, testBlue))
+	args := filepath.Join(t.TempDir(), "args")
+	fakeCommand(t, "ffmpeg", fmt.Sprintf("echo \"$@\" > %s\nfor f in %s/*; do cp \"$f\" \"$(dirname \"$8\")/frame_$(basename \"$f\")\"; done\n", args, frames))
+
+	for _, tt := range []struct {
+		path string
+		opts Options
+	}{
+		{"in.mp4", Options{fps: 10}},
+		// videos without a known extension need the flag
+		{"in.bin", parseTestFlags(t, "-frames-from-video", "-fps", "10").opts},
+	} {
+		out := filepath.Join(t.TempDir(), "out.gif")
+		msg := gen(context.Background(), filepath.Join(t.TempDir(), tt.path), out, tt.opts, nil)().(resultMsg)
+		if msg.err != nil {
+			t.Fatalf("%s: %v", tt.path, msg.err)
+		}
+		if g := decodeTestGif(t, out); len(g.Image) != 3 {
+			t.Errorf("%s: got %d frames, want 3", tt.path, len(g.Image))
+		}
+		data, err := os.ReadFile(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), "-vf fps=10") {
+			t.Errorf("%s: ffmpeg got arguments %s, want the frame rate 10", tt.path, data)
+		}
+	}
+}
+
+// TestFramesFromWebm extracts frames of a webm encoded by ffmpeg with the real ffmpeg.
+func TestFramesFromWebm(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg isn't installed")
+	}
+	dir := t.TempDir()
+	video := filepath.Join(dir, "in.webm")
+	f, err := os.Create(video)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := encodeVideo(context.Background(), f, testFrames(32, 32, testRed, testGreen, testBlue), 10, webmArgs, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	out := filepath.Join(dir, "out.gif")
+	if msg := gen(context.Background(), video, out, Options{fps: 10}, nil)().(resultMsg); msg.err != nil {
+		t.Fatal(msg.err)
+	}
+	if g := decodeTestGif(t, out); len(g.Image) != 3 {
+		t.Errorf("got %d frames, want 3", len(g.Image))
+	}
+}
+
+func TestWebmWithoutFfmpeg(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	dir := t.TempDir()
+	writeTestPngs(t, dir, 8, 8, testRed, testBlue)
+	files, err := listFiles(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the gif output isn't written either, as the build fails before decoding images
+	out := filepath.Join(dir, "out.gif")
+	err = BuildGif(context.Background(), files, out+","+filepath.Join(dir, "out.webm"), Options{})
+	if err == nil || !strings.Contains(err.Error(), "ffmpeg is required") {
+		t.Errorf("got %v, want an error about missing ffmpeg", err)
+	}
+	if _, err := os.Stat(out); err == nil {
+		t.Error("gif is written without ffmpeg for the webm")
+	}
+}
+
+// TestWebmOutput builds a webm with ffmpeg and checks its header.
+func TestWebmOutput(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg isn't installed")
+	}
+	dir := t.TempDir()
+	writeTestPngs(t, dir, 32, 32, testRed, testGreen, testBlue)
+	files, err := listFiles(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out.webm")
+	if err := BuildGif(context.Background(), files, out, Options{fps: 10}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// EBML magic, then the webm doc type in the header
+	if !bytes.HasPrefix(data, []byte{0x1a, 0x45, 0xdf, 0xa3}) || !bytes.Contains(data[:min(len(data), 64)], []byte("webm")) {
+		t.Errorf("output isn't a webm: % x", data[:min(len(data), 16)])
+	}
+	if probe, err := exec.LookPath("ffprobe"); err == nil {
+		codec, err := exec.Command(probe, "-v", "error", "-select_streams", "v:0", "-show_entries", "stream=codec_name", "-of", "csv=p=0", out).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(string(codec)) != "vp9" {
+			t.Errorf("got the codec %q, want vp9", codec)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/webp"
+)
+
+// isWebp checks if the path points to a webp image by its extension.
+func isWebp(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".webp"
+}
+
+// extractWebpFrames decodes frames of an animated webp into a temporary folder of png images.
+// The caller should remove the folder when it's done with the frames.
+func extractWebpFrames(path string, opts Options) (string, error) {
+	data, err := opts.readFile(path)
+	if err != nil {
+		return "", err
+	}
+	frames, err := decodeWebpFrames(data)
+	if err != nil {
+		return "", &fileError{"decode webp", path, err}
+	}
+
+	dir, err := os.MkdirTemp("", "png2gif-frames-")
+	if err != nil {
+		return "", err
+	}
+	enc := png.Encoder{CompressionLevel: png.BestSpeed}
+	for n, frame := range frames {
+		if err := writePng(&enc, filepath.Join(dir, fmt.Sprintf("frame_%06d.png", n+1)), frame); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// writePng encodes the image into a png file.
+func writePng(enc *png.Encoder, path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := enc.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// webpChunk is a chunk of a webp RIFF container.
+// @property {string} fourcc - The chunk type, e.g. "VP8X" or "ANMF".
+// @property {[]byte} data - The payload of the chunk.
+type webpChunk struct {
+	fourcc string
+	data   []byte
+}
+
+// readWebpChunks splits the payload of a RIFF container into chunks.
+func readWebpChunks(data []byte) ([]webpChunk, error) {
+	chunks := []webpChunk{}
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, fmt.Errorf("webp: truncated chunk header")
+		}
+		size := int(binary.LittleEndian.Uint32(data[4:8]))
+		if size > len(data)-8 {
+			return nil, fmt.Errorf("webp: truncated %s chunk", data[:4])
+		}
+		chunks = append(chunks, webpChunk{string(data[:4]), data[8 : 8+size]})
+		// chunks are padded to an even size
+		size += size & 1
+		if 8+size > len(data) {
+			break
+		}
+		data = data[8+size:]
+	}
+	return chunks, nil
+}
+
+// writeWebpChunk appends a chunk with its header and padding to the buffer.
+func writeWebpChunk(b *bytes.Buffer, fourcc string, data []byte) {
+	header := make([]byte, 8)
+	copy(header, fourcc)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(data)))
+	b.Write(header)
+	b.Write(data)
+	if len(data)&1 == 1 {
+		b.WriteByte(0)
+	}
+}
+
+// uint24 reads a 24-bit little endian number.
+func uint24(b []byte) int {
+	return int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+}
+
+// decodeWebpFrames decodes all frames of a webp, composed on the canvas as viewers show them.
+// A still webp gives a single frame. Frame durations are ignored, frames play at the gif frame rate.
+func decodeWebpFrames(data []byte) ([]image.Image, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("webp: invalid format")
+	}
+	chunks, err := readWebpChunks(data[12:])
+	if err != nil {
+		return nil, err
+	}
+
+	var canvas *image.NRGBA
+	frames := []image.Image{}
+	for _, c := range chunks {
+		switch c.fourcc {
+		case "VP8X":
+			if len(c.data) < 10 {
+				return nil, fmt.Errorf("webp: invalid VP8X chunk")
+			}
+			// decode a still image as is
+			if c.data[0]&0x02 == 0 {
+				img, err := webp.Decode(bytes.NewReader(data))
+				if err != nil {
+					return nil, err
+				}
+				return []image.Image{img}, nil
+			}
+			canvas = image.NewNRGBA(image.Rect(0, 0, 1+uint24(c.data[4:7]), 1+uint24(c.data[7:10])))
+
+		case "VP8 ", "VP8L":
+			img, err := webp.Decode(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			return []image.Image{img}, nil
+
+		case "ANMF":
+			if canvas == nil || len(c.data) < 16 {
+				return nil, fmt.Errorf("webp: invalid ANMF chunk")
+			}
+			x, y := 2*uint24(c.data[0:3]), 2*uint24(c.data[3:6])
+			w, h := 1+uint24(c.data[6:9]), 1+uint24(c.data[9:12])
+			flags := c.data[15]
+
+			img, err := decodeWebpFrame(c.data[16:], w, h)
+			if err != nil {
+				return nil, err
+			}
+
+			// blend the frame over the canvas, unless the no blend bit is set
+			op := draw.Over
+			if flags&0x02 != 0 {
+				op = draw.Src
+			}
+			rect := image.Rect(x, y, x+w, y+h)
+			draw.Draw(canvas, rect, img, img.Bounds().Min, op)
+
+			frame := image.NewNRGBA(canvas.Rect)
+			copy(frame.Pix, canvas.Pix)
+			frames = append(frames, frame)
+
+			// dispose the frame area to transparent background
+			if flags&0x01 != 0 {
+				draw.Draw(canvas, rect, image.Transparent, image.Point{}, draw.Src)
+			}
+		}
+	}
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("webp: no frames found")
+	}
+	return frames, nil
+}
+
+// decodeWebpFrame wraps the bitstream chunks of an animation frame into a still webp and decodes it.
+func decodeWebpFrame(data []byte, w, h int) (image.Image, error) {
+	chunks, err := readWebpChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	body := bytes.Buffer{}
+	body.WriteString("WEBP")
+	hasAlpha := false
+	for _, c := range chunks {
+		if c.fourcc == "ALPH" {
+			hasAlpha = true
+		}
+	}
+	// lossy frames with alpha need the extended format header
+	if hasAlpha {
+		vp8x := make([]byte, 10)
+		vp8x[0] = 0x10
+		vp8x[4], vp8x[5], vp8x[6] = byte(w-1), byte((w-1)>>8), byte((w-1)>>16)
+		vp8x[7], vp8x[8], vp8x[9] = byte(h-1), byte((h-1)>>8), byte((h-1)>>16)
+		writeWebpChunk(&body, "VP8X", vp8x)
+	}
+	for _, c := range chunks {
+		if c.fourcc == "ALPH" || c.fourcc == "VP8 " || c.fourcc == "VP8L" {
+			writeWebpChunk(&body, c.fourcc, c.data)
+		}
+	}
+
+	riff := bytes.Buffer{}
+	writeWebpChunk(&riff, "RIFF", body.Bytes())
+	return webp.Decode(bytes.NewReader(riff.Bytes()))
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// bitWriter writes bits least significant first, like webp lossless bitstreams are read.
+type bitWriter struct {
+	buf  []byte
+	bits int
+}
+
+func (w *bitWriter) write(v uint32, n int) {
+	for i := 0; i < n; i++ {
+		if w.bits%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		w.buf[len(w.buf)-1] |= byte(v>>i&1) << (w.bits % 8)
+		w.bits++
+	}
+}
+
+// solidVP8L returns a lossless webp bitstream of a w x h image of the color,
+// every prefix code has a single symbol, so pixels take no bits.
+func solidVP8L(w, h int, c color.NRGBA) []byte {
+	b := &bitWriter{}
+	b.write(0x2f, 8)
+	b.write(uint32(w-1), 14)
+	b.write(uint32(h-1), 14)
+	b.write(1, 1) // alpha is used
+	b.write(0, 3) // version
+	b.write(0, 1) // no transforms
+	b.write(0, 1) // no color cache
+	b.write(0, 1) // no meta prefix codes
+	// green, red, blue and alpha codes, then the distance one
+	for _, v := range []uint8{c.G, c.R, c.B, c.A, 0} {
+		b.write(1, 1) // simple code
+		b.write(0, 1) // of one symbol
+		b.write(1, 1) // of 8 bits
+		b.write(uint32(v), 8)
+	}
+	return b.buf
+}
+
+// webpFrame is a frame of a test animation.
+// @property {image.Rectangle} rect - The area of the frame on the canvas, its corner is at even coordinates.
+// @property {color.NRGBA} color - The color of the frame.
+// @property {byte} flags - 1 to dispose the frame to the background, 2 not to blend it.
+type webpFrame struct {
+	rect  image.Rectangle
+	color color.NRGBA
+	flags byte
+}
+
+// animatedWebp returns an animated webp of the w x h canvas and solid frames.
+func animatedWebp(w, h int, frames ...webpFrame) []byte {
+	u24 := func(b []byte, v int) []byte { return append(b, byte(v), byte(v>>8), byte(v>>16)) }
+	body := bytes.Buffer{}
+	body.WriteString("WEBP")
+	vp8x := []byte{0x12, 0, 0, 0}
+	vp8x = u24(u24(vp8x, w-1), h-1)
+	writeWebpChunk(&body, "VP8X", vp8x)
+	writeWebpChunk(&body, "ANIM", make([]byte, 6))
+	for _, f := range frames {
+		anmf := u24(u24(nil, f.rect.Min.X/2), f.rect.Min.Y/2)
+		anmf = u24(u24(anmf, f.rect.Dx()-1), f.rect.Dy()-1)
+		anmf = append(u24(anmf, 100), f.flags)
+		frame := bytes.NewBuffer(anmf)
+		writeWebpChunk(frame, "VP8L", solidVP8L(f.rect.Dx(), f.rect.Dy(), f.color))
+		writeWebpChunk(&body, "ANMF", frame.Bytes())
+	}
+	riff := bytes.Buffer{}
+	writeWebpChunk(&riff, "RIFF", body.Bytes())
+	return riff.Bytes()
+}
+
+var (
+	webpRed  = color.NRGBA{255, 0, 0, 255}
+	webpBlue = color.NRGBA{0, 0, 255, 255}
+)
+
+func TestDecodeWebpFrames(t *testing.T) {
+	data := animatedWebp(4, 4,
+		webpFrame{image.Rect(0, 0, 4, 4), webpRed, 0},
+		// blended over the red frame, then disposed
+		webpFrame{image.Rect(2, 2, 4, 4), webpBlue, 1},
+		webpFrame{image.Rect(0, 0, 2, 2), color.NRGBA{0, 255, 0, 128}, 2},
+	)
+	frames, err := decodeWebpFrames(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 3 {
+		t.Fatalf("got %d frames, want 3", len(frames))
+	}
+	for _, tt := range []struct {
+		frame, x, y int
+		want        color.Color
+	}{
+		{0, 3, 3, webpRed},
+		{1, 3, 3, webpBlue},
+		{1, 0, 0, webpRed},
+		// the blue corner is disposed to transparent
+		{2, 3, 3, color.NRGBA{}},
+		// a frame that isn't blended replaces the canvas
+		{2, 0, 0, color.NRGBA{0, 255, 0, 128}},
+		{2, 2, 0, webpRed},
+	} {
+		if got := frames[tt.frame].At(tt.x, tt.y); !colorsEqual(got, tt.want) {
+			t.Errorf("frame %d at %d,%d: got %v, want %v", tt.frame, tt.x, tt.y, got, tt.want)
+		}
+	}
+
+	if _, err := decodeWebpFrames([]byte("RIFF\x04\x00\x00\x00WEBP")); err == nil {
+		t.Error("no error for a webp without frames")
+	}
+}
+
+func TestWebpInput(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.webp")
+	data := animatedWebp(4, 4,
+		webpFrame{image.Rect(0, 0, 4, 4), webpRed, 0},
+		webpFrame{image.Rect(0, 0, 4, 4), webpBlue, 0},
+		webpFrame{image.Rect(0, 0, 4, 4), webpRed, 0},
+	)
+	if err := os.WriteFile(in, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out.gif")
+	if msg := gen(context.Background(), in, out, Options{}, nil)().(resultMsg); msg.err != nil {
+		t.Fatal(msg.err)
+	}
+	if g := decodeTestGif(t, out); len(g.Image) != 3 {
+		t.Errorf("got %d frames, want 3", len(g.Image))
+	}
+}
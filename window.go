@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timeLayouts are the layouts of times accepted by -since and -until, times without a zone are local.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseTime parses a time of the modification time window: a date, a date and time, e.g. 2024-01-02 15:04,
+// an RFC 3339 time, or a duration before now, e.g. 90m.
+func parseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, use a date like 2024-01-02, a time like 2024-01-02 15:04 or an RFC 3339 time, or a duration before now like 90m", s)
+}
+
+// inTimeWindow checks if the modification time is in the window of options, since is inclusive and until is exclusive.
+// Zero times leave the window open.
+func (o Options) inTimeWindow(mtime time.Time) bool {
+	if !o.since.IsZero() && mtime.Before(o.since) {
+		return false
+	}
+	return o.until.IsZero() || mtime.Before(o.until)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.Local)
+	for _, tt := range []struct {
+		s    string
+		want time.Time
+	}{
+		{"2024-01-02", time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)},
+		{"2024-01-02 15:04", time.Date(2024, 1, 2, 15, 4, 0, 0, time.Local)},
+		{"2024-01-02T15:04:05", time.Date(2024, 1, 2, 15, 4, 5, 0, time.Local)},
+		{" 2024-01-02 15:04:05 ", time.Date(2024, 1, 2, 15, 4, 5, 0, time.Local)},
+		{"2024-01-02T15:04:05Z", time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"90m", now.Add(-90 * time.Minute)},
+		{"0s", now},
+	} {
+		got, err := parseTime(tt.s, now)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.s, err)
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.s, got, tt.want)
+		}
+	}
+	for _, s := range []string{"yesterday", "-2h", "2024-13-01", "01/02/2024"} {
+		if _, err := parseTime(s, now); err == nil {
+			t.Errorf("%q: got no error", s)
+		}
+	}
+}
+
+func TestTimeWindow(t *testing.T) {
+	day := func(d, h int) time.Time { return time.Date(2024, 1, d, h, 0, 0, 0, time.Local) }
+	fsys := fstest.MapFS{
+		"a.png": {ModTime: day(1, 0)},
+		"b.png": {ModTime: day(1, 12)},
+		"c.png": {ModTime: day(2, 0)},
+		"d.png": {ModTime: day(2, 12)},
+		"e.png": {ModTime: day(3, 6)},
+	}
+	for _, tt := range []struct {
+		args []string
+		want []string
+	}{
+		{nil, []string{"a.png", "b.png", "c.png", "d.png", "e.png"}},
+		// since is inclusive and until is exclusive
+		{[]string{"-since", "2024-01-01", "-until", "2024-01-02"}, []string{"a.png", "b.png"}},
+		{[]string{"-since", "2024-01-02 00:00"}, []string{"c.png", "d.png", "e.png"}},
+		{[]string{"-until", "2024-01-01T12:00:00"}, []string{"a.png"}},
+		{[]string{"-since", "2024-01-04"}, []string{}},
+	} {
+		if got := listTestFiles(t, fsys, parseTestFlags(t, tt.args...).opts); !slices.Equal(got, tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.args, got, tt.want)
+		}
+	}
+
+	// durations are counted back from now
+	recent := fstest.MapFS{
+		"old.png": {ModTime: time.Now().Add(-3 * time.Hour)},
+		"new.png": {ModTime: time.Now().Add(-time.Minute)},
+	}
+	if got := listTestFiles(t, recent, parseTestFlags(t, "-since", "1h").opts); !slices.Equal(got, []string{"new.png"}) {
+		t.Errorf("-since 1h: got %v, want new.png", got)
+	}
+
+	parseUsageError(t, "-since", "someday")
+	parseUsageError(t, "-until", "someday")
+	parseUsageError(t, "-since", "2024-01-02", "-until", "2024-01-01")
+	parseUsageError(t, "-since", "2024-01-02", "-until", "2024-01-02")
+}